@@ -1,5 +1,22 @@
 #!/bin/sh
+#
+# Deletes bridges, links and network namespaces created by mininet
+# for the topology (default is "mininet"), everything else is untouched.
+#
+# Usage: cleanup.sh [topology]
 
-ovs-vsctl show | grep Bridge | awk '{print $2}' | xargs -n1 ovs-vsctl del-br
-ip netns list | xargs  -n1 ip netns del
-ip link | egrep -oh "[0-9]+: .*+:" | grep -v eth0 | awk -F ':' '{print $2}' | xargs -n1 ip link delete
+TOPOLOGY=${1:-mininet}
+STATEDIR=/var/run/mininet
+
+ovs-vsctl --bare --columns=name find bridge external_ids:mn-topology=$TOPOLOGY | xargs -r -n1 ovs-vsctl --if-exists del-br
+ip -o link show | grep "alias mn:$TOPOLOGY\$" | awk -F ': ' '{print $2}' | cut -d@ -f1 | xargs -r -n1 ip link delete
+
+for f in $STATEDIR/netns/*; do
+    [ -f "$f" ] || continue
+    [ "$(cat $f)" = "$TOPOLOGY" ] || continue
+
+    ns=$(basename $f)
+    ip netns pids $ns 2>/dev/null | xargs -r kill -9
+    ip netns delete $ns 2>/dev/null
+    rm -f $f
+done
@@ -0,0 +1,487 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/3d0c/mininet/pkg/mn"
+	"github.com/3d0c/mininet/pkg/perf"
+)
+
+const usageText = `Usage: mn-ctl [-t topology] [command [arguments]]
+
+Without a command interactive shell is started.
+
+Commands:
+  up {file}                     Create the scheme or converge running one to it
+  plan [file]                   Show what recover or "up file" is going to do
+  validate [file]               Check the scheme, all problems are printed
+  schema                        Print JSON Schema of the scheme file
+  discover [format]             Print the live system as a scheme
+  drift [-o format]             Compare the scheme with the live system
+  down                          Release the scheme and cleanup its resources
+  exec {host} -- {command}      Run command inside host's netns
+  link [node] {link} set {params}
+                                Change link's impairment, e.g. delay 50ms loss 1%
+  link [node] {link} clear      Remove link's impairment
+  link [node] {link} down|up    Set link and its peer down or up
+  chaos {duration} {interval} [seed]
+                                Flap random links by seeded schedule
+  pingall [count] [-o format]   Ping all hosts from each other
+  iperf {client} {server} [-u] [-t duration] [-b rate] [-l length] [-o format]
+                                Measure TCP or UDP throughput between hosts
+  scenario {file} [-junit file] [-o format]
+                                Run scenario steps, write JUnit XML report
+  ps {host} [-o format]         Show processes associated with host
+  show hosts|switches [-o format]
+                                Print hosts or switches
+  dump [-o format]              Print the whole scheme
+  export json|yaml|toml         Export the scheme, it could be imported back
+  export dot|mermaid            Render the scheme as a diagram
+
+Output format is one of text, table, json or yaml, json and yaml have
+stable schemas of hosts, switches, links and processes.
+
+All of them are available in the interactive shell too and any shell command,
+e.g. "mn-ctl new host h1" or "mn-ctl plan", could be run this way. Exit status
+is 0 on success, 1 on failure, 2 on wrong usage, exec returns exit status of
+the command.
+
+Flags:
+`
+
+func usage() {
+	fmt.Fprint(os.Stderr, usageText)
+	flag.PrintDefaults()
+}
+
+// runSubcommand runs non-interactive command and returns exit status
+func runSubcommand(args []string) int {
+	err := execute(args)
+
+	if args[0] != "down" {
+		if serr := scheme.SaveState(); serr != nil {
+			log.Println("Unable to save state:", serr)
+		}
+	}
+
+	if err == nil {
+		return 0
+	}
+
+	// exec returns exit status of the command
+	var exitErr *exec.ExitError
+	if args[0] == "exec" && errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+
+	log.Println(err)
+
+	if err == errBadArguments {
+		usage()
+		return 2
+	}
+
+	return 1
+}
+
+// drift prints discrepancies between the scheme and the live system,
+// it fails if there are any
+func drift(commands []string) error {
+	_, format, err := outputFormat(commands)
+	if err != nil {
+		return err
+	}
+
+	report, err := scheme.Drift()
+	if err != nil {
+		return err
+	}
+
+	if len(report) == 0 && (format == "text" || format == "table") {
+		fmt.Println("No drift")
+		return nil
+	}
+
+	err = render(format, report, func(w io.Writer) {
+		fmt.Fprint(w, report.String())
+	}, func(w io.Writer) {
+		for _, d := range report {
+			fmt.Fprintln(w, d)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(report) > 0 {
+		return fmt.Errorf("Scheme has drifted, %d discrepancies", len(report))
+	}
+
+	return nil
+}
+
+// linkCommand changes the link, node could be omitted, if the link's
+// name is unique in the scheme
+func linkCommand(commands []string) error {
+	if len(commands) < 2 {
+		return errBadArguments
+	}
+
+	var node string
+
+	switch commands[1] {
+	case "set", "clear", "down", "up":
+		owner, err := scheme.LinkOwner(commands[0])
+		if err != nil {
+			return err
+		}
+
+		node = owner
+	default:
+		node, commands = commands[0], commands[1:]
+	}
+
+	if len(commands) < 2 {
+		return errBadArguments
+	}
+
+	n, found := scheme.GetNode(node)
+	if !found {
+		return fmt.Errorf("Node %s not found in scheme", node)
+	}
+
+	l, found := n.GetLinks().ByName(commands[0])
+	if !found {
+		return fmt.Errorf("Link %s of %s not found in scheme", commands[0], node)
+	}
+
+	switch commands[1] {
+	case "set":
+		im, err := mn.ParseImpairment(commands[2:], l.Impairment)
+		if err != nil {
+			return err
+		}
+
+		if err := scheme.SetImpairment(node, l.Name, im); err != nil {
+			return err
+		}
+
+		fmt.Println("Link", l.Name, "of", node, "is impaired:", im)
+
+	case "clear":
+		if err := scheme.SetImpairment(node, l.Name, nil); err != nil {
+			return err
+		}
+
+		fmt.Println("Link", l.Name, "of", node, "is cleared")
+
+	case "down", "up":
+		return scheme.SetLinkState(node, l.Name, strings.ToUpper(commands[1]))
+
+	default:
+		return errBadArguments
+	}
+
+	return nil
+}
+
+// chaos flaps random links, "chaos {duration} {interval} [seed]", the
+// seed is printed, so the same schedule could be run again
+func chaos(commands []string) error {
+	if len(commands) < 2 || len(commands) > 3 {
+		return errBadArguments
+	}
+
+	duration, err := time.ParseDuration(commands[0])
+	if err != nil {
+		return fmt.Errorf("Wrong duration %s: %v", commands[0], err)
+	}
+
+	interval, err := time.ParseDuration(commands[1])
+	if err != nil {
+		return fmt.Errorf("Wrong interval %s: %v", commands[1], err)
+	}
+
+	seed := time.Now().UnixNano()
+	if len(commands) == 3 {
+		if seed, err = strconv.ParseInt(commands[2], 10, 64); err != nil {
+			return fmt.Errorf("Wrong seed %s: %v", commands[2], err)
+		}
+	}
+
+	schedule, err := scheme.NewChaosSchedule(seed, duration, interval)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Chaos seed", seed)
+	fmt.Print(schedule)
+
+	stop := make(chan struct{})
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	defer signal.Stop(sig)
+
+	go func() {
+		if _, ok := <-sig; ok {
+			close(stop)
+		}
+	}()
+
+	return scheme.Chaos(schedule, stop)
+}
+
+// pingall pings all hosts from each other, "pingall [count] [-o format]",
+// it fails if any ping is lost
+func pingall(commands []string) error {
+	rest, format, err := outputFormat(commands)
+	if err != nil {
+		return err
+	}
+
+	opts := mn.PingOptions{}
+
+	switch len(rest) {
+	case 0:
+	case 1:
+		if opts.Count, err = strconv.Atoi(rest[0]); err != nil {
+			return fmt.Errorf("Wrong count %s", rest[0])
+		}
+	default:
+		return errBadArguments
+	}
+
+	report, err := scheme.PingAll(opts)
+	if err != nil {
+		return err
+	}
+
+	err = render(format, report, func(w io.Writer) {
+		pingTable(w, report)
+	}, func(w io.Writer) {
+		fmt.Fprint(w, report.String())
+	})
+	if err != nil {
+		return err
+	}
+
+	if report.Received() != report.Sent() {
+		return fmt.Errorf("%d of %d pings are lost", report.Sent()-report.Received(), report.Sent())
+	}
+
+	return nil
+}
+
+// iperf measures throughput, "iperf {client} {server} [-u] [-t duration]
+// [-b rate] [-l length] [-o format]"
+func iperf(commands []string) error {
+	rest, format, err := outputFormat(commands)
+	if err != nil {
+		return err
+	}
+
+	if len(rest) < 2 {
+		return errBadArguments
+	}
+
+	o := perf.Options{Protocol: "tcp"}
+
+	for i := 2; i < len(rest); i++ {
+		if rest[i] == "-u" {
+			o.Protocol = "udp"
+			continue
+		}
+
+		if i+1 == len(rest) {
+			return errBadArguments
+		}
+
+		switch rest[i] {
+		case "-t":
+			o.Duration, err = time.ParseDuration(rest[i+1])
+		case "-b":
+			o.Rate, err = perf.ParseRate(rest[i+1])
+		case "-l":
+			o.Length, err = strconv.Atoi(rest[i+1])
+		default:
+			return errBadArguments
+		}
+
+		if err != nil {
+			return fmt.Errorf("Wrong %s %s: %v", rest[i], rest[i+1], err)
+		}
+
+		i++
+	}
+
+	r, err := scheme.Iperf(rest[0], rest[1], o)
+	if err != nil {
+		return err
+	}
+
+	return render(format, r, func(w io.Writer) {
+		fmt.Fprintln(w, "CLIENT\tSERVER\tPROTOCOL\tSECONDS\tBYTES\tBANDWIDTH\tJITTER\tLOST")
+		fmt.Fprintf(w, "%s\t%s\t%s\t%.2f\t%s\t%s\t%.3fms\t%d/%d\n", rest[0], rest[1], r.Protocol, r.Seconds, perf.Bytes(r.Bytes), perf.Bits(r.Bandwidth), r.Jitter, r.Lost, r.Packets)
+	}, func(w io.Writer) {
+		fmt.Fprintln(w, rest[0], "->", rest[1], r)
+	})
+}
+
+// scenario runs the scenario file, "scenario {file} [-junit file] [-o format]",
+// against the running scheme, or brings up the scenario's one. It fails if
+// any step fails.
+func scenario(commands []string) error {
+	rest, format, err := outputFormat(commands)
+	if err != nil {
+		return err
+	}
+
+	junit := ""
+	if len(rest) == 3 && rest[1] == "-junit" {
+		junit, rest = rest[2], rest[:1]
+	}
+
+	if len(rest) != 1 {
+		return errBadArguments
+	}
+
+	sc, err := mn.NewScenarioFromFile(rest[0])
+	if err != nil {
+		return err
+	}
+
+	var running *mn.Scheme
+	if len(scheme.Hosts) > 0 || len(scheme.Switches) > 0 {
+		running = scheme
+	}
+
+	report := sc.Run(running)
+
+	if junit != "" {
+		out, err := report.JUnit()
+		if err != nil {
+			return err
+		}
+
+		if err := ioutil.WriteFile(junit, out, 0644); err != nil {
+			return err
+		}
+	}
+
+	err = render(format, report, func(w io.Writer) {
+		fmt.Fprint(w, report.String())
+	}, func(w io.Writer) {
+		fmt.Fprint(w, report.String())
+	})
+	if err != nil {
+		return err
+	}
+
+	if n := report.Failures(); n > 0 {
+		return fmt.Errorf("Scenario %s failed, %d of %d steps", report.Name, n, len(report.Steps))
+	}
+
+	return nil
+}
+
+// loadScheme loads the scheme file, warnings of the import are printed
+func loadScheme(fname string) (*mn.Scheme, error) {
+	s, err := mn.NewSchemeFromFile(fname)
+	if err != nil {
+		return nil, err
+	}
+
+	printWarnings(s)
+
+	return s, nil
+}
+
+func printWarnings(s *mn.Scheme) {
+	for _, w := range s.Warnings() {
+		fmt.Fprintln(os.Stderr, "Warning:", w)
+	}
+}
+
+// up creates the scheme from file, if some scheme is already running,
+// it's converged to the file
+func up(fname string) error {
+	desired, err := loadScheme(fname)
+	if err != nil {
+		return err
+	}
+
+	if len(scheme.Hosts) > 0 || len(scheme.Switches) > 0 {
+		return scheme.Reconcile(desired)
+	}
+
+	// failed scheme is rolled back, so it isn't the running one
+	if err := desired.Apply(); err != nil {
+		return err
+	}
+
+	scheme = desired
+
+	return nil
+}
+
+// planFile returns operations of "up fname", loading of the file has no side effects
+func planFile(fname string) (mn.Plan, error) {
+	desired, err := loadScheme(fname)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(scheme.Hosts) > 0 || len(scheme.Switches) > 0 {
+		return scheme.Diff(desired)
+	}
+
+	return desired.Plan()
+}
+
+// down releases the scheme, removes everything left by its topology
+// and the state file
+func down() error {
+	scheme.Release()
+
+	if err := scheme.Cleanup(); err != nil {
+		return err
+	}
+
+	scheme = mn.NewScheme().SetTopology(scheme.Topology)
+
+	return mn.RemoveState(scheme.Topology)
+}
+
+// execCommand runs "host -- command args..."
+func execCommand(args []string) error {
+	if len(args) < 2 {
+		return errBadArguments
+	}
+
+	host, found := scheme.GetHost(args[0])
+	if !found {
+		return fmt.Errorf("Host %s not found in scheme", args[0])
+	}
+
+	args = args[1:]
+	if args[0] == "--" {
+		args = args[1:]
+	}
+
+	if len(args) == 0 {
+		return errBadArguments
+	}
+
+	return hostExec(host, args)
+}
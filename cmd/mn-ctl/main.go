@@ -2,7 +2,10 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
@@ -16,7 +19,7 @@ import (
 
 var (
 	historyFn = "/tmp/.liner_history"
-	names     = []string{"help", "new", "new host", "new switch", "new link", "new router", "dump-json", "import", "recover", "release", "show hosts", "show switches"}
+	names     = []string{"help", "new", "new host", "new switch", "new link", "new router", "new topo", "dump-json", "export", "import", "validate", "schema", "discover", "drift", "plan", "recover", "diff", "apply", "release", "cleanup", "up", "down", "exec", "ps", "link", "chaos", "pingall", "iperf", "scenario", "show hosts", "show switches"}
 )
 
 var generalHelpTest = `
@@ -30,8 +33,12 @@ Commands:
              Right node should be a namespaced host.
              Options:
                 Cidr:   valid_cidr or noip literal
+                Addrs:  additional IPv4 or IPv6 CIDRs
+                SLAAC:  true to autoconfigure IPv6 addresses
                 Name:   interface name
                 HwAddr: interface address
+                Impairment: {"Rate":"10mbit","Delay":"50ms","Jitter":"5ms",
+                        "Loss":1,"Duplicate":0,"Reorder":0,"Limit":1000}
 
                 E.g.:
                     new link switch1, host1
@@ -39,14 +46,79 @@ Commands:
                     new link switch1 host1 {"Cidr":"noip","Name":"ctrl0"} {"Cidr":"192.168.55.200/24","Name":"ctrl1","NetNs":"root"}
 
   new router [name]     Create router, same as host, but with forwarding enabled
-  dump                  Dump as a plain text
+  new topo   {name} [args]
+                        Create standard topo in empty scheme:
+                        single {n}, linear {n}, tree {depth} {fanout}, mesh {n}, fattree {k}
+                        or mininet's --topo and --controller syntax.
+                        E.g.:
+                            new topo tree 2 3
+                            new topo --topo=tree,2,3 --controller=remote,ip=10.0.0.100
+  dump [-o format]      Dump as a plain text, or as a table of links, json or yaml
   dump-json             Dump as a json
-  show hosts            Print hosts
-  show switches         Print switches
-  import {file.json}    Import json scheme 
+  export {format}       Export scheme as json, yaml or toml, or render it as
+                        dot or mermaid diagram, e.g.: export dot | dot -Tsvg > lab.svg
+  show hosts [-o format]
+                        Print hosts
+  show switches [-o format]
+                        Print switches
+  import {file}         Import scheme, json, yaml, toml, topo, MiniEdit or containerlab
+                        format is detected by extension: .json, .yaml, .yml, .toml, .topo,
+                        .mn, .clab.yml
+  validate [file]       Check the file or the current scheme, all problems are printed
+  schema                Print JSON Schema of the scheme file
+  discover [format]     Print the live system: namespaces, veths, addresses, routes
+                        and OVS bridges, as json (default), yaml, toml, dot or mermaid
+  drift [-o format]     Compare the scheme with the live system: links, addresses, MACs,
+                        state, routes, ports, controllers and processes
+  plan [file]           Show what recover is going to do, nothing is executed.
+                        With the file shows what "up file" is going to do
+  recover               Create everything from the scheme, which doesn't exist
+  diff {file}           Show what apply is going to change in running scheme
+  apply {file}          Converge running scheme to the file: delete dropped nodes,
+                        links, routes and processes, update changed ones, create new
+  cleanup [topology]    Delete bridges, links, namespaces and processes of the topology,
+                        including leftovers of crashed runs. Default is current scheme's
+  up {file}             Create the scheme or converge running one to it
+  down                  Release the scheme and cleanup its resources
+  exec {host} -- {cmd}  Run command inside host's netns
+  ps {host} [-o format] Same as "hostname ps"
+  link [node] {link} set {params}
+                        Change impairment of the link, node could be omitted if the
+                        link's name is unique. Params are rate (or bw), delay, jitter,
+                        loss, duplicate, reorder and limit, unset ones are kept, e.g.:
+                            link h1-eth0 set delay 50ms jitter 5ms loss 1%
+                            link s1 h1-eth0 set rate 10mbit limit 100
+  link [node] {link} clear
+                        Remove impairment of the link
+  link [node] {link} down|up
+                        Set the link and its peer down or up, routes are restored on up
+  chaos {duration} {interval} [seed]
+                        Flap a random host's link every interval, it's down for 1/4-3/4
+                        of the interval. The same seed gives the same schedule, it's
+                        random by default. Ctrl-C stops it, e.g.:
+                            chaos 5m 30s 42
+  pingall [count] [-o format]
+                        Ping addresses of all hosts' links from each host, print
+                        reachability matrix, or loss and RTT of every ping as json/yaml.
+                        Fails if any ping is lost
+  iperf {client} {server} [-u] [-t duration] [-b rate] [-l length] [-o format]
+                        Measure throughput from client to the server's first address by
+                        mn-perf, which should be in the PATH. TCP is default, -u is UDP
+                        with jitter and loss, -b is its rate, e.g. 10M, default is 1M.
+                        -t is 10s by default, e.g.:
+                            iperf h1 h2 -u -b 20M -t 5s
+  scenario {file} [-junit file] [-o format]
+                        Run steps of the scenario against the running scheme, or bring up
+                        the scenario's Scheme, run them and release it. Every step is a test
+                        case of the JUnit XML report. Fails if any step fails, e.g.:
+                            scenario cmd/schemes/lab.scenario.yaml -junit lab.xml
+
+  Read commands accept -o (--output) text|table|json|yaml option,
+  json and yaml have stable schemas of hosts, switches, links and processes
   
   Host command:
-  hostname ps           Show processess associated with host
+  hostname ps [-o format]
+                        Show processess associated with host
   hostname proc output  {pid} Show process output
   hostname proc stop    {pid} Stop process
 `
@@ -59,7 +131,9 @@ func help(commands ...string) {
 
 var scheme *mn.Scheme = mn.NewScheme()
 
-func newNode(commands ...string) {
+var errBadArguments = errors.New("Bad arguments")
+
+func newNode(commands ...string) error {
 	switch commands[0] {
 	case "host":
 		var name string
@@ -69,8 +143,7 @@ func newNode(commands ...string) {
 
 		h, err := mn.NewHost(name)
 		if err != nil {
-			log.Println(err)
-			return
+			return err
 		}
 
 		fmt.Println("Host", h.NodeName(), "created")
@@ -84,8 +157,7 @@ func newNode(commands ...string) {
 
 		h, err := mn.NewRouter(name)
 		if err != nil {
-			log.Println(err)
-			return
+			return err
 		}
 
 		fmt.Println("Host", h.NodeName(), "created")
@@ -99,8 +171,7 @@ func newNode(commands ...string) {
 
 		s, err := mn.NewSwitch(name)
 		if err != nil {
-			log.Println(err)
-			return
+			return err
 		}
 
 		fmt.Println("Switch", s.NodeName(), "created")
@@ -113,8 +184,7 @@ func newNode(commands ...string) {
 
 		args := commands[1:]
 		if len(args) < 2 {
-			log.Println("At least two nodes required, e.g.: new link a,b")
-			return
+			return errors.New("At least two nodes required, e.g.: new link a,b")
 		}
 
 		n1 := args[0]
@@ -122,54 +192,151 @@ func newNode(commands ...string) {
 
 		if len(args) >= 3 && args[2] != "" {
 			if err := json.Unmarshal([]byte(args[2]), &left); err != nil {
-				log.Println(err)
-				return
+				return err
 			}
 		}
 
 		if len(args) >= 4 && args[3] != "" {
 			if err := json.Unmarshal([]byte(args[3]), &right); err != nil {
-				log.Println(err)
-				return
+				return err
 			}
 		}
 
 		node1, found := scheme.GetNode(n1)
 		if !found {
-			log.Println("No such node:", n1, "create it first")
-			return
+			return fmt.Errorf("No such node: %s, create it first", n1)
 		}
 
 		node2, found := scheme.GetNode(n2)
 		if !found {
-			log.Println("No such node:", n2, "create it first")
-			return
+			return fmt.Errorf("No such node: %s, create it first", n2)
 		}
 
 		pair := mn.NewLink(node1, node2, left, right)
 
+		journal := mn.NewJournal()
+
 		if err := pair.Create(); err != nil {
-			log.Println("Unable to create pair:", err)
-			return
+			return fmt.Errorf("Unable to create pair: %v", err)
 		}
+		journal.Record("create pair", pair.Left.Delete)
 
 		pair, err := pair.Up()
 		if err != nil {
-			log.Println("Can't bring it up,", err)
+			rollback(journal)
+			return fmt.Errorf("Can't bring it up, %v", err)
+		}
+
+		if err := node1.AddLink(pair.Left); err != nil {
+			rollback(journal)
+			return fmt.Errorf("Unable to add link: %v", err)
 		}
 
-		node1.AddLink(pair.Left)
 		node2.AddLink(pair.Right)
 
 		if pair.IsPatch() {
 			fmt.Println("[Patch]", node1.NodeName(), "<--->", node2.NodeName())
 		}
 
-		return
+	case "topo":
+		return newTopo(commands[1:]...)
+
+	default:
+		return errBadArguments
+	}
+
+	return nil
+}
+
+// newTopo creates generated topo "name args...", the scheme should be empty
+func newTopo(args ...string) error {
+	if len(args) == 0 {
+		return errBadArguments
+	}
+
+	if len(scheme.Hosts) > 0 || len(scheme.Switches) > 0 {
+		return errors.New("Scheme isn't empty, release it first")
+	}
+
+	var (
+		tmp *mn.Scheme
+		err error
+	)
+
+	// mininet's syntax, e.g. "tree,2,3" or "--topo=linear,4 --controller=remote,ip=10.0.0.100"
+	if strings.HasPrefix(args[0], "--") || strings.Contains(args[0], ",") {
+		tmp, err = mn.ImportMininet(strings.Join(args, " "))
+	} else {
+		tmp, err = generate(args[0], args[1:]...)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	printWarnings(tmp)
+
+	if err := tmp.SetTopology(scheme.Topology).Apply(); err != nil {
+		return err
+	}
+
+	scheme = tmp
+
+	for node := range scheme.Nodes() {
+		names = append(names, node.NodeName())
+	}
+
+	fmt.Println("Topo", strings.Join(args, " "), "created:", len(scheme.Switches), "switches,", len(scheme.Hosts), "hosts")
+
+	return nil
+}
+
+func generate(name string, args ...string) (*mn.Scheme, error) {
+	values := make([]int, 0, len(args))
+
+	for _, arg := range args {
+		v, err := strconv.Atoi(arg)
+		if err != nil {
+			return nil, fmt.Errorf("Wrong argument %s, number expected", arg)
+		}
+		values = append(values, v)
+	}
+
+	return mn.Generate(name, values...)
+}
+
+func rollback(journal *mn.Journal) {
+	if err := journal.Rollback(); err != nil {
+		log.Println("Rollback failed:", err)
 	}
 }
 
-func dump() {
+func dump(commands []string) error {
+	_, format, err := outputFormat(commands)
+	if err != nil {
+		return err
+	}
+
+	view := scheme.View()
+
+	return render(format, view, func(w io.Writer) {
+		links := make([]mn.LinkView, 0)
+
+		for _, s := range view.Switches {
+			links = append(links, s.Ports...)
+		}
+
+		for _, h := range view.Hosts {
+			links = append(links, h.Links...)
+		}
+
+		linksTable(w, links)
+	}, func(io.Writer) {
+		dumpText()
+	})
+}
+
+func dumpText() {
 	for _, s := range scheme.Switches {
 		fmt.Println("Switch:", s.NodeName())
 		for _, port := range s.Ports {
@@ -192,71 +359,321 @@ func dump() {
 
 }
 
-func hostCommand(commands []string) {
+func show(commands []string) error {
+	commands, format, err := outputFormat(commands)
+	if err != nil {
+		return err
+	}
+
+	if len(commands) == 0 {
+		return errBadArguments
+	}
+
+	switch commands[0] {
+	case "hosts":
+		hosts := scheme.HostViews()
+
+		return render(format, hosts, func(w io.Writer) {
+			hostsTable(w, hosts)
+		}, func(w io.Writer) {
+			for _, h := range hosts {
+				fmt.Fprintln(w, h.Name)
+			}
+		})
+
+	case "switches":
+		switches := scheme.SwitchViews()
+
+		return render(format, switches, func(w io.Writer) {
+			switchesTable(w, switches)
+		}, func(w io.Writer) {
+			for _, s := range switches {
+				fmt.Fprintln(w, s.Name)
+			}
+		})
+	}
+
+	return errBadArguments
+}
+
+func hostCommand(commands []string) error {
 	host, found := scheme.GetHost(commands[0])
 	if !found {
-		log.Println("Host", host, "not found in scheme")
-		return
+		return fmt.Errorf("Host %s not found in scheme", commands[0])
+	}
+
+	if len(commands) < 2 {
+		return errBadArguments
 	}
 
 	switch commands[1] {
 	case "ps":
-		for _, process := range host.Procs {
-			fmt.Printf("%5d %s %s\n", process.GetPid(), process.Command, strings.Join(process.Args, " "))
+		_, format, err := outputFormat(commands[2:])
+		if err != nil {
+			return err
 		}
 
+		procs := host.View().Procs
+
+		return render(format, procs, func(w io.Writer) {
+			procsTable(w, procs)
+		}, func(w io.Writer) {
+			for _, process := range host.Procs {
+				fmt.Fprintf(w, "%5d %s %s\n", process.GetPid(), process.Command, strings.Join(process.Args, " "))
+			}
+		})
+
 	case "start":
 		_, err := host.RunProcess(commands[2:]...)
 		if err != nil {
-			log.Println("Error running process:", err)
+			return fmt.Errorf("Error running process: %v", err)
 		}
 
 	case "proc":
 		if len(commands) < 4 {
-			log.Println("Please provide a pid of process to show")
-			break
+			return errors.New("Please provide a pid of process to show")
 		}
 
 		pid, err := strconv.Atoi(commands[3])
 		if err != nil {
-			log.Println("Wrong pid", commands[3])
-			break
+			return fmt.Errorf("Wrong pid %s", commands[3])
 		}
 
 		proc := host.Procs.GetByPid(pid)
 		if proc == nil {
-			log.Println("Can't find process", commands[3])
-			break
+			return fmt.Errorf("Can't find process %s", commands[3])
 		}
 
 		if commands[2] == "stop" {
-			if err := proc.Stop(); err != nil {
-				log.Println(err)
-			}
-
-			break
+			return proc.Stop()
 		}
 
 		if commands[2] == "output" {
 			out, err := ioutil.ReadFile(proc.Output)
 			if err != nil {
-				log.Println("Can't open process output file", proc.Output)
-				break
+				return fmt.Errorf("Can't open process output file %s", proc.Output)
 			}
 
 			fmt.Println(string(out))
 		}
 
 	default:
-		commands = append([]string{"netns", "exec"}, commands...)
-		out, err := mn.RunCommand("ip", commands...)
+		return hostExec(host, commands[1:])
+	}
+
+	return nil
+}
+
+// hostExec runs command inside host's netns, error holds command's exit status
+func hostExec(host *mn.Host, args []string) error {
+	out, err := host.RunCommand(args...)
+
+	fmt.Print(out)
+
+	return err
+}
+
+// execute runs a single command, it's shared by REPL and non-interactive mode
+func execute(commands []string) error {
+	if _, found := scheme.GetHost(commands[0]); found {
+		return hostCommand(commands)
+	}
+
+	switch commands[0] {
+	case "":
+
+	case "help":
+		if len(commands) > 1 {
+			help(commands[1:]...)
+		} else {
+			help(commands[0])
+		}
+
+	case "new":
+		if len(commands) < 2 {
+			return errBadArguments
+		}
+
+		return newNode(commands[1:]...)
+
+	case "dump":
+		return dump(commands[1:])
+
+	case "drift":
+		return drift(commands[1:])
+
+	case "dump-json":
+		fmt.Println(scheme)
+
+	case "export":
+		if len(commands) < 2 {
+			return errBadArguments
+		}
+
+		out, err := scheme.ExportAs(commands[1])
 		if err != nil {
-			log.Println("Error:", err, "Output:", out)
+			return err
+		}
+
+		fmt.Println(strings.TrimRight(out, "\n"))
+
+	case "import":
+		if len(commands) < 2 {
+			return errBadArguments
 		}
 
-		fmt.Println(out)
+		tmp, err := loadScheme(commands[1])
+		if err != nil {
+			return err
+		}
+
+		scheme = tmp
+
+		fmt.Println("Scheme", commands[1], "imported. User 'recover' command to apply it.")
+
+	case "validate":
+		tmp := scheme
+
+		if len(commands) > 1 {
+			var err error
+			if tmp, err = loadScheme(commands[1]); err != nil {
+				return err
+			}
+		}
+
+		if err := tmp.Validate(); err != nil {
+			return err
+		}
+
+		fmt.Println("Scheme is valid")
+
+	case "schema":
+		out, err := mn.JSONSchema()
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(out))
+
+	case "discover":
+		format := mn.FormatJSON
+		if len(commands) > 1 {
+			format = commands[1]
+		}
+
+		live, err := mn.Discover()
+		if err != nil {
+			return err
+		}
+
+		out, err := live.ExportAs(format)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(strings.TrimRight(out, "\n"))
+
+	case "plan":
+		var (
+			plan mn.Plan
+			err  error
+		)
+
+		if len(commands) > 1 {
+			plan, err = planFile(commands[1])
+		} else {
+			plan, err = scheme.Plan()
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if len(plan) == 0 {
+			fmt.Println("Nothing to do")
+			break
+		}
+
+		fmt.Print(plan)
+
+	case "diff", "apply":
+		if len(commands) < 2 {
+			return errBadArguments
+		}
+
+		desired, err := loadScheme(commands[1])
+		if err != nil {
+			return err
+		}
+
+		if commands[0] == "diff" {
+			plan, err := scheme.Diff(desired)
+			if err != nil {
+				return err
+			}
+
+			fmt.Print(plan)
+			break
+		}
+
+		return scheme.Reconcile(desired)
+
+	case "recover":
+		return scheme.Apply()
+
+	case "release":
+		scheme.Release()
+
+	case "cleanup":
+		if len(commands) > 1 {
+			return mn.Cleanup(commands[1])
+		}
+
+		return scheme.Cleanup()
+
+	case "show":
+		return show(commands[1:])
+
+	case "up":
+		if len(commands) != 2 {
+			return errBadArguments
+		}
+
+		return up(commands[1])
+
+	case "down":
+		return down()
+
+	case "exec":
+		return execCommand(commands[1:])
+
+	case "ps":
+		if len(commands) < 2 {
+			return errBadArguments
+		}
+
+		return hostCommand(append([]string{commands[1], "ps"}, commands[2:]...))
+
+	case "link":
+		return linkCommand(commands[1:])
+
+	case "chaos":
+		return chaos(commands[1:])
+
+	case "pingall":
+		return pingall(commands[1:])
+
+	case "iperf":
+		return iperf(commands[1:])
+
+	case "scenario":
+		return scenario(commands[1:])
+
+	default:
+		return fmt.Errorf("Unknown command: %s, see help", commands[0])
 	}
 
+	return nil
 }
 
 func init() {
@@ -264,6 +681,31 @@ func init() {
 }
 
 func main() {
+	topology := flag.String("t", mn.DefaultTopology, "topology ID, created resources are tagged with it")
+	pool6 := flag.String("6", "", "IPv6 network, e.g. fd00::/64, new links get addresses from it too")
+	flag.Usage = usage
+	flag.Parse()
+
+	mn.DefaultTopology = *topology
+
+	if *pool6 != "" {
+		if err := pool.SetNetwork6(*pool6); err != nil {
+			log.Fatalln("Wrong IPv6 network:", err)
+		}
+	}
+
+	// reattach to the topology, which is left by previous run
+	if tmp, err := mn.LoadState(*topology); err == nil {
+		scheme = tmp
+		log.Println("Reattached to topology", *topology, "from", mn.StateFile(*topology))
+	} else if !os.IsNotExist(err) {
+		log.Println("Unable to load state:", err)
+	}
+
+	if flag.NArg() > 0 {
+		os.Exit(runSubcommand(flag.Args()))
+	}
+
 	line := liner.NewLiner()
 	defer line.Close()
 
@@ -306,74 +748,13 @@ func main() {
 
 		commands := strings.Split(input, " ")
 
-		if _, found := scheme.GetHost(commands[0]); found {
-			hostCommand(commands)
+		if err := execute(commands); err != nil {
+			log.Println(err)
 		}
 
-		switch commands[0] {
-		case "help":
-			if len(commands) > 1 {
-				help(commands[1:]...)
-			} else {
-				help(commands[0])
-			}
-
-		case "new":
-			if len(commands) > 1 {
-				newNode(commands[1:]...)
-			} else {
-				log.Println("Bad arguments")
-			}
-
-		case "dump":
-			dump()
-
-		case "dump-json":
-			fmt.Println(scheme)
-
-		case "import":
-			if len(commands) > 1 {
-				tmp, err := mn.NewSchemeFromJSON(commands[1])
-				if err != nil {
-					log.Println(err)
-					break
-				}
-
-				scheme = tmp
-
-				fmt.Println("Scheme", commands[1], "imported. User 'recover' command to apply it.")
-			} else {
-				log.Println("Bad arguments")
-			}
-
-		case "recover":
-			if scheme != nil {
-				scheme.Recover()
-			}
-
-		case "release":
-			if scheme != nil {
-				scheme.Release()
-			}
-
-		case "show":
-			if len(commands) == 1 {
-				log.Println("Bad arguments")
-				break
-			}
-
-			if commands[1] == "hosts" {
-				for _, node := range scheme.Hosts {
-					fmt.Println(node.NodeName())
-				}
-				break
-			}
-
-			if commands[1] == "switches" {
-				for _, node := range scheme.Switches {
-					fmt.Println(node.NodeName())
-				}
-			}
+		// state is saved after every command, so mn-ctl can reattach after restart
+		if err := scheme.SaveState(); err != nil {
+			log.Println("Unable to save state:", err)
 		}
 
 	}
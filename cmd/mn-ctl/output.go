@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/3d0c/mininet/pkg/mn"
+	"gopkg.in/yaml.v3"
+)
+
+// output formats of read commands, text is a legacy free-form one
+var formats = []string{"text", "table", "json", "yaml"}
+
+// outputFormat extracts "-o format" or "--output format" option from the command
+func outputFormat(commands []string) ([]string, string, error) {
+	rest := make([]string, 0, len(commands))
+	format := "text"
+
+	for i := 0; i < len(commands); i++ {
+		switch {
+		case commands[i] == "-o" || commands[i] == "--output":
+			if i+1 == len(commands) {
+				return nil, "", errBadArguments
+			}
+			format = commands[i+1]
+			i++
+		case strings.HasPrefix(commands[i], "-o="), strings.HasPrefix(commands[i], "--output="):
+			format = commands[i][strings.Index(commands[i], "=")+1:]
+		default:
+			rest = append(rest, commands[i])
+		}
+	}
+
+	for _, f := range formats {
+		if f == format {
+			return rest, format, nil
+		}
+	}
+
+	return nil, "", fmt.Errorf("Unknown output format: %s, expected one of: %s", format, strings.Join(formats, ", "))
+}
+
+// render writes view in json or yaml, table and text are written by callbacks
+func render(format string, view interface{}, table, text func(io.Writer)) error {
+	switch format {
+	case "json":
+		out, err := json.MarshalIndent(view, "", "    ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(out))
+
+	case "yaml":
+		out, err := yaml.Marshal(view)
+		if err != nil {
+			return err
+		}
+
+		fmt.Print(string(out))
+
+	case "table":
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		table(w)
+		w.Flush()
+
+	default:
+		text(os.Stdout)
+	}
+
+	return nil
+}
+
+func hostsTable(w io.Writer, hosts []mn.HostView) {
+	fmt.Fprintln(w, "NAME\tNETNS\tLINKS\tPROCS\tCGROUP")
+
+	for _, h := range hosts {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\n", h.Name, h.NetNs, len(h.Links), len(h.Procs), h.Cgroup)
+	}
+}
+
+func switchesTable(w io.Writer, switches []mn.SwitchView) {
+	fmt.Fprintln(w, "NAME\tPORTS\tCONTROLLER")
+
+	for _, s := range switches {
+		fmt.Fprintf(w, "%s\t%d\t%s\n", s.Name, len(s.Ports), s.Controller)
+	}
+}
+
+func linksTable(w io.Writer, links []mn.LinkView) {
+	fmt.Fprintln(w, "NODE\tLINK\tSTATE\tCIDR\tMAC\tPEER")
+
+	for _, l := range links {
+		cidrs := strings.Join(append([]string{l.Cidr}, l.Addrs...), ",")
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s/%s\n", l.Node, l.Name, l.State, cidrs, l.HwAddr, l.PeerNode, l.PeerLink)
+	}
+}
+
+func procsTable(w io.Writer, procs []mn.ProcessView) {
+	fmt.Fprintln(w, "PID\tRUNNING\tCOMMAND\tOUTPUT")
+
+	for _, p := range procs {
+		fmt.Fprintf(w, "%d\t%t\t%s\t%s\n", p.Pid, p.Running, strings.Join(append([]string{p.Command}, p.Args...), " "), p.Output)
+	}
+}
+
+func pingTable(w io.Writer, report mn.PingReport) {
+	fmt.Fprintln(w, "SRC\tDST\tADDR\tSENT\tRECEIVED\tLOSS\tRTT\tERROR")
+
+	for _, r := range report {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%v%%\t%vms\t%s\n", r.Src, r.Dst, r.Addr, r.Sent, r.Received, r.Loss, r.RTT, r.Error)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/3d0c/mininet/pkg/perf"
+)
+
+func main() {
+	server := flag.Bool("s", false, "run server")
+	client := flag.String("c", "", "run client, connect to the server address")
+	port := flag.Int("p", perf.DefaultPort, "server port")
+	udp := flag.Bool("u", false, "use UDP instead of TCP")
+	duration := flag.Duration("t", perf.DefaultDuration, "time to transmit")
+	rate := flag.String("b", "1M", "UDP bandwidth, bits per second, K, M and G suffixes are supported")
+	length := flag.Int("l", 0, "length of buffer or datagram, default is 128K for TCP, 1400 for UDP")
+	asJSON := flag.Bool("json", false, "print result as json")
+	flag.Parse()
+
+	switch {
+	case *server:
+		s, err := perf.NewServer(perf.HostPort("", *port))
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		log.Println("Listening on", s.Addr())
+		log.Fatalln(s.Serve())
+
+	case *client != "":
+		o := perf.Options{Protocol: "tcp", Duration: *duration, Length: *length}
+
+		if *udp {
+			bps, err := perf.ParseRate(*rate)
+			if err != nil {
+				log.Fatalln("Wrong bandwidth:", *rate)
+			}
+
+			o.Protocol, o.Rate = "udp", bps
+		}
+
+		r, err := perf.Run(perf.HostPort(*client, *port), o)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		if *asJSON {
+			json.NewEncoder(os.Stdout).Encode(r)
+			return
+		}
+
+		fmt.Println(r)
+
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,107 @@
+package mn
+
+import (
+	"fmt"
+	"log"
+	"sync"
+)
+
+// LinkBackend is a low level interface for veth/link manipulation.
+// Link and Pair methods don't talk to the system directly, they delegate
+// everything to the current backend.
+type LinkBackend interface {
+	// CreatePair creates veth pair and puts both ends into their netns
+	CreatePair(left, right Link) error
+	// MoveToNs moves link from the root namespace into the netns
+	MoveToNs(l Link, netns string) error
+	// AddAddr assigns link's CIDR to the link
+	AddAddr(l Link) error
+	// DelAddr removes link's CIDR from the link
+	DelAddr(l Link) error
+	// SetHwAddr sets link's MAC address
+	SetHwAddr(l Link) error
+	// SetUp sets link state to up
+	SetUp(l Link) error
+	// SetDown sets link state to down
+	SetDown(l Link) error
+	// Delete removes the link
+	Delete(l Link) error
+	// Exists checks whether link exists in its netns
+	Exists(l Link) bool
+	// SetAlias sets link's alias, it's used to mark owned links
+	SetAlias(l Link, alias string) error
+	// ListByAlias returns names of links in the netns with given alias,
+	// empty netns means root namespace
+	ListByAlias(netns, alias string) ([]string, error)
+}
+
+// LinkError is returned by link backends
+type LinkError struct {
+	Op    string
+	Link  string
+	NetNs string
+	Err   error
+}
+
+func (e *LinkError) Error() string {
+	if e.NetNs != "" {
+		return fmt.Sprintf("%s %s (netns %s): %v", e.Op, e.Link, e.NetNs, e.Err)
+	}
+
+	return fmt.Sprintf("%s %s: %v", e.Op, e.Link, e.Err)
+}
+
+// Unwrap returns underlying error
+func (e *LinkError) Unwrap() error {
+	return e.Err
+}
+
+func linkError(op string, l Link, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &LinkError{Op: op, Link: l.Name, NetNs: l.NetNs, Err: err}
+}
+
+var (
+	backendOnce sync.Once
+	backend     LinkBackend
+)
+
+// DefaultLinkBackend returns the backend used by links,
+// rtnetlink is preferred, ip utility is a fallback
+func DefaultLinkBackend() LinkBackend {
+	backendOnce.Do(func() {
+		if backend != nil {
+			return
+		}
+
+		b, err := NewNetlinkBackend()
+		if err != nil {
+			log.Println("rtnetlink is not available, falling back to ip utility:", err)
+			backend = NewExecBackend()
+			return
+		}
+
+		backend = b
+	})
+
+	return backend
+}
+
+// backendFor returns ExecBackend for explicitly set executor,
+// default backend otherwise
+func backendFor(e Executor) LinkBackend {
+	if e != nil {
+		return NewExecBackend(e)
+	}
+
+	return DefaultLinkBackend()
+}
+
+// SetLinkBackend replaces default link backend
+func SetLinkBackend(b LinkBackend) {
+	backendOnce.Do(func() {})
+	backend = b
+}
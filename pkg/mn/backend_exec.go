@@ -0,0 +1,152 @@
+package mn
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ExecBackend implements LinkBackend by running ip utility
+type ExecBackend struct {
+	exec Executor
+}
+
+// NewExecBackend creates ip utility based backend,
+// optional executor runs the commands, DefaultExecutor is used otherwise
+func NewExecBackend(e ...Executor) *ExecBackend {
+	b := &ExecBackend{}
+
+	if len(e) > 0 {
+		b.exec = e[0]
+	}
+
+	return b
+}
+
+func (b *ExecBackend) run(l Link, args ...string) error {
+	_, err := b.output(l, args...)
+	return err
+}
+
+func (b *ExecBackend) output(l Link, args ...string) (string, error) {
+	if l.NetNs != "" {
+		args = append([]string{"netns", "exec", l.NetNs, "ip"}, args...)
+	}
+
+	out, err := executorOrDefault(b.exec).Run("ip", args...)
+	if err != nil {
+		return "", fmt.Errorf("%v, output: %s", err, strings.TrimSpace(out))
+	}
+
+	return out, nil
+}
+
+// CreatePair creates veth pair
+func (b *ExecBackend) CreatePair(left, right Link) error {
+	command := []string{"link", "add", "name", left.Name, "type", "veth", "peer", "name", right.Name}
+
+	if right.NetNs != "" {
+		command = append(command, "netns", right.NetNs)
+	}
+
+	if out, err := executorOrDefault(b.exec).Run("ip", command...); err != nil {
+		return linkError("create", left, fmt.Errorf("%v, output: %s", err, strings.TrimSpace(out)))
+	}
+
+	if left.NetNs != "" {
+		if err := b.MoveToNs(left, left.NetNs); err != nil {
+			// pair isn't journaled yet, so it's deleted here, peer goes with it
+			root := left
+			root.NetNs = ""
+			b.Delete(root)
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// MoveToNs moves link to network namespace
+func (b *ExecBackend) MoveToNs(l Link, netns string) error {
+	root := l
+	root.NetNs = ""
+
+	return linkError("move", l, b.run(root, "link", "set", l.Name, "netns", netns))
+}
+
+// AddAddr applies CIDR to the link, IPv6 address skips duplicate
+// address detection, so it's usable right away
+func (b *ExecBackend) AddAddr(l Link) error {
+	args := []string{"addr", "add", l.Cidr, "dev", l.Name}
+	if isIPv6(l.Cidr) {
+		args = append(args, "nodad")
+	}
+
+	return linkError("addr", l, b.run(l, args...))
+}
+
+// DelAddr removes CIDR from the link
+func (b *ExecBackend) DelAddr(l Link) error {
+	return linkError("addr", l, b.run(l, "addr", "del", l.Cidr, "dev", l.Name))
+}
+
+// SetHwAddr applies MAC address
+func (b *ExecBackend) SetHwAddr(l Link) error {
+	return linkError("mac", l, b.run(l, "link", "set", "dev", l.Name, "address", l.HwAddr))
+}
+
+// SetUp sets link to on
+func (b *ExecBackend) SetUp(l Link) error {
+	return linkError("up", l, b.run(l, "link", "set", l.Name, "up"))
+}
+
+// SetDown sets link to off
+func (b *ExecBackend) SetDown(l Link) error {
+	return linkError("down", l, b.run(l, "link", "set", l.Name, "down"))
+}
+
+// Delete deletes the link
+func (b *ExecBackend) Delete(l Link) error {
+	return linkError("delete", l, b.run(l, "link", "delete", l.Name))
+}
+
+// Exists checks whether link exists
+func (b *ExecBackend) Exists(l Link) bool {
+	return b.run(l, "link", "show", l.Name) == nil
+}
+
+// SetAlias sets link's alias
+func (b *ExecBackend) SetAlias(l Link, alias string) error {
+	return linkError("alias", l, b.run(l, "link", "set", "dev", l.Name, "alias", alias))
+}
+
+// ListByAlias returns names of links with the alias, parsed from
+// one line output, e.g. "5: eth0@if4: <...> ... \    alias mn:default"
+func (b *ExecBackend) ListByAlias(netns, alias string) ([]string, error) {
+	out, err := b.output(Link{NetNs: netns}, "-o", "link", "show")
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]string, 0)
+
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+
+		for i := 2; i < len(fields)-1; i++ {
+			if fields[i] != "alias" || fields[i+1] != alias {
+				continue
+			}
+
+			name := strings.TrimSuffix(fields[1], ":")
+			if at := strings.Index(name, "@"); at > 0 {
+				name = name[:at]
+			}
+
+			result = append(result, name)
+			break
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,214 @@
+package mn
+
+import (
+	"net"
+	"syscall"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+)
+
+// NetlinkBackend implements LinkBackend over rtnetlink
+type NetlinkBackend struct{}
+
+// NewNetlinkBackend creates rtnetlink backend,
+// returns error if netlink socket can't be opened
+func NewNetlinkBackend() (*NetlinkBackend, error) {
+	h, err := netlink.NewHandle()
+	if err != nil {
+		return nil, err
+	}
+
+	h.Delete()
+
+	return &NetlinkBackend{}, nil
+}
+
+// handle returns netlink handle bound to the network namespace,
+// empty name means root namespace
+func (b *NetlinkBackend) handle(name string) (*netlink.Handle, error) {
+	if name == "" {
+		return netlink.NewHandle()
+	}
+
+	ns, err := netns.GetFromName(name)
+	if err != nil {
+		return nil, err
+	}
+	defer ns.Close()
+
+	return netlink.NewHandleAt(ns)
+}
+
+// do runs fn against the link, found by name in link's netns
+func (b *NetlinkBackend) do(op string, l Link, fn func(*netlink.Handle, netlink.Link) error) error {
+	h, err := b.handle(l.NetNs)
+	if err != nil {
+		return linkError(op, l, err)
+	}
+	defer h.Delete()
+
+	link, err := h.LinkByName(l.Name)
+	if err != nil {
+		return linkError(op, l, err)
+	}
+
+	return linkError(op, l, fn(h, link))
+}
+
+// CreatePair creates veth pair
+func (b *NetlinkBackend) CreatePair(left, right Link) error {
+	h, err := b.handle("")
+	if err != nil {
+		return linkError("create", left, err)
+	}
+	defer h.Delete()
+
+	veth := &netlink.Veth{
+		LinkAttrs: netlink.LinkAttrs{Name: left.Name},
+		PeerName:  right.Name,
+	}
+
+	if right.NetNs != "" {
+		ns, err := netns.GetFromName(right.NetNs)
+		if err != nil {
+			return linkError("create", right, err)
+		}
+		defer ns.Close()
+
+		veth.PeerNamespace = netlink.NsFd(ns)
+	}
+
+	if err := h.LinkAdd(veth); err != nil {
+		return linkError("create", left, err)
+	}
+
+	if left.NetNs != "" {
+		if err := b.MoveToNs(left, left.NetNs); err != nil {
+			// pair isn't journaled yet, so it's deleted here, peer goes with it
+			if link, lerr := h.LinkByName(left.Name); lerr == nil {
+				h.LinkDel(link)
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// MoveToNs moves link from the root namespace into the netns
+func (b *NetlinkBackend) MoveToNs(l Link, name string) error {
+	root := l
+	root.NetNs = ""
+
+	return b.do("move", root, func(h *netlink.Handle, link netlink.Link) error {
+		ns, err := netns.GetFromName(name)
+		if err != nil {
+			return err
+		}
+		defer ns.Close()
+
+		return h.LinkSetNsFd(link, int(ns))
+	})
+}
+
+// AddAddr applies CIDR to the link, IPv6 address skips duplicate
+// address detection, so it's usable right away
+func (b *NetlinkBackend) AddAddr(l Link) error {
+	addr, err := netlink.ParseAddr(l.Cidr)
+	if err != nil {
+		return linkError("addr", l, err)
+	}
+
+	if isIPv6(l.Cidr) {
+		addr.Flags |= syscall.IFA_F_NODAD
+	}
+
+	return b.do("addr", l, func(h *netlink.Handle, link netlink.Link) error {
+		return h.AddrAdd(link, addr)
+	})
+}
+
+// DelAddr removes CIDR from the link
+func (b *NetlinkBackend) DelAddr(l Link) error {
+	addr, err := netlink.ParseAddr(l.Cidr)
+	if err != nil {
+		return linkError("addr", l, err)
+	}
+
+	return b.do("addr", l, func(h *netlink.Handle, link netlink.Link) error {
+		return h.AddrDel(link, addr)
+	})
+}
+
+// SetHwAddr applies MAC address
+func (b *NetlinkBackend) SetHwAddr(l Link) error {
+	hw, err := net.ParseMAC(l.HwAddr)
+	if err != nil {
+		return linkError("mac", l, err)
+	}
+
+	return b.do("mac", l, func(h *netlink.Handle, link netlink.Link) error {
+		return h.LinkSetHardwareAddr(link, hw)
+	})
+}
+
+// SetUp sets link to on
+func (b *NetlinkBackend) SetUp(l Link) error {
+	return b.do("up", l, func(h *netlink.Handle, link netlink.Link) error {
+		return h.LinkSetUp(link)
+	})
+}
+
+// SetDown sets link to off
+func (b *NetlinkBackend) SetDown(l Link) error {
+	return b.do("down", l, func(h *netlink.Handle, link netlink.Link) error {
+		return h.LinkSetDown(link)
+	})
+}
+
+// Delete deletes the link
+func (b *NetlinkBackend) Delete(l Link) error {
+	return b.do("delete", l, func(h *netlink.Handle, link netlink.Link) error {
+		return h.LinkDel(link)
+	})
+}
+
+// Exists checks whether link exists
+func (b *NetlinkBackend) Exists(l Link) bool {
+	return b.do("show", l, func(*netlink.Handle, netlink.Link) error {
+		return nil
+	}) == nil
+}
+
+// SetAlias sets link's alias
+func (b *NetlinkBackend) SetAlias(l Link, alias string) error {
+	return b.do("alias", l, func(h *netlink.Handle, link netlink.Link) error {
+		return h.LinkSetAlias(link, alias)
+	})
+}
+
+// ListByAlias returns names of links with the alias
+func (b *NetlinkBackend) ListByAlias(netns, alias string) ([]string, error) {
+	h, err := b.handle(netns)
+	if err != nil {
+		return nil, err
+	}
+	defer h.Delete()
+
+	links, err := h.LinkList()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]string, 0)
+
+	for _, link := range links {
+		if link.Attrs().Alias == alias {
+			result = append(result, link.Attrs().Name)
+		}
+	}
+
+	return result, nil
+}
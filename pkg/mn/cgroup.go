@@ -3,11 +3,16 @@ package mn
 import (
 	"encoding/json"
 	"log"
+	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/3d0c/mininet/pkg/cgroup"
 )
 
+// CgroupRoot is a mount point of cgroup v1 hierarchies
+var CgroupRoot = "/sys/fs/cgroup"
+
 // Cgroup structure
 type Cgroup struct {
 	*cgroup.Cgroup
@@ -43,21 +48,30 @@ func NewCgroup(name string) (*Cgroup, error) {
 	return cg, nil
 }
 
-// UnmarshalJSON satisfies Unarshller
+// UnmarshalJSON satisfies Unarshller, it only decodes the cgroup,
+// it's created by Scheme.Apply
 func (c *Cgroup) UnmarshalJSON(b []byte) error {
 	type tmp Cgroup
 	cg := tmp{}
 
-	cgroup.Init()
-
 	if err := json.Unmarshal(b, &cg); err != nil {
 		return err
 	}
 
 	c.Name = cg.Name
-	c.Cgroup = cgroup.NewCgroup(cg.Name)
+	c.Controllers = cg.Controllers
+
+	return nil
+}
+
+// Setup sets controllers, physically creates cgroup and sets controllers values
+func (c *Cgroup) Setup() error {
+	if c.Cgroup == nil {
+		cgroup.Init()
+		c.Cgroup = cgroup.NewCgroup(c.Name)
+	}
 
-	if err := c.SetControllers(cg.Controllers); err != nil {
+	if err := c.SetControllers(c.Controllers); err != nil {
 		return err
 	}
 
@@ -65,15 +79,30 @@ func (c *Cgroup) UnmarshalJSON(b []byte) error {
 		return err
 	}
 
-	if err := c.SetParams(cg.Controllers); err != nil {
+	if err := c.SetParams(c.Controllers); err != nil {
 		return err
 	}
 
-	c.Controllers = cg.Controllers
-
 	return nil
 }
 
+// Exists checks whether cgroup exists in hierarchies of all its controllers.
+// It only reads CgroupRoot, libcgroup is initialised by Setup, so a dry-run
+// plan doesn't touch it.
+func (c *Cgroup) Exists() bool {
+	if c == nil {
+		return false
+	}
+
+	for _, ctrl := range c.Controllers {
+		if _, err := os.Stat(filepath.Join(CgroupRoot, ctrl.Name, c.Name)); err != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
 // SetControllers add controller into Controllers collection
 func (c *Cgroup) SetControllers(controllers []Controller) error {
 	for _, controller := range controllers {
@@ -116,7 +145,7 @@ func (c *Cgroup) SetParams(controllers []Controller) error {
 
 // Release recusively release cgroup
 func (c *Cgroup) Release() {
-	if c != nil {
+	if c != nil && c.Cgroup != nil {
 		c.DeleteExt(cgroup.DeleteRecursive)
 	}
 }
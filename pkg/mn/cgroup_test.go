@@ -1,10 +1,15 @@
 package mn
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
 func TestCgroupImport(t *testing.T) {
+	requireSystem(t)
+
 	scheme, err := NewSchemeFromJSON(exampleScheme)
 	if err != nil {
 		t.Fatal(err)
@@ -12,6 +17,11 @@ func TestCgroupImport(t *testing.T) {
 
 	defer scheme.Release()
 
+	// loading doesn't create cgroups
+	if err := scheme.Apply(); err != nil {
+		t.Fatal(err)
+	}
+
 	host, found := scheme.GetHost("net1-h1")
 	if !found {
 		t.Fatal("Expected host net1-h1 not found")
@@ -34,6 +44,8 @@ func TestCgroupImport(t *testing.T) {
 }
 
 func TestCgroupCtrl(t *testing.T) {
+	requireSystem(t)
+
 	cg, err := NewCgroup("asdf5")
 	if err != nil {
 		t.Fatal(err)
@@ -95,3 +107,30 @@ func TestCgroupCtrl(t *testing.T) {
 		t.Fatal("Expected value=2G, obtained:", v)
 	}
 }
+
+func TestCgroupExists(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mn-cgroup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	root := CgroupRoot
+	CgroupRoot = dir
+	defer func() { CgroupRoot = root }()
+
+	if err := os.MkdirAll(filepath.Join(dir, "cpu", "g1"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	cg := &Cgroup{Name: "g1", Controllers: []Controller{{Name: "cpu"}}}
+	if !cg.Exists() {
+		t.Fatal("Expected g1 to exist")
+	}
+
+	// it's missing in the memory hierarchy
+	cg.Controllers = append(cg.Controllers, Controller{Name: "memory"})
+	if cg.Exists() || cg.Cgroup != nil {
+		t.Fatal("Expected g1 not to exist and not to be attached")
+	}
+}
@@ -0,0 +1,176 @@
+package mn
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"text/tabwriter"
+	"time"
+)
+
+// SetLinkState sets the node's link and its peer down or up, state of
+// both links is updated in the scheme. Peer, which isn't in the scheme,
+// e.g. root end of the control interface, is left as is.
+func (s *Scheme) SetLinkState(node, ifname, state string) error {
+	l, err := s.link(node, ifname)
+	if err != nil {
+		return err
+	}
+
+	pr := Pair{Left: *l, Right: Link{patch: l.patch}}
+
+	peer, err := s.link(l.Peer.NodeName, l.Peer.IfName)
+	if err == nil {
+		pr.Right = *peer
+	}
+
+	switch state {
+	case "DOWN":
+		pr, err = pr.down(peer != nil)
+	case "UP":
+		pr, err = pr.restore(peer != nil)
+	default:
+		return fmt.Errorf("Unknown link state %s, expected UP or DOWN", state)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	*l = pr.Left
+	if peer != nil {
+		*peer = pr.Right
+	}
+
+	return nil
+}
+
+func (pr Pair) down(both bool) (Pair, error) {
+	if both {
+		return pr.Down()
+	}
+
+	if pr.Left.patch {
+		return pr, fmt.Errorf("Patch port %s can't be set down", pr.Left.Name)
+	}
+
+	if err := pr.Left.Down(); err != nil {
+		return pr, err
+	}
+
+	pr.Left = pr.Left.SetState("DOWN")
+
+	return pr, nil
+}
+
+func (pr Pair) restore(both bool) (Pair, error) {
+	if both {
+		return pr.Restore()
+	}
+
+	if err := pr.Left.Up(); err != nil {
+		return pr, err
+	}
+
+	pr.Left = pr.Left.SetState("UP")
+
+	for _, route := range pr.Left.Routes {
+		if err := pr.Left.route("replace", route); err != nil {
+			return pr, err
+		}
+	}
+
+	return pr, nil
+}
+
+// ChaosEvent is a link flap: the link is set down At since the start
+// of the chaos and it's set up again after Down
+type ChaosEvent struct {
+	At   time.Duration
+	Node string
+	Link string
+	Down time.Duration
+}
+
+// ChaosSchedule is a list of link flaps ordered by time
+type ChaosSchedule []ChaosEvent
+
+// String prints schedule as a table
+func (cs ChaosSchedule) String() string {
+	buf := &bytes.Buffer{}
+
+	w := tabwriter.NewWriter(buf, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "AT\tNODE\tLINK\tDOWN")
+	for _, e := range cs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", e.At, e.Node, e.Link, e.Down)
+	}
+	w.Flush()
+
+	return buf.String()
+}
+
+// NewChaosSchedule flaps a random link every interval during duration,
+// the link is down from a quarter to three quarters of the interval, so
+// flaps don't overlap. Links are hosts' namespaced ones, the same seed
+// gives the same schedule for the same scheme.
+func (s Scheme) NewChaosSchedule(seed int64, duration, interval time.Duration) (ChaosSchedule, error) {
+	if interval <= 0 || duration < interval {
+		return nil, fmt.Errorf("Wrong chaos interval %s, it should be positive and not longer than duration %s", interval, duration)
+	}
+
+	candidates := make([]Link, 0)
+	for _, h := range s.Hosts {
+		for _, l := range h.Links {
+			if l.NetNs != "" && !l.patch {
+				candidates = append(candidates, l)
+			}
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("No links to flap")
+	}
+
+	r := rand.New(rand.NewSource(seed))
+	result := make(ChaosSchedule, 0)
+
+	for at := time.Duration(0); at+interval <= duration; at += interval {
+		l := candidates[r.Intn(len(candidates))]
+		down := interval/4 + time.Duration(r.Int63n(int64(interval/2)+1))
+
+		result = append(result, ChaosEvent{At: at, Node: l.NodeName, Link: l.Name, Down: down.Round(time.Millisecond)})
+	}
+
+	return result, nil
+}
+
+// Chaos runs the schedule, it blocks until the schedule is done or stop
+// is closed. Flapped link is always set up again.
+func (s *Scheme) Chaos(schedule ChaosSchedule, stop <-chan struct{}) error {
+	start := time.Now()
+
+	for _, e := range schedule {
+		select {
+		case <-stop:
+			return nil
+		case <-time.After(time.Until(start.Add(e.At))):
+		}
+
+		fmt.Println("[Chaos]", time.Since(start).Round(time.Millisecond), e.Node, e.Link, "down for", e.Down)
+
+		if err := s.SetLinkState(e.Node, e.Link, "DOWN"); err != nil {
+			return err
+		}
+
+		select {
+		case <-stop:
+		case <-time.After(e.Down):
+		}
+
+		if err := s.SetLinkState(e.Node, e.Link, "UP"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package mn
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSetLinkState(t *testing.T) {
+	fake := NewRecordingExecutor()
+	scheme := newFakeScheme(fake)
+
+	if err := scheme.SetLinkState("h1", "eth1", "DOWN"); err != nil {
+		t.Fatal(err)
+	}
+
+	h1, _ := scheme.GetHost("h1")
+	h2, _ := scheme.GetHost("h2")
+
+	if h1.Links[1].State != "DOWN" || h2.Links[0].State != "DOWN" {
+		t.Fatalf("Unexpected states: %s, %s", h1.Links[1].State, h2.Links[0].State)
+	}
+
+	if err := scheme.SetLinkState("h2", "eth0", "UP"); err != nil {
+		t.Fatal(err)
+	}
+
+	if h1.Links[1].State != "UP" || h2.Links[0].State != "UP" {
+		t.Fatalf("Unexpected states: %s, %s", h1.Links[1].State, h2.Links[0].State)
+	}
+
+	err := fake.Verify(
+		"ip netns exec h1 ip link set eth1 down",
+		"ip netns exec h2 ip link set eth0 down",
+		"ip netns exec h2 ip link set eth0 up",
+		"ip netns exec h1 ip link set eth1 up",
+		"ip netns exec h2 ip route replace 10.0.0.0/24 via 10.1.0.1 dev eth0",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := scheme.SetLinkState("h1", "eth1", "LOWERLAYERDOWN"); err == nil {
+		t.Fatal("Expected error for unknown state")
+	}
+}
+
+func TestChaos(t *testing.T) {
+	fake := NewRecordingExecutor()
+	scheme := newFakeScheme(fake)
+
+	schedule, err := scheme.NewChaosSchedule(42, 40*time.Millisecond, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(schedule) != 4 {
+		t.Fatalf("Expected 4 events, obtained:\n%s", schedule)
+	}
+
+	for i, e := range schedule {
+		if e.At != time.Duration(i)*10*time.Millisecond || e.Down < 2*time.Millisecond || e.Down > 8*time.Millisecond {
+			t.Fatalf("Unexpected event #%d:\n%s", i, schedule)
+		}
+
+		if e.Node != "h1" && e.Node != "h2" {
+			t.Fatalf("Unexpected node of event #%d:\n%s", i, schedule)
+		}
+	}
+
+	same, _ := scheme.NewChaosSchedule(42, 40*time.Millisecond, 10*time.Millisecond)
+	if !reflect.DeepEqual(schedule, same) {
+		t.Fatalf("Schedules of the same seed differ:\n%s\n%s", schedule, same)
+	}
+
+	if err := scheme.Chaos(schedule, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// fake links are created down, flapped ones are up after the chaos
+	for _, e := range schedule {
+		if l, _ := scheme.link(e.Node, e.Link); l.State != "UP" {
+			t.Fatalf("Link %s of %s is left %s", e.Link, e.Node, l.State)
+		}
+	}
+
+	if _, err := scheme.NewChaosSchedule(42, time.Second, 0); err == nil {
+		t.Fatal("Expected error for zero interval")
+	}
+}
@@ -0,0 +1,88 @@
+package mn
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Cleanup removes bridges, veths and network namespaces owned by the scheme's
+// topology, including ones left by crashed runs. Processes running inside
+// owned namespaces are killed. Resources of other topologies and everything
+// not created by mininet are untouched.
+func (s *Scheme) Cleanup() error {
+	return cleanup(s.exec, s.Topology)
+}
+
+// Cleanup removes resources owned by the topology, see Scheme.Cleanup
+func Cleanup(id string) error {
+	return cleanup(nil, id)
+}
+
+func cleanup(e Executor, id string) error {
+	run := executorOrDefault(e).Run
+	errs := make([]string, 0)
+	netnses := ownedNetns(id)
+
+	fail := func(err error) {
+		log.Println(err)
+		errs = append(errs, err.Error())
+	}
+
+	for _, name := range netnses {
+		out, err := run("ip", "netns", "pids", name)
+		if err != nil {
+			continue
+		}
+
+		for _, pid := range strings.Fields(out) {
+			if out, err := run("kill", "-9", pid); err != nil {
+				fail(fmt.Errorf("Unable to kill %s in %s, error: %v, output: %s", pid, name, err, out))
+			}
+		}
+	}
+
+	out, err := run("ovs-vsctl", "--bare", "--columns=name", "find", "bridge", "external_ids:"+topologyKey+"="+topologyOrDefault(id))
+	if err != nil {
+		fail(fmt.Errorf("Unable to find bridges, error: %v, output: %s", err, out))
+	}
+
+	for _, name := range strings.Fields(out) {
+		if out, err := run("ovs-vsctl", "--if-exists", "del-br", name); err != nil {
+			fail(fmt.Errorf("Unable to delete bridge %s, error: %v, output: %s", name, err, out))
+		}
+	}
+
+	// veths inside namespaces are deleted by kernel together with netns
+	backend := backendFor(e)
+
+	links, err := backend.ListByAlias("", linkAlias(id))
+	if err != nil {
+		fail(fmt.Errorf("Unable to list links, error: %v", err))
+	}
+
+	for _, name := range links {
+		if err := backend.Delete(Link{Name: name}); err != nil {
+			fail(err)
+		}
+	}
+
+	for _, name := range netnses {
+		ns := &NetNs{name: name, exec: e}
+
+		if !ns.Exists() {
+			delNetnsOwner(name)
+			continue
+		}
+
+		if err := ns.Release(); err != nil {
+			fail(err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package mn
+
+import (
+	"testing"
+)
+
+func TestCleanup(t *testing.T) {
+	links := "1: lo: <LOOPBACK,UP,LOWER_UP> mtu 65536 qdisc noqueue state UNKNOWN\\    link/loopback 00:00:00:00:00:00 brd 00:00:00:00:00:00\n" +
+		"7: h1-eth0@if6: <BROADCAST,MULTICAST,UP,LOWER_UP> mtu 1500\\    link/ether 02:00:00:00:01:01 brd ff:ff:ff:ff:ff:ff link-netns h1\\    alias mn:lab\n" +
+		"9: docker-veth@if8: <BROADCAST,MULTICAST,UP,LOWER_UP> mtu 1500\\    link/ether 02:00:00:00:03:01 brd ff:ff:ff:ff:ff:ff\\    alias mn:other\n"
+
+	fake := NewRecordingExecutor().
+		On("ip netns list", "h1\nh3\n", nil).
+		On("ip netns pids h1", "1234\n", nil).
+		On("ovs-vsctl --bare --columns=name find bridge", "s1\n", nil).
+		On("ip -o link show", links, nil)
+
+	scheme := newFakeScheme(fake).SetTopology("lab")
+
+	for _, name := range []string{"h1", "h2"} {
+		if err := setNetnsOwner(name, "lab"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := setNetnsOwner("h3", "other"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := scheme.Cleanup(); err != nil {
+		t.Fatal(err)
+	}
+
+	// h2 is left by crashed run, netns itself is already gone
+	err := fake.Verify(
+		"ip netns pids h1",
+		"kill -9 1234",
+		"ip netns pids h2",
+		"ovs-vsctl --bare --columns=name find bridge external_ids:mn-topology=lab",
+		"ovs-vsctl --if-exists del-br s1",
+		"ip -o link show",
+		"ip link delete h1-eth0",
+		"ip netns list",
+		"ip netns delete h1",
+		"ip netns list",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if owned := ownedNetns("lab"); len(owned) != 0 {
+		t.Fatal("Expected no netns owned by lab, obtained:", owned)
+	}
+
+	if owned := ownedNetns("other"); len(owned) != 1 || owned[0] != "h3" {
+		t.Fatal("Expected h3 owned by other, obtained:", owned)
+	}
+
+	delNetnsOwner("h3")
+}
+
+func TestNewNodesOwner(t *testing.T) {
+	fake := NewRecordingExecutor()
+
+	// nodes are created by "new host" and "new switch" before they're added
+	h := &Host{Name: "h9", netns: &NetNs{name: "h9", exec: fake}, Links: make(Links, 0)}
+	if err := h.NetNs().Create(); err != nil {
+		t.Fatal(err)
+	}
+	defer delNetnsOwner("h9")
+
+	sw := &Switch{Name: "s9", Ports: make(Links, 0), exec: fake}
+	if err := sw.Create(); err != nil {
+		t.Fatal(err)
+	}
+
+	NewScheme().SetTopology("lab").AddNode(h).AddNode(sw)
+
+	if owned := ownedNetns("lab"); len(owned) != 1 || owned[0] != "h9" {
+		t.Fatal("Expected h9 owned by lab, obtained:", owned)
+	}
+
+	err := fake.Verify(
+		"ip netns add h9",
+		"ovs-vsctl add-br s9 -- set bridge s9 external_ids:mn-topology=mininet",
+		"ovs-vsctl set bridge s9 external_ids:mn-topology=lab",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
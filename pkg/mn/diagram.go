@@ -0,0 +1,160 @@
+package mn
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Diagram formats
+const (
+	FormatDOT     = "dot"
+	FormatMermaid = "mermaid"
+)
+
+// edge is a pair of the scheme's links, patch is a switch to switch one
+type edge struct {
+	left  Link
+	right Link
+	patch bool
+}
+
+// DOT renders the scheme as a Graphviz graph: switches are boxes, hosts are
+// ellipses and routers (hosts with multiple links) are octagons. Veth links
+// are labeled by interface names, CIDRs and MACs, patch links are dashed.
+func (s Scheme) DOT() string {
+	buf := &bytes.Buffer{}
+
+	fmt.Fprintf(buf, "graph %q {\n", topologyOrDefault(s.Topology))
+	fmt.Fprintln(buf, "    node [fontname=\"monospace\"];")
+	fmt.Fprintln(buf, "    edge [fontname=\"monospace\", fontsize=10];")
+
+	for _, sw := range s.Switches {
+		fmt.Fprintf(buf, "    %q [shape=box, label=%q];\n", sw.Name, sw.Name+controllerLabel(sw, "\n"))
+	}
+
+	for _, h := range s.Hosts {
+		if isRouter(h) {
+			fmt.Fprintf(buf, "    %q [shape=octagon, label=%q];\n", h.Name, h.Name+"\nrouter")
+			continue
+		}
+
+		fmt.Fprintf(buf, "    %q [shape=ellipse];\n", h.Name)
+	}
+
+	for _, e := range s.edges() {
+		style := ""
+		if e.patch {
+			style = "style=dashed, label=\"patch\", "
+		}
+
+		fmt.Fprintf(buf, "    %q -- %q [%staillabel=%q, headlabel=%q];\n",
+			e.left.NodeName, e.right.NodeName, style, linkLabel(e.left, "\n"), linkLabel(e.right, "\n"))
+	}
+
+	fmt.Fprintln(buf, "}")
+
+	return buf.String()
+}
+
+// Mermaid renders the scheme as a Mermaid flowchart, shapes and labels
+// are the same as DOT ones
+func (s Scheme) Mermaid() string {
+	buf := &bytes.Buffer{}
+	ids := make(map[string]string)
+
+	// node names could contain characters, which aren't allowed in ids
+	id := func(name string) string {
+		if _, found := ids[name]; !found {
+			ids[name] = fmt.Sprintf("n%d", len(ids))
+		}
+
+		return ids[name]
+	}
+
+	fmt.Fprintln(buf, "graph LR")
+
+	for _, sw := range s.Switches {
+		fmt.Fprintf(buf, "    %s[\"%s\"]\n", id(sw.Name), mermaidEscape(sw.Name)+controllerLabel(sw, "<br/>"))
+	}
+
+	for _, h := range s.Hosts {
+		if isRouter(h) {
+			fmt.Fprintf(buf, "    %s{{\"%s<br/>router\"}}\n", id(h.Name), mermaidEscape(h.Name))
+			continue
+		}
+
+		fmt.Fprintf(buf, "    %s([\"%s\"])\n", id(h.Name), mermaidEscape(h.Name))
+	}
+
+	for _, e := range s.edges() {
+		label := mermaidEscape(linkLabel(e.left, " ")) + " &lt;-&gt; " + mermaidEscape(linkLabel(e.right, " "))
+
+		if e.patch {
+			fmt.Fprintf(buf, "    %s -.-|\"patch<br/>%s\"| %s\n", id(e.left.NodeName), label, id(e.right.NodeName))
+			continue
+		}
+
+		fmt.Fprintf(buf, "    %s ---|\"%s\"| %s\n", id(e.left.NodeName), label, id(e.right.NodeName))
+	}
+
+	return buf.String()
+}
+
+// edges returns every pair of the scheme once, links with unknown peers are skipped
+func (s Scheme) edges() []edge {
+	result := make([]edge, 0)
+	seen := make(map[string]bool)
+
+	for node := range s.Nodes() {
+		_, isSwitch := node.(*Switch)
+
+		for _, l := range node.GetLinks() {
+			peer, found := s.GetNode(l.Peer.NodeName)
+			if !found {
+				continue
+			}
+
+			pl := peer.GetLinks().LinkByPeer(l.Peer)
+			if pl.NodeName == "" || seen[pairHash(l, pl)] {
+				continue
+			}
+
+			seen[pairHash(l, pl)] = true
+
+			_, peerSwitch := peer.(*Switch)
+			result = append(result, edge{left: l, right: pl, patch: isSwitch && peerSwitch})
+		}
+	}
+
+	return result
+}
+
+// linkLabel is interface name, addresses and MAC of the link joined by sep
+func linkLabel(l Link, sep string) string {
+	parts := []string{l.Name}
+
+	parts = append(parts, l.Cidrs()...)
+
+	if l.HwAddr != "" {
+		parts = append(parts, l.HwAddr)
+	}
+
+	return strings.Join(parts, sep)
+}
+
+func controllerLabel(sw *Switch, sep string) string {
+	if sw.Controller == "" {
+		return ""
+	}
+
+	return sep + sw.Controller
+}
+
+func isRouter(h *Host) bool {
+	return len(h.Links) > 1
+}
+
+func mermaidEscape(s string) string {
+	return strings.NewReplacer("\"", "#quot;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
@@ -0,0 +1,49 @@
+package mn
+
+import (
+	"testing"
+)
+
+func TestDiagrams(t *testing.T) {
+	scheme := newFakeScheme(NewRecordingExecutor())
+
+	s2 := newFakeSwitch("s2", nil)
+	s1, _ := scheme.GetSwitch("s1")
+	s1.Controller = "tcp:127.0.0.1:6633"
+
+	pr := NewLink(s1, s2)
+	s1.Ports = append(s1.Ports, pr.Left)
+	s2.Ports = append(s2.Ports, pr.Right)
+	scheme.AddNode(s2)
+
+	expected := `graph "mininet" {
+    node [fontname="monospace"];
+    edge [fontname="monospace", fontsize=10];
+    "s1" [shape=box, label="s1\ntcp:127.0.0.1:6633"];
+    "s2" [shape=box, label="s2"];
+    "h1" [shape=octagon, label="h1\nrouter"];
+    "h2" [shape=ellipse];
+    "s1" -- "h1" [taillabel="h1-eth0\n02:00:00:00:01:01", headlabel="veth0\n10.0.0.1/24\n02:00:00:00:01:02"];
+    "s1" -- "s2" [style=dashed, label="patch", taillabel="s1-pp1", headlabel="s2-pp0"];
+    "h1" -- "h2" [taillabel="eth1\n10.1.0.1/24\n02:00:00:00:02:01", headlabel="eth0\n10.1.0.2/24\n02:00:00:00:02:02"];
+}
+`
+
+	if obtained, _ := scheme.ExportAs(FormatDOT); obtained != expected {
+		t.Fatalf("\nExpected:\n%s\nObtained:\n%s", expected, obtained)
+	}
+
+	expected = `graph LR
+    n0["s1<br/>tcp:127.0.0.1:6633"]
+    n1["s2"]
+    n2{{"h1<br/>router"}}
+    n3(["h2"])
+    n0 ---|"h1-eth0 02:00:00:00:01:01 &lt;-&gt; veth0 10.0.0.1/24 02:00:00:00:01:02"| n2
+    n0 -.-|"patch<br/>s1-pp1 &lt;-&gt; s2-pp0"| n1
+    n2 ---|"eth1 10.1.0.1/24 02:00:00:00:02:01 &lt;-&gt; eth0 10.1.0.2/24 02:00:00:00:02:02"| n3
+`
+
+	if obtained, _ := scheme.ExportAs(FormatMermaid); obtained != expected {
+		t.Fatalf("\nExpected:\n%s\nObtained:\n%s", expected, obtained)
+	}
+}
@@ -0,0 +1,377 @@
+package mn
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ipLink is an interface from "ip -j -d addr show" output
+type ipLink struct {
+	Index       int    `json:"ifindex"`
+	Name        string `json:"ifname"`
+	PeerIndex   int    `json:"link_index"`
+	PeerNetnsID *int   `json:"link_netnsid"`
+	OperState   string `json:"operstate"`
+	Address     string `json:"address"`
+	LinkInfo    struct {
+		Kind string `json:"info_kind"`
+	} `json:"linkinfo"`
+	AddrInfo []struct {
+		Family    string `json:"family"`
+		Local     string `json:"local"`
+		PrefixLen int    `json:"prefixlen"`
+		Scope     string `json:"scope"`
+		Dynamic   bool   `json:"dynamic"`
+	} `json:"addr_info"`
+
+	netns string
+}
+
+// ipRoute is a route from "ip -j route show" output
+type ipRoute struct {
+	Dst      string `json:"dst"`
+	Gateway  string `json:"gateway"`
+	Dev      string `json:"dev"`
+	Protocol string `json:"protocol"`
+}
+
+// Discover builds the scheme from the live system, instead of the structs
+// in memory. Network namespaces of /var/run/netns are hosts, their veths
+// with IPv4 and IPv6 addresses and routes via gateways are links, addresses
+// and routes learned from router advertisements are skipped. OVS bridges are switches,
+// with controllers, veth and patch ports. Peers are matched by interface
+// indexes. Root namespace veths, which aren't OVS ports, don't belong to any
+// node, so links to them have no peer. Optional executor runs the commands.
+func Discover(e ...Executor) (*Scheme, error) {
+	scheme := NewScheme()
+
+	if len(e) > 0 {
+		scheme.SetExecutor(e[0])
+	}
+
+	run := executorOrDefault(scheme.exec).Run
+
+	out, err := run("ip", "netns", "list")
+	if err != nil {
+		return nil, fmt.Errorf("Unable to list netns, error: %v, output: %s", err, out)
+	}
+
+	// "h1 (id: 0)"
+	netnses := make([]string, 0)
+
+	for _, line := range strings.Split(out, "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			netnses = append(netnses, fields[0])
+		}
+	}
+
+	sort.Strings(netnses)
+
+	all, err := discoverLinks(run, "")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range netnses {
+		links, err := discoverLinks(run, name)
+		if err != nil {
+			return nil, err
+		}
+
+		all = append(all, links...)
+	}
+
+	ports, err := discoverPorts(run)
+	if err != nil {
+		return nil, err
+	}
+
+	// root namespace interface name to the bridge
+	portOwner := make(map[string]string)
+
+	for _, br := range ports.bridges {
+		for _, port := range ports.ports[br] {
+			portOwner[port] = br
+		}
+	}
+
+	// peer of the interface in the scheme's terms
+	peerOf := func(l ipLink) Peer {
+		p, found := findPeer(all, l)
+		if !found {
+			return Peer{}
+		}
+
+		if p.netns != "" {
+			return Peer{Name: p.Name, IfName: p.Name, NodeName: p.netns}
+		}
+
+		if br, found := portOwner[p.Name]; found {
+			return Peer{Name: p.Name, IfName: p.Name, NodeName: br}
+		}
+
+		return Peer{}
+	}
+
+	for _, br := range ports.bridges {
+		sw := &Switch{Name: br, Ports: make(Links, 0), Controller: ports.controllers[br]}
+
+		for _, name := range ports.ports[br] {
+			if peer, isPatch := ports.patches[name]; isPatch {
+				l := Link{Name: name, NodeName: br, Peer: Peer{Name: peer, IfName: peer, NodeName: portOwner[peer]}}
+				sw.Ports = append(sw.Ports, l.SetPatch().SetState("UP"))
+				continue
+			}
+
+			l, found := linkByName(all, "", name)
+			if !found {
+				// internal ports, tunnels, etc
+				continue
+			}
+
+			sw.Ports = append(sw.Ports, discoveredLink(l, br, nil, peerOf(l)))
+		}
+
+		scheme.AddNode(sw)
+	}
+
+	for _, name := range netnses {
+		routes, err := discoverRoutes(run, name)
+		if err != nil {
+			return nil, err
+		}
+
+		h := &Host{Name: name, netns: &NetNs{name: name}, Links: make(Links, 0)}
+
+		for _, l := range all {
+			if l.netns != name || l.LinkInfo.Kind != "veth" {
+				continue
+			}
+
+			h.Links = append(h.Links, discoveredLink(l, name, routes, peerOf(l)))
+		}
+
+		scheme.AddNode(h)
+	}
+
+	return scheme, nil
+}
+
+// discoverLinks returns interfaces of the netns with addresses, empty name is the root one
+func discoverLinks(run func(string, ...string) (string, error), netns string) ([]ipLink, error) {
+	command := netnsCommand(netns, "ip", "-j", "-d", "addr", "show")
+
+	out, err := run(command[0], command[1:]...)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to list links of %s, error: %v, output: %s", netnsOrRoot(netns), err, out)
+	}
+
+	links := make([]ipLink, 0)
+
+	if strings.TrimSpace(out) == "" {
+		return links, nil
+	}
+
+	if err := json.Unmarshal([]byte(out), &links); err != nil {
+		return nil, fmt.Errorf("Unable to parse links of %s, error: %v", netnsOrRoot(netns), err)
+	}
+
+	for i := range links {
+		links[i].netns = netns
+	}
+
+	return links, nil
+}
+
+// discoverRoutes returns IPv4 and IPv6 routes of the netns
+func discoverRoutes(run func(string, ...string) (string, error), netns string) ([]ipRoute, error) {
+	result := make([]ipRoute, 0)
+
+	for _, family := range []string{"-4", "-6"} {
+		command := netnsCommand(netns, "ip", "-j", family, "route", "show")
+
+		out, err := run(command[0], command[1:]...)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to list routes of %s, error: %v, output: %s", netnsOrRoot(netns), err, out)
+		}
+
+		if strings.TrimSpace(out) == "" {
+			continue
+		}
+
+		routes := make([]ipRoute, 0)
+		if err := json.Unmarshal([]byte(out), &routes); err != nil {
+			return nil, fmt.Errorf("Unable to parse routes of %s, error: %v", netnsOrRoot(netns), err)
+		}
+
+		result = append(result, routes...)
+	}
+
+	return result, nil
+}
+
+// ovsPorts is OVS configuration: bridges with ports and controllers,
+// patch ports with their peers
+type ovsPorts struct {
+	bridges     []string
+	ports       map[string][]string
+	controllers map[string]string
+	patches     map[string]string
+}
+
+func discoverPorts(run func(string, ...string) (string, error)) (ovsPorts, error) {
+	result := ovsPorts{
+		ports:       make(map[string][]string),
+		controllers: make(map[string]string),
+		patches:     make(map[string]string),
+	}
+
+	out, err := run("ovs-vsctl", "list-br")
+	if err != nil {
+		return result, fmt.Errorf("Unable to list bridges, error: %v, output: %s", err, out)
+	}
+
+	result.bridges = strings.Fields(out)
+
+	for _, br := range result.bridges {
+		out, err := run("ovs-vsctl", "list-ports", br)
+		if err != nil {
+			return result, fmt.Errorf("Unable to list ports of %s, error: %v, output: %s", br, err, out)
+		}
+
+		result.ports[br] = strings.Fields(out)
+
+		out, err = run("ovs-vsctl", "get-controller", br)
+		if err != nil {
+			return result, fmt.Errorf("Unable to get controller of %s, error: %v, output: %s", br, err, out)
+		}
+
+		// only the first one, scheme has the single controller
+		if fields := strings.Fields(out); len(fields) > 0 {
+			result.controllers[br] = fields[0]
+		}
+
+		for _, port := range result.ports[br] {
+			out, err := run("ovs-vsctl", "get", "interface", port, "type")
+			if err != nil || ovsValue(out) != "patch" {
+				continue
+			}
+
+			out, err = run("ovs-vsctl", "get", "interface", port, "options:peer")
+			if err != nil {
+				return result, fmt.Errorf("Unable to get peer of %s, error: %v, output: %s", port, err, out)
+			}
+
+			result.patches[port] = ovsValue(out)
+		}
+	}
+
+	return result, nil
+}
+
+// discoveredLink converts the interface into the node's link, routes
+// via gateways are attached to the link they go through
+func discoveredLink(l ipLink, node string, routes []ipRoute, peer Peer) Link {
+	link := Link{
+		Name:     l.Name,
+		NodeName: node,
+		NetNs:    l.netns,
+		Cidr:     noip,
+		HwAddr:   l.Address,
+		State:    "DOWN",
+		Peer:     peer,
+	}
+
+	if l.OperState == "UP" {
+		link.State = "UP"
+	}
+
+	// IPv4 address is the primary one, unless there are only IPv6 ones
+	for _, family := range []string{"inet", "inet6"} {
+		for _, addr := range l.AddrInfo {
+			if addr.Family != family || addr.Scope != "global" || addr.Dynamic {
+				continue
+			}
+
+			cidr := addr.Local + "/" + strconv.Itoa(addr.PrefixLen)
+
+			if link.Cidr == noip {
+				link.Cidr = cidr
+				continue
+			}
+
+			link.Addrs = append(link.Addrs, cidr)
+		}
+	}
+
+	for _, r := range routes {
+		if r.Dev != l.Name || r.Gateway == "" || r.Protocol == "ra" {
+			continue
+		}
+
+		if r.Dst == "default" {
+			r.Dst = defaultDst(r.Gateway)
+		}
+
+		link.Routes = append(link.Routes, Route{Dst: r.Dst, Gw: r.Gateway})
+	}
+
+	return link
+}
+
+// findPeer returns the other end of the veth. Peer's link_index is the
+// interface's index and vice versa, peer is in the same netns, unless
+// link_netnsid is set.
+func findPeer(all []ipLink, l ipLink) (ipLink, bool) {
+	if l.LinkInfo.Kind != "veth" || l.PeerIndex == 0 {
+		return ipLink{}, false
+	}
+
+	var (
+		result ipLink
+		found  int
+	)
+
+	for _, p := range all {
+		if p.Index != l.PeerIndex || p.PeerIndex != l.Index || p.LinkInfo.Kind != "veth" {
+			continue
+		}
+
+		if (l.PeerNetnsID == nil) != (p.netns == l.netns) {
+			continue
+		}
+
+		result = p
+		found++
+	}
+
+	// indexes are per netns, so the match could be ambiguous
+	return result, found == 1
+}
+
+func linkByName(all []ipLink, netns, name string) (ipLink, bool) {
+	for _, l := range all {
+		if l.netns == netns && l.Name == name {
+			return l, true
+		}
+	}
+
+	return ipLink{}, false
+}
+
+// netnsCommand prepends "ip netns exec" to the command, unless netns is the root one
+func netnsCommand(netns string, command ...string) []string {
+	if netns == "" {
+		return command
+	}
+
+	return append([]string{"ip", "netns", "exec", netns}, command...)
+}
+
+// ovsValue unquotes ovs-vsctl get output
+func ovsValue(out string) string {
+	return strings.Trim(strings.TrimSpace(out), "\"")
+}
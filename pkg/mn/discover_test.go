@@ -0,0 +1,86 @@
+package mn
+
+import (
+	"reflect"
+	"testing"
+)
+
+// newFakeLive scripts live system of the fake scheme with drift: h2 has
+// default route and its link is down, s1 has controller and patch to s2
+func newFakeLive() *RecordingExecutor {
+	return NewRecordingExecutor().
+		On("ip netns list", "h2 (id: 1)\nh1 (id: 0)\n", nil).
+		On("ip -j -d addr show", `[
+    {"ifindex": 1, "ifname": "lo", "operstate": "UNKNOWN", "address": "00:00:00:00:00:00", "addr_info": [{"family": "inet", "local": "127.0.0.1", "prefixlen": 8, "scope": "host"}]},
+    {"ifindex": 5, "ifname": "h1-eth0", "link_index": 2, "link_netnsid": 0, "operstate": "UP", "address": "02:00:00:00:01:01", "linkinfo": {"info_kind": "veth"}, "addr_info": []},
+    {"ifindex": 6, "ifname": "ctrl0", "link_index": 7, "operstate": "UP", "address": "02:00:00:00:03:01", "linkinfo": {"info_kind": "veth"}, "addr_info": []}
+]`, nil).
+		On("ip netns exec h1 ip -j -d addr show", `[
+    {"ifindex": 1, "ifname": "lo", "operstate": "UNKNOWN", "address": "00:00:00:00:00:00", "addr_info": []},
+    {"ifindex": 2, "ifname": "veth0", "link_index": 5, "link_netnsid": 0, "operstate": "UP", "address": "02:00:00:00:01:02", "linkinfo": {"info_kind": "veth"},
+        "addr_info": [{"family": "inet", "local": "10.0.0.1", "prefixlen": 24, "scope": "global"}]},
+    {"ifindex": 3, "ifname": "eth1", "link_index": 2, "link_netnsid": 1, "operstate": "UP", "address": "02:00:00:00:02:01", "linkinfo": {"info_kind": "veth"},
+        "addr_info": [{"family": "inet", "local": "10.1.0.1", "prefixlen": 24, "scope": "global"}]}
+]`, nil).
+		On("ip netns exec h2 ip -j -d addr show", `[
+    {"ifindex": 2, "ifname": "eth0", "link_index": 3, "link_netnsid": 0, "operstate": "LOWERLAYERDOWN", "address": "02:00:00:00:02:02", "linkinfo": {"info_kind": "veth"},
+        "addr_info": [{"family": "inet", "local": "10.1.0.2", "prefixlen": 24, "scope": "global"}]}
+]`, nil).
+		On("ip netns exec h2 ip -j -4 route show", `[
+    {"dst": "default", "gateway": "10.1.0.1", "dev": "eth0"},
+    {"dst": "10.1.0.0/24", "dev": "eth0", "protocol": "kernel", "scope": "link", "prefsrc": "10.1.0.2"}
+]`, nil).
+		On("ovs-vsctl list-br", "s1\ns2\n", nil).
+		On("ovs-vsctl list-ports s1", "h1-eth0\ns1-pp1\n", nil).
+		On("ovs-vsctl list-ports s2", "s2-pp0\n", nil).
+		On("ovs-vsctl get-controller s1", "tcp:127.0.0.1:6653\n", nil).
+		On("ovs-vsctl get interface", "\"\"\n", nil).
+		On("ovs-vsctl get interface s1-pp1 type", "patch\n", nil).
+		On("ovs-vsctl get interface s2-pp0 type", "patch\n", nil).
+		On("ovs-vsctl get interface s1-pp1 options:peer", "\"s2-pp0\"\n", nil).
+		On("ovs-vsctl get interface s2-pp0 options:peer", "\"s1-pp1\"\n", nil)
+}
+
+func TestDiscover(t *testing.T) {
+	fake := newFakeLive()
+
+	scheme, err := Discover(fake)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := scheme.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	s1, _ := scheme.GetSwitch("s1")
+	s2, _ := scheme.GetSwitch("s2")
+	h1, _ := scheme.GetHost("h1")
+	h2, _ := scheme.GetHost("h2")
+
+	if s1.Controller != "tcp:127.0.0.1:6653" || s2.Controller != "" {
+		t.Fatalf("Unexpected controllers: %s, %s", s1.Controller, s2.Controller)
+	}
+
+	expected := Link{Name: "h1-eth0", NodeName: "s1", Cidr: noip, HwAddr: "02:00:00:00:01:01", State: "UP", Peer: Peer{Name: "veth0", IfName: "veth0", NodeName: "h1"}}
+	if port := s1.Ports[0]; !reflect.DeepEqual(port.SetExecutor(nil), expected) {
+		t.Fatalf("\nExpected: %v\nObtained: %v", expected, port)
+	}
+
+	if patch := s2.Ports[0]; !patch.patch || patch.Peer.NodeName != "s1" || patch.Peer.Name != "s1-pp1" {
+		t.Fatalf("Unexpected patch port: %v", patch)
+	}
+
+	if len(h1.Links) != 2 || h1.Links[0].Cidr != "10.0.0.1/24" || h1.Links[1].Peer.NodeName != "h2" {
+		t.Fatalf("Unexpected links of h1: %v", h1.Links)
+	}
+
+	expected = Link{
+		Name: "eth0", NodeName: "h2", NetNs: "h2", Cidr: "10.1.0.2/24", HwAddr: "02:00:00:00:02:02", State: "DOWN",
+		Routes: []Route{{Dst: "0.0.0.0/0", Gw: "10.1.0.1"}},
+		Peer:   Peer{Name: "eth1", IfName: "eth1", NodeName: "h1"},
+	}
+	if link := h2.Links[0]; !reflect.DeepEqual(link.SetExecutor(nil), expected) {
+		t.Fatalf("\nExpected: %v\nObtained: %v", expected, link)
+	}
+}
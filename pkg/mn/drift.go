@@ -0,0 +1,238 @@
+package mn
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+	"text/tabwriter"
+)
+
+// Discrepancy is a difference between the scheme and the live system
+type Discrepancy struct {
+	Node     string
+	Kind     string
+	Target   string
+	Declared string
+	Live     string
+}
+
+// String satisfies stringer interface
+func (d Discrepancy) String() string {
+	return fmt.Sprintf("%s %s %s: declared %s, live %s", d.Node, d.Kind, d.Target, d.Declared, d.Live)
+}
+
+// DriftReport is a list of discrepancies
+type DriftReport []Discrepancy
+
+// String prints report as a table
+func (r DriftReport) String() string {
+	buf := &bytes.Buffer{}
+
+	w := tabwriter.NewWriter(buf, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NODE\tKIND\tTARGET\tDECLARED\tLIVE")
+	for _, d := range r {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", d.Node, d.Kind, d.Target, d.Declared, d.Live)
+	}
+	w.Flush()
+
+	return buf.String()
+}
+
+const (
+	present = "present"
+	missing = "missing"
+)
+
+// Drift compares the scheme with the live system, see Discover. Hosts' links
+// are compared by IPv4 and IPv6 addresses, MACs, state, peers and routes, switches by ports
+// and controller, processes should be running in hosts' netns. Nodes of the
+// live system, which aren't in the scheme, are ignored, extra links and ports
+// of the scheme's nodes are reported. Host's links of the root namespace
+// aren't discovered, so they aren't compared.
+func (s Scheme) Drift() (DriftReport, error) {
+	live, err := Discover(s.exec)
+	if err != nil {
+		return nil, err
+	}
+
+	report := make(DriftReport, 0)
+
+	add := func(node, kind, target, declared, live string) {
+		report = append(report, Discrepancy{Node: node, Kind: kind, Target: target, Declared: declared, Live: live})
+	}
+
+	for _, sw := range s.Switches {
+		lsw, found := live.GetSwitch(sw.Name)
+		if !found {
+			add(sw.Name, "switch", sw.Name, present, missing)
+			continue
+		}
+
+		if sw.Controller != lsw.Controller {
+			add(sw.Name, "controller", sw.Name, valueOrNone(sw.Controller), valueOrNone(lsw.Controller))
+		}
+
+		for _, port := range sw.Ports {
+			lport, found := lsw.Ports.ByName(port.Name)
+			if !found {
+				add(sw.Name, "port", port.Name, present, missing)
+				continue
+			}
+
+			// veth peers are compared from the hosts' side
+			if port.patch && (port.Peer.NodeName != lport.Peer.NodeName || port.Peer.IfName != lport.Peer.IfName) {
+				add(sw.Name, "peer", port.Name, peerString(port.Peer), peerString(lport.Peer))
+			}
+		}
+
+		for _, lport := range lsw.Ports {
+			if _, found := sw.Ports.ByName(lport.Name); !found {
+				add(sw.Name, "port", lport.Name, missing, present)
+			}
+		}
+	}
+
+	for _, h := range s.Hosts {
+		lh, found := live.GetHost(h.Name)
+		if !found {
+			add(h.Name, "host", h.Name, present, missing)
+			continue
+		}
+
+		for _, l := range h.Links {
+			if l.NetNs == "" {
+				continue
+			}
+
+			ll, found := lh.Links.ByName(l.Name)
+			if !found {
+				add(h.Name, "link", l.Name, present, missing)
+				continue
+			}
+
+			for _, d := range linkDrift(l, ll) {
+				add(h.Name, d.Kind, d.Target, d.Declared, d.Live)
+			}
+		}
+
+		for _, ll := range lh.Links {
+			if _, found := h.Links.ByName(ll.Name); !found {
+				add(h.Name, "link", ll.Name, missing, present)
+			}
+		}
+
+		for _, p := range h.Procs {
+			if !p.alive(h.executor(), h.NetNs().Name()) {
+				add(h.Name, "process", p.CommandLine(), "running", "not running")
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// linkDrift compares declared link with the live one, the empty declared
+// MAC is set by kernel and the empty state isn't tracked yet, so they
+// aren't compared
+func linkDrift(l, ll Link) DriftReport {
+	report := make(DriftReport, 0)
+
+	add := func(kind, declared, live string) {
+		report = append(report, Discrepancy{Kind: kind, Target: l.Name, Declared: declared, Live: live})
+	}
+
+	if cidrOrNoip(l.Cidr) != ll.Cidr {
+		add("cidr", cidrOrNoip(l.Cidr), ll.Cidr)
+	}
+
+	for _, addr := range l.Addrs {
+		if !contains(ll.Cidrs(), addr) {
+			add("addr", addr, missing)
+		}
+	}
+
+	for _, addr := range ll.Addrs {
+		if !contains(l.Cidrs(), addr) {
+			add("addr", missing, addr)
+		}
+	}
+
+	if l.HwAddr != "" && !strings.EqualFold(l.HwAddr, ll.HwAddr) {
+		add("mac", l.HwAddr, ll.HwAddr)
+	}
+
+	if l.State != "" && l.State != ll.State {
+		add("state", l.State, ll.State)
+	}
+
+	if l.Peer.NodeName != ll.Peer.NodeName || l.Peer.IfName != ll.Peer.IfName {
+		add("peer", peerString(l.Peer), peerString(ll.Peer))
+	}
+
+	for _, r := range l.Routes {
+		if !hasLiveRoute(ll.Routes, r) {
+			add("route", routeString(r), missing)
+		}
+	}
+
+	for _, r := range ll.Routes {
+		if !hasLiveRoute(l.Routes, r) {
+			add("route", missing, routeString(r))
+		}
+	}
+
+	return report
+}
+
+// hasLiveRoute compares routes by destination network and gateway,
+// e.g. declared 10.0.0.1/24 is 10.0.0.0/24 in the routing table
+func hasLiveRoute(routes []Route, route Route) bool {
+	for _, r := range routes {
+		if routeNetwork(r.Dst) == routeNetwork(route.Dst) && r.Gw == route.Gw {
+			return true
+		}
+	}
+
+	return false
+}
+
+func routeNetwork(dst string) string {
+	if dst == "default" {
+		return "0.0.0.0/0"
+	}
+
+	if _, network, err := net.ParseCIDR(dst); err == nil {
+		return network.String()
+	}
+
+	return dst
+}
+
+func routeString(r Route) string {
+	return r.Dst + " via " + r.Gw
+}
+
+func peerString(p Peer) string {
+	if p.NodeName == "" {
+		return "none"
+	}
+
+	return p.NodeName + ":" + p.IfName
+}
+
+func cidrOrNoip(cidr string) string {
+	if _, _, err := net.ParseCIDR(cidr); err != nil {
+		return noip
+	}
+
+	return cidr
+}
+
+func valueOrNone(v string) string {
+	if v == "" {
+		return "none"
+	}
+
+	return v
+}
@@ -0,0 +1,226 @@
+package mn
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDrift(t *testing.T) {
+	fake := newFakeLive().
+		On("ps -A", "    1,/sbin/init\n", nil)
+
+	scheme := newFakeScheme(fake)
+
+	h1, _ := scheme.GetHost("h1")
+	h2, _ := scheme.GetHost("h2")
+
+	for _, links := range []Links{h1.Links, h2.Links} {
+		for i := range links {
+			links[i] = links[i].SetState("UP")
+		}
+	}
+
+	h1.Procs = append(h1.Procs, &Process{Command: "sleep", Args: []string{"100"}})
+
+	report, err := scheme.Drift()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := DriftReport{
+		{Node: "s1", Kind: "controller", Target: "s1", Declared: "none", Live: "tcp:127.0.0.1:6653"},
+		{Node: "s1", Kind: "port", Target: "s1-pp1", Declared: missing, Live: present},
+		{Node: "h1", Kind: "process", Target: "sleep 100", Declared: "running", Live: "not running"},
+		{Node: "h2", Kind: "state", Target: "eth0", Declared: "UP", Live: "DOWN"},
+		{Node: "h2", Kind: "route", Target: "eth0", Declared: "10.0.0.0/24 via 10.1.0.1", Live: missing},
+		{Node: "h2", Kind: "route", Target: "eth0", Declared: missing, Live: "0.0.0.0/0 via 10.1.0.1"},
+	}
+
+	if !reflect.DeepEqual(report, expected) {
+		t.Fatalf("\nExpected:\n%s\nObtained:\n%s", expected, report)
+	}
+
+	// no drift against itself
+	live, err := Discover(fake)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report, err = live.Drift(); err != nil || len(report) != 0 {
+		t.Fatalf("Unexpected drift: %v\n%s", err, report)
+	}
+}
+
+func TestDriftUntrackedState(t *testing.T) {
+	fake := newFakeLive().
+		On("ps -A", "    1,/sbin/init\n", nil)
+
+	// state of new links isn't tracked, live DOWN link isn't a drift
+	report, err := newFakeScheme(fake).Drift()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, d := range report {
+		if d.Kind == "state" {
+			t.Fatal("Unexpected state drift:", d)
+		}
+	}
+}
+
+// newAppliedLive scripts the live system, which has netns, veths, ports,
+// addresses and routes created by the recorded commands. MAC, which isn't
+// set, is a random one given by kernel.
+func newAppliedLive(calls []string) *RecordingExecutor {
+	live := NewRecordingExecutor()
+	links := make([]*ipLink, 0)
+	routes := make(map[string][]ipRoute)
+	netnses := []string{""}
+	bridges := make([]string, 0)
+	ports := make(map[string][]string)
+
+	find := func(netns, name string) *ipLink {
+		for _, l := range links {
+			if l.netns == netns && l.Name == name {
+				return l
+			}
+		}
+
+		return &ipLink{}
+	}
+
+	add := func(netns, name string) *ipLink {
+		l := &ipLink{Index: len(links) + 1, Name: name, OperState: "DOWN", Address: fmt.Sprintf("ee:00:00:00:00:%02x", len(links)+1), netns: netns}
+		l.LinkInfo.Kind = "veth"
+		links = append(links, l)
+
+		return l
+	}
+
+	for _, call := range calls {
+		f := strings.Fields(call)
+		netns := ""
+
+		if len(f) > 4 && f[1] == "netns" && f[2] == "exec" {
+			netns, f = f[3], f[4:]
+		}
+
+		if len(f) < 4 {
+			continue
+		}
+
+		switch strings.Join(f[:3], " ") {
+		case "ip netns add":
+			netnses = append(netnses, f[3])
+		case "ip link add":
+			// ip link add name A type veth peer name B netns N
+			left, right := add(netns, f[4]), add(f[11], f[9])
+			left.PeerIndex, right.PeerIndex = right.Index, left.Index
+		case "ip link set":
+			switch {
+			case len(f) == 5 && f[4] == "up":
+				find(netns, f[3]).OperState = "UP"
+			case len(f) == 6 && f[4] == "netns":
+				find(netns, f[3]).netns = f[5]
+			case len(f) == 7 && f[5] == "address":
+				find(netns, f[4]).Address = f[6]
+			}
+		case "ip addr add":
+			l := find(netns, f[5])
+			ip, ipnet, _ := net.ParseCIDR(f[3])
+			prefix, _ := ipnet.Mask.Size()
+			l.AddrInfo = append(l.AddrInfo, struct {
+				Family    string `json:"family"`
+				Local     string `json:"local"`
+				PrefixLen int    `json:"prefixlen"`
+				Scope     string `json:"scope"`
+				Dynamic   bool   `json:"dynamic"`
+			}{Family: "inet", Local: ip.String(), PrefixLen: prefix, Scope: "global"})
+		case "ip route add":
+			dst := f[3]
+			if dst == "0.0.0.0/0" {
+				dst = "default"
+			}
+
+			routes[netns] = append(routes[netns], ipRoute{Dst: dst, Gateway: f[5], Dev: f[7]})
+		}
+
+		switch {
+		case len(f) > 2 && f[1] == "add-br":
+			bridges = append(bridges, f[2])
+		case len(f) == 4 && f[1] == "add-port":
+			ports[f[2]] = append(ports[f[2]], f[3])
+		case len(f) == 4 && f[1] == "set-controller":
+			live.On("ovs-vsctl get-controller "+f[2], f[3], nil)
+		case len(f) == 5 && f[1] == "set" && f[4] == "type=patch":
+			live.On("ovs-vsctl get interface "+f[3]+" type", "patch", nil)
+		case len(f) == 5 && f[1] == "set" && strings.HasPrefix(f[4], "options:peer="):
+			live.On("ovs-vsctl get interface "+f[3]+" options:peer", strconv.Quote(strings.TrimPrefix(f[4], "options:peer=")), nil)
+		}
+	}
+
+	live.On("ip netns list", strings.Join(netnses[1:], "\n"), nil).
+		On("ovs-vsctl list-br", strings.Join(bridges, "\n"), nil)
+
+	for _, br := range bridges {
+		live.On("ovs-vsctl list-ports "+br, strings.Join(ports[br], "\n"), nil)
+	}
+
+	zero := 0
+
+	for _, netns := range netnses {
+		result := make([]ipLink, 0)
+		for _, l := range links {
+			if l.netns == netns {
+				if links[l.PeerIndex-1].netns != netns {
+					l.PeerNetnsID = &zero
+				}
+
+				result = append(result, *l)
+			}
+		}
+
+		out, _ := json.Marshal(result)
+		live.On(strings.Join(netnsCommand(netns, "ip", "-j", "-d", "addr", "show"), " "), string(out), nil)
+
+		out, _ = json.Marshal(routes[netns])
+		live.On(strings.Join(netnsCommand(netns, "ip", "-j", "-4", "route", "show"), " "), string(out), nil)
+	}
+
+	return live
+}
+
+func TestDriftOfAppliedScheme(t *testing.T) {
+	scheme, err := NewSchemeFromFile("../../cmd/schemes/l3-multi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake := NewRecordingExecutor().
+		On("ovs-vsctl br-exists", "", errors.New("exit status 2")).
+		On("ip link show", "", errors.New("exit status 1"))
+
+	for _, h := range scheme.Hosts {
+		fake.On("ip netns exec "+h.Name+" ip link show", "", errors.New("exit status 1"))
+	}
+
+	if err := scheme.SetExecutor(fake).Apply(); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := scheme.SetExecutor(newAppliedLive(fake.Calls())).Drift()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// everything declared is applied, including MACs
+	if len(report) != 0 {
+		t.Fatal("Unexpected drift:\n", report)
+	}
+}
@@ -8,6 +8,8 @@ import (
 )
 
 func TestTopoSimple(t *testing.T) {
+	requireSystem(t)
+
 	root, err := NewSwitch()
 	if err != nil {
 		t.Fatal(err)
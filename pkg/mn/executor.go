@@ -0,0 +1,38 @@
+package mn
+
+// Executor runs external commands (ip, ovs-vsctl, sysctl, etc)
+// on behalf of nodes, links and namespaces
+type Executor interface {
+	Run(cmd string, args ...string) (string, error)
+}
+
+// SystemExecutor runs commands on the local system
+type SystemExecutor struct{}
+
+// Run is a wrapper for RunCommand
+func (SystemExecutor) Run(cmd string, args ...string) (string, error) {
+	return RunCommand(cmd, args...)
+}
+
+// DefaultExecutor is used by everything, which has no executor set explicitly
+var DefaultExecutor Executor = SystemExecutor{}
+
+func executorOrDefault(e Executor) Executor {
+	if e == nil {
+		return DefaultExecutor
+	}
+
+	return e
+}
+
+// nodeExecutor returns executor explicitly set to the node or nil
+func nodeExecutor(n Node) Executor {
+	switch t := n.(type) {
+	case *Host:
+		return t.exec
+	case *Switch:
+		return t.exec
+	}
+
+	return nil
+}
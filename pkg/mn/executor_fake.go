@@ -0,0 +1,95 @@
+package mn
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// RecordingExecutor is an Executor for unit tests. It doesn't run anything,
+// just records every invocation and replies with scripted output.
+type RecordingExecutor struct {
+	mu      sync.Mutex
+	calls   []string
+	replies []reply
+}
+
+type reply struct {
+	prefix string
+	output string
+	err    error
+}
+
+// NewRecordingExecutor creates an instance of RecordingExecutor
+func NewRecordingExecutor() *RecordingExecutor {
+	return &RecordingExecutor{
+		calls:   make([]string, 0),
+		replies: make([]reply, 0),
+	}
+}
+
+// On scripts a reply for commands which start with prefix,
+// e.g. On("ovs-vsctl br-exists s1", "", errors.New("exit status 2")).
+// The latest matching reply wins. Unscripted commands return empty output and no error.
+func (r *RecordingExecutor) On(prefix string, output string, err error) *RecordingExecutor {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.replies = append(r.replies, reply{prefix: prefix, output: output, err: err})
+
+	return r
+}
+
+// Run records the command and returns scripted reply
+func (r *RecordingExecutor) Run(cmd string, args ...string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	line := strings.Join(append([]string{cmd}, args...), " ")
+	r.calls = append(r.calls, line)
+
+	for i := len(r.replies) - 1; i >= 0; i-- {
+		if strings.HasPrefix(line, r.replies[i].prefix) {
+			return r.replies[i].output, r.replies[i].err
+		}
+	}
+
+	return "", nil
+}
+
+// Calls returns recorded command lines
+func (r *RecordingExecutor) Calls() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]string{}, r.calls...)
+}
+
+// Reset forgets recorded commands, scripted replies stay
+func (r *RecordingExecutor) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.calls = make([]string, 0)
+}
+
+// Verify checks that exactly expected commands have been run in the same order
+func (r *RecordingExecutor) Verify(expected ...string) error {
+	calls := r.Calls()
+
+	for i := range expected {
+		if i >= len(calls) {
+			return fmt.Errorf("Expected command #%d %q, but only %d commands have been run", i, expected[i], len(calls))
+		}
+
+		if calls[i] != expected[i] {
+			return fmt.Errorf("Expected command #%d %q, obtained %q", i, expected[i], calls[i])
+		}
+	}
+
+	if len(calls) > len(expected) {
+		return fmt.Errorf("Unexpected commands: %q", calls[len(expected):])
+	}
+
+	return nil
+}
@@ -0,0 +1,246 @@
+package mn
+
+import (
+	"errors"
+	"testing"
+)
+
+// newFakeHost creates a host without touching the system
+func newFakeHost(name string, e Executor) *Host {
+	h := &Host{Name: name, netns: &NetNs{name: name}, Links: make(Links, 0)}
+	h.SetExecutor(e)
+
+	return h
+}
+
+// newFakeSwitch creates a switch without touching the system
+func newFakeSwitch(name string, e Executor) *Switch {
+	s := &Switch{Name: name, Ports: make(Links, 0)}
+	s.SetExecutor(e)
+
+	return s
+}
+
+func TestRecordingExecutor(t *testing.T) {
+	fake := NewRecordingExecutor().
+		On("ovs-vsctl", "", nil).
+		On("ovs-vsctl br-exists", "", errors.New("exit status 2"))
+
+	if _, err := fake.Run("ovs-vsctl", "br-exists", "s1"); err == nil {
+		t.Fatal("Expected scripted error")
+	}
+
+	if _, err := fake.Run("ovs-vsctl", "add-br", "s1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fake.Verify("ovs-vsctl br-exists s1", "ovs-vsctl add-br s1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fake.Verify("ovs-vsctl br-exists s1"); err == nil {
+		t.Fatal("Expected error for unexpected commands")
+	}
+
+	fake.Reset()
+
+	if err := fake.Verify(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSwitchCommands(t *testing.T) {
+	fake := NewRecordingExecutor()
+
+	s1 := newFakeSwitch("s1", fake)
+	s2 := newFakeSwitch("s2", fake)
+
+	if err := s1.Create(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s1.SetController("tcp:127.0.0.1:6633"); err != nil {
+		t.Fatal(err)
+	}
+
+	h1 := newFakeHost("h1", fake)
+
+	if err := s1.AddLink(NewLink(s1, h1, Link{Cidr: noip}, Link{Cidr: "10.0.0.2/24"}).Left); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewLink(s1, s2)
+
+	if err := s1.AddLink(p.Left); err != nil {
+		t.Fatal(err)
+	}
+
+	s1.Release()
+
+	err := fake.Verify(
+		"ovs-vsctl add-br s1 -- set bridge s1 external_ids:mn-topology=mininet",
+		"ovs-vsctl set-controller s1 tcp:127.0.0.1:6633",
+		"ovs-vsctl add-port s1 h1-eth0",
+		"ovs-vsctl add-port s1 s1-pp1",
+		"ovs-vsctl set interface s1-pp1 type=patch",
+		"ovs-vsctl set interface s1-pp1 options:peer=s2-pp0",
+		"ovs-vsctl del-br s1",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c := s1.LinksCount(); c != 2 {
+		t.Fatal("Expected 2 ports, obtained:", c)
+	}
+
+	// bridge with STP
+	fake.Reset()
+	s2.STP = true
+
+	if err := s2.Create(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fake.Verify("ovs-vsctl add-br s2 -- set bridge s2 external_ids:mn-topology=mininet stp_enable=true"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHostCommands(t *testing.T) {
+	fake := NewRecordingExecutor()
+
+	r1 := newFakeHost("r1", fake)
+
+	if err := r1.NetNs().Create(); err != nil {
+		t.Fatal(err)
+	}
+
+	// IPv6 forwarding is set for IPv6 links only
+	r1.Links = append(r1.Links, Link{Name: "eth0", Cidr: "10.0.0.1/24"})
+
+	if err := r1.enableForwarding(); err != nil {
+		t.Fatal(err)
+	}
+
+	r1.Links = append(r1.Links, Link{Name: "eth1", Cidr: "fd00::1/64"})
+
+	if err := r1.enableForwarding(); err != nil {
+		t.Fatal(err)
+	}
+
+	err := fake.Verify(
+		"ip netns add r1",
+		"ip netns exec r1 sysctl -e net.ipv4.ip_forward=1",
+		"ip netns exec r1 sysctl -e net.ipv4.ip_forward=1 net.ipv6.conf.all.forwarding=1",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// absent IPv6 key isn't printed, if IPv6 is disabled
+	fake.On("ip netns exec r1 sysctl -n -e", "1\n", nil)
+
+	if !r1.forwardingEnabled() {
+		t.Fatal("Expected forwarding to be enabled")
+	}
+
+	fake.On("ip netns exec r1 sysctl -n -e", "1\n0\n", nil)
+
+	if r1.forwardingEnabled() {
+		t.Fatal("Expected IPv6 forwarding to be disabled")
+	}
+}
+
+func TestPairCommands(t *testing.T) {
+	fake := NewRecordingExecutor()
+
+	s1 := newFakeSwitch("s1", fake)
+	h1 := newFakeHost("h1", fake)
+
+	p := NewLink(s1, h1,
+		Link{Cidr: noip},
+		Link{Cidr: "10.0.0.2/24", Routes: []Route{{Dst: "0.0.0.0/0", Gw: "10.0.0.1"}}},
+	)
+
+	if err := p.Create(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := p.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	err := fake.Verify(
+		"ip link add name h1-eth0 type veth peer name veth0 netns h1",
+		"ip link set dev h1-eth0 alias mn:mininet",
+		"ip netns exec h1 ip link set dev veth0 alias mn:mininet",
+		"ip netns exec h1 ip addr add 10.0.0.2/24 dev veth0",
+		"ip link set h1-eth0 up",
+		"ip netns exec h1 ip link set veth0 up",
+		"ip netns exec h1 ip route add 0.0.0.0/0 via 10.0.0.1 dev veth0",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPairIPv6Commands(t *testing.T) {
+	fake := NewRecordingExecutor()
+
+	r1 := newFakeHost("r1", fake)
+	h1 := newFakeHost("h1", fake)
+
+	p := NewLink(r1, h1,
+		Link{Name: "eth0", Cidr: "fd00::1/64", SLAAC: true},
+		Link{Name: "eth0", Cidr: "10.0.0.2/24", Addrs: []string{"fd00::2/64"}, Routes: []Route{{Dst: "::/0", Gw: "fd00::1"}}},
+	)
+
+	if _, err := p.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	err := fake.Verify(
+		"ip netns exec r1 ip addr add fd00::1/64 dev eth0 nodad",
+		"ip netns exec h1 ip addr add 10.0.0.2/24 dev eth0",
+		"ip netns exec r1 sysctl -w net.ipv6.conf.eth0.autoconf=1 net.ipv6.conf.eth0.accept_ra=2 net.ipv6.conf.eth0.keep_addr_on_down=1",
+		"ip netns exec h1 sysctl -w net.ipv6.conf.eth0.autoconf=0 net.ipv6.conf.eth0.accept_ra=0 net.ipv6.conf.eth0.keep_addr_on_down=1",
+		"ip netns exec h1 ip addr add fd00::2/64 dev eth0 nodad",
+		"ip netns exec r1 ip link set eth0 up",
+		"ip netns exec h1 ip link set eth0 up",
+		"ip netns exec h1 ip -6 route add ::/0 via fd00::1 dev eth0",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPairError(t *testing.T) {
+	fake := NewRecordingExecutor().On("ip link add", "RTNETLINK answers: File exists", errors.New("exit status 2"))
+
+	p := NewLink(newFakeHost("h1", fake), newFakeHost("h2", fake), Link{Cidr: "10.0.0.1/24"}, Link{Cidr: "10.0.0.2/24"})
+
+	err := p.Create()
+
+	var lerr *LinkError
+	if !errors.As(err, &lerr) {
+		t.Fatal("Expected LinkError, obtained:", err)
+	}
+
+	if lerr.Op != "create" || lerr.Link != p.Left.Name || lerr.NetNs != "h1" {
+		t.Fatal("Unexpected error:", lerr)
+	}
+
+	// pair, which can't be moved into the netns, is deleted
+	fake = NewRecordingExecutor().On("ip link set veth0 netns", "Cannot find device", errors.New("exit status 1"))
+	p = NewLink(newFakeHost("h1", fake), newFakeHost("h2", fake), Link{Cidr: "10.0.0.1/24"}, Link{Cidr: "10.0.0.2/24"})
+
+	if err := p.Create(); err == nil {
+		t.Fatal("Expected move error")
+	}
+
+	calls := fake.Calls()
+	if last := calls[len(calls)-1]; last != "ip link delete veth0" {
+		t.Fatal("Expected pair to be deleted, obtained:", calls)
+	}
+}
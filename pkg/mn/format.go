@@ -0,0 +1,244 @@
+package mn
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Scheme file formats
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+	FormatTOML = "toml"
+)
+
+// FormatByExt detects scheme format by file extension: .json, .yaml, .yml, .toml or .topo.
+// Imported topologies are MiniEdit's .mn and containerlab's .clab.yml files.
+func FormatByExt(fname string) (string, error) {
+	lower := strings.ToLower(fname)
+	if strings.HasSuffix(lower, ".clab.yml") || strings.HasSuffix(lower, ".clab.yaml") {
+		return FormatContainerlab, nil
+	}
+
+	switch filepath.Ext(lower) {
+	case ".json":
+		return FormatJSON, nil
+	case ".yaml", ".yml":
+		return FormatYAML, nil
+	case ".toml":
+		return FormatTOML, nil
+	case ".topo":
+		return FormatTopo, nil
+	case ".mn":
+		return FormatMiniEdit, nil
+	}
+
+	return "", fmt.Errorf("Unknown scheme format of %s, expected .json, .yaml, .yml, .toml, .topo, .mn or .clab.yml", fname)
+}
+
+// NewSchemeFromFile creates scheme from json, yaml, toml or topo file,
+// or imports MiniEdit or containerlab topology, format is detected by extension
+func NewSchemeFromFile(fname string) (*Scheme, error) {
+	format, err := FormatByExt(fname)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSchemeFromData(data, format)
+}
+
+// NewSchemeFromData creates scheme from data in the format. Yaml and toml are
+// converted into json, so Host, Switch and Cgroup unmarshalers work the same way.
+// Older versions are migrated, unknown fields are rejected. Topo is expanded
+// into the scheme, MiniEdit and containerlab topologies are imported.
+func NewSchemeFromData(data []byte, format string) (*Scheme, error) {
+	switch format {
+	case FormatTopo:
+		t, err := ParseTopo(data)
+		if err != nil {
+			return nil, err
+		}
+
+		return t.Scheme()
+
+	case FormatMiniEdit:
+		return ImportMiniEdit(data)
+
+	case FormatContainerlab:
+		return ImportContainerlab(data)
+	}
+
+	data, err := toJSON(data, format)
+	if err != nil {
+		return nil, err
+	}
+
+	// older versions are migrated and unknown fields are rejected,
+	// before unmarshalers see the scheme
+	raw := make(map[string]interface{})
+
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	if err := migrate(raw); err != nil {
+		return nil, err
+	}
+
+	if problems := checkFields(raw, reflect.TypeOf(Scheme{}), "$"); len(problems) > 0 {
+		return nil, problems
+	}
+
+	if data, err = json.Marshal(raw); err != nil {
+		return nil, err
+	}
+
+	scheme := NewScheme()
+
+	if err := json.Unmarshal(data, scheme); err != nil {
+		return nil, err
+	}
+
+	if scheme.Topology != "" {
+		scheme.SetTopology(scheme.Topology)
+	}
+
+	return scheme, nil
+}
+
+// ExportAs exports the scheme in json, yaml or toml format,
+// or renders it as dot or mermaid diagram
+func (s Scheme) ExportAs(format string) (string, error) {
+	switch format {
+	case FormatDOT:
+		return s.DOT(), nil
+	case FormatMermaid:
+		return s.Mermaid(), nil
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+
+	switch format {
+	case FormatJSON:
+		return s.String(), nil
+
+	case FormatYAML:
+		// json is yaml, so decoding into the node keeps fields order
+		node := &yaml.Node{}
+		if err := yaml.Unmarshal(data, node); err != nil {
+			return "", err
+		}
+
+		compact(node)
+
+		out, err := yaml.Marshal(node)
+		if err != nil {
+			return "", err
+		}
+
+		return string(out), nil
+
+	case FormatTOML:
+		var v map[string]interface{}
+		if err := json.Unmarshal(data, &v); err != nil {
+			return "", err
+		}
+
+		buf := &bytes.Buffer{}
+		if err := toml.NewEncoder(buf).Encode(dropEmpty(v)); err != nil {
+			return "", err
+		}
+
+		return buf.String(), nil
+	}
+
+	return "", fmt.Errorf("Unknown scheme format: %s", format)
+}
+
+func toJSON(data []byte, format string) ([]byte, error) {
+	var v interface{}
+
+	switch format {
+	case FormatJSON:
+		return data, nil
+
+	case FormatYAML:
+		if err := yaml.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+
+	case FormatTOML:
+		m := make(map[string]interface{})
+		if _, err := toml.Decode(string(data), &m); err != nil {
+			return nil, err
+		}
+		v = m
+
+	default:
+		return nil, fmt.Errorf("Unknown scheme format: %s", format)
+	}
+
+	return json.Marshal(v)
+}
+
+// compact resets json flow style and drops null and empty fields,
+// they are the same as missing ones for unmarshalers
+func compact(node *yaml.Node) {
+	node.Style = 0
+
+	if node.Kind == yaml.MappingNode {
+		content := make([]*yaml.Node, 0, len(node.Content))
+
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if value := node.Content[i+1]; value.Tag == "!!null" || (value.Tag == "!!str" && value.Value == "") {
+				continue
+			}
+
+			content = append(content, node.Content[i], node.Content[i+1])
+		}
+
+		node.Content = content
+	}
+
+	for _, child := range node.Content {
+		compact(child)
+	}
+}
+
+// dropEmpty removes null and empty values, toml has no null
+func dropEmpty(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for key, value := range t {
+			if value == nil || value == "" {
+				delete(t, key)
+				continue
+			}
+
+			t[key] = dropEmpty(value)
+		}
+
+	case []interface{}:
+		for i := range t {
+			t[i] = dropEmpty(t[i])
+		}
+	}
+
+	return v
+}
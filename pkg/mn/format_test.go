@@ -0,0 +1,126 @@
+package mn
+
+import (
+	"testing"
+)
+
+var formatSchemes = map[string]string{
+	FormatJSON: `{
+    "Switches": [
+        {
+            "Name": "s1",
+            "Ports": [
+                {"Name": "h1-eth0", "Cidr": "noip", "Peer": {"Name": "h1-eth0", "IfName": "eth0", "NodeName": "h1"}}
+            ]
+        }
+    ],
+    "Hosts": [
+        {
+            "Name": "h1",
+            "Links": [
+                {"Name": "eth0", "Cidr": "10.0.0.1/24", "Routes": [{"Dst": "0.0.0.0/0", "Gw": "10.0.0.254"}],
+                 "Peer": {"Name": "h1-eth0", "IfName": "h1-eth0", "NodeName": "s1"}}
+            ]
+        }
+    ]
+}`,
+	FormatYAML: `
+# comments are allowed
+Switches:
+  - Name: s1
+    Ports:
+      - Name: h1-eth0
+        Cidr: noip
+        Peer: {Name: h1-eth0, IfName: eth0, NodeName: h1}
+Hosts:
+  - Name: h1
+    Links:
+      - Name: eth0
+        Cidr: 10.0.0.1/24
+        Routes:
+          - {Dst: 0.0.0.0/0, Gw: 10.0.0.254}
+        Peer: {Name: h1-eth0, IfName: h1-eth0, NodeName: s1}
+`,
+	FormatTOML: `
+# comments are allowed
+[[Switches]]
+Name = "s1"
+
+  [[Switches.Ports]]
+  Name = "h1-eth0"
+  Cidr = "noip"
+  Peer = { Name = "h1-eth0", IfName = "eth0", NodeName = "h1" }
+
+[[Hosts]]
+Name = "h1"
+
+  [[Hosts.Links]]
+  Name = "eth0"
+  Cidr = "10.0.0.1/24"
+  Routes = [ { Dst = "0.0.0.0/0", Gw = "10.0.0.254" } ]
+  Peer = { Name = "h1-eth0", IfName = "h1-eth0", NodeName = "s1" }
+`,
+}
+
+func TestSchemeFormats(t *testing.T) {
+	// loading has no side effects, every command would be recorded
+	fake := NewRecordingExecutor()
+
+	saved := DefaultExecutor
+	DefaultExecutor = fake
+	defer func() { DefaultExecutor = saved }()
+
+	var expected string
+
+	for _, format := range []string{FormatJSON, FormatYAML, FormatTOML} {
+		scheme, err := NewSchemeFromData([]byte(formatSchemes[format]), format)
+		if err != nil {
+			t.Fatal(format, err)
+		}
+
+		obtained, err := scheme.ExportAs(FormatJSON)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if expected == "" {
+			expected = obtained
+			continue
+		}
+
+		if obtained != expected {
+			t.Fatalf("%s\nExpected:\n%s\nObtained:\n%s", format, expected, obtained)
+		}
+	}
+
+	scheme, _ := NewSchemeFromData([]byte(formatSchemes[FormatJSON]), FormatJSON)
+
+	// exported scheme is loaded back the same
+	for _, format := range []string{FormatYAML, FormatTOML} {
+		out, err := scheme.ExportAs(format)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		loaded, err := NewSchemeFromData([]byte(out), format)
+		if err != nil {
+			t.Fatal(format, err, "\n", out)
+		}
+
+		if loaded.String() != expected {
+			t.Fatalf("%s\nExpected:\n%s\nObtained:\n%s", format, expected, loaded)
+		}
+	}
+
+	if calls := fake.Calls(); len(calls) > 0 {
+		t.Fatal("Unexpected commands during loading:", calls)
+	}
+
+	if _, err := FormatByExt("scheme.yml"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := FormatByExt("scheme.xml"); err == nil {
+		t.Fatal("Expected error for unknown extension")
+	}
+}
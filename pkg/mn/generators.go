@@ -0,0 +1,206 @@
+package mn
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/3d0c/mininet/pkg/pool"
+)
+
+// GeneratorNet is a network of generated topos, if the pool isn't preset
+const GeneratorNet = "10.0.0.0/8"
+
+// Generators are standard topos, like original mininet's --topo, by name
+// with number of arguments
+var Generators = map[string]struct {
+	Args     []string
+	Generate func(args ...int) (*Topo, error)
+}{
+	"single":  {[]string{"n"}, func(a ...int) (*Topo, error) { return Single(a[0]) }},
+	"linear":  {[]string{"n"}, func(a ...int) (*Topo, error) { return Linear(a[0]) }},
+	"tree":    {[]string{"depth", "fanout"}, func(a ...int) (*Topo, error) { return Tree(a[0], a[1]) }},
+	"mesh":    {[]string{"n"}, func(a ...int) (*Topo, error) { return Mesh(a[0]) }},
+	"fattree": {[]string{"k"}, func(a ...int) (*Topo, error) { return FatTree(a[0]) }},
+}
+
+// Generate creates the scheme of the standard topo, e.g. Generate("tree", 2, 3)
+func Generate(name string, args ...int) (*Scheme, error) {
+	g, found := Generators[name]
+	if !found {
+		return nil, fmt.Errorf("Unknown topo %s", name)
+	}
+
+	if len(args) != len(g.Args) {
+		return nil, fmt.Errorf("Topo %s expects arguments: %s", name, strings.Join(g.Args, ", "))
+	}
+
+	t, err := g.Generate(args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.Scheme()
+}
+
+// Single is a star: n hosts connected to one switch
+func Single(n int) (*Topo, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("Number of hosts should be positive")
+	}
+
+	t := newGeneratedTopo().AddSwitch("s1")
+
+	for i := 1; i <= n; i++ {
+		t.AddHost(nodeName("h", i)).AddLink("s1", nodeName("h", i))
+	}
+
+	return t, nil
+}
+
+// Linear is a chain of n switches, each one has a host
+func Linear(n int) (*Topo, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("Number of switches should be positive")
+	}
+
+	t := newGeneratedTopo()
+
+	for i := 1; i <= n; i++ {
+		t.AddSwitch(nodeName("s", i)).AddHost(nodeName("h", i)).AddLink(nodeName("s", i), nodeName("h", i))
+
+		if i > 1 {
+			t.AddLink(nodeName("s", i-1), nodeName("s", i))
+		}
+	}
+
+	return t, nil
+}
+
+// Tree is a tree of switches of the depth, every switch has fanout children,
+// hosts are connected to the leaves
+func Tree(depth, fanout int) (*Topo, error) {
+	if depth < 1 || fanout < 1 {
+		return nil, fmt.Errorf("Depth and fanout should be positive")
+	}
+
+	t := newGeneratedTopo().AddSwitch("s1")
+	level := []string{"s1"}
+	switches, hosts := 1, 0
+
+	for d := 1; d <= depth; d++ {
+		next := make([]string, 0, len(level)*fanout)
+
+		for _, parent := range level {
+			for i := 0; i < fanout; i++ {
+				var child string
+
+				if d == depth {
+					hosts++
+					child = nodeName("h", hosts)
+					t.AddHost(child)
+				} else {
+					switches++
+					child = nodeName("s", switches)
+					t.AddSwitch(child)
+				}
+
+				t.AddLink(parent, child)
+				next = append(next, child)
+			}
+		}
+
+		level = next
+	}
+
+	return t, nil
+}
+
+// Mesh is n switches connected to each other, each one has a host.
+// Mesh has loops, so its switches run STP.
+func Mesh(n int) (*Topo, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("Number of switches should be positive")
+	}
+
+	t := newGeneratedTopo()
+
+	for i := 1; i <= n; i++ {
+		t.AddSwitch(nodeName("s", i)).AddHost(nodeName("h", i)).AddLink(nodeName("s", i), nodeName("h", i))
+
+		for j := 1; j < i; j++ {
+			t.AddLink(nodeName("s", j), nodeName("s", i))
+		}
+	}
+
+	return t.withSTP(), nil
+}
+
+// FatTree is a k-ary fat-tree: (k/2)^2 core switches c*, k pods of k/2
+// aggregation a* and k/2 edge e* switches, every edge switch has k/2 hosts.
+// Fat-tree has loops, so its switches run STP.
+func FatTree(k int) (*Topo, error) {
+	if k < 2 || k%2 != 0 {
+		return nil, fmt.Errorf("K should be even and positive")
+	}
+
+	t := newGeneratedTopo()
+	half := k / 2
+
+	for i := 1; i <= half*half; i++ {
+		t.AddSwitch(nodeName("c", i))
+	}
+
+	hosts := 0
+
+	for pod := 0; pod < k; pod++ {
+		for i := 1; i <= half; i++ {
+			t.AddSwitch(nodeName("a", pod*half+i))
+		}
+
+		for i := 1; i <= half; i++ {
+			edge := nodeName("e", pod*half+i)
+			t.AddSwitch(edge)
+
+			for j := 1; j <= half; j++ {
+				t.AddLink(nodeName("a", pod*half+j), edge)
+			}
+
+			for j := 0; j < half; j++ {
+				hosts++
+				t.AddHost(nodeName("h", hosts)).AddLink(edge, nodeName("h", hosts))
+			}
+		}
+
+		// aggregation switch i is connected to i-th group of core switches
+		for i := 0; i < half; i++ {
+			for j := 1; j <= half; j++ {
+				t.AddLink(nodeName("c", i*half+j), nodeName("a", pod*half+i+1))
+			}
+		}
+	}
+
+	return t.withSTP(), nil
+}
+
+func newGeneratedTopo() *Topo {
+	t := NewTopo()
+
+	if !pool.ThePool().Preset() {
+		t.Pool = GeneratorNet
+	}
+
+	return t
+}
+
+// withSTP enables spanning tree of all switches, so loops don't flood
+func (t *Topo) withSTP() *Topo {
+	for i := range t.Switches {
+		t.Switches[i].STP = true
+	}
+
+	return t
+}
+
+func nodeName(prefix string, i int) string {
+	return fmt.Sprintf("%s%d", prefix, i)
+}
@@ -0,0 +1,94 @@
+package mn
+
+import (
+	"testing"
+)
+
+func TestGenerators(t *testing.T) {
+	cases := []struct {
+		name     string
+		args     []int
+		switches int
+		hosts    int
+		patches  int
+		stp      bool
+	}{
+		{"single", []int{3}, 1, 3, 0, false},
+		{"linear", []int{4}, 4, 4, 3, false},
+		{"tree", []int{2, 3}, 4, 9, 3, false},
+		{"mesh", []int{4}, 4, 4, 6, true},
+		{"fattree", []int{4}, 20, 16, 32, true},
+	}
+
+	for _, c := range cases {
+		scheme, err := Generate(c.name, c.args...)
+		if err != nil {
+			t.Fatal(c.name, err)
+		}
+
+		if len(scheme.Switches) != c.switches || len(scheme.Hosts) != c.hosts {
+			t.Fatalf("%s\nExpected: %d switches, %d hosts\nObtained: %d switches, %d hosts",
+				c.name, c.switches, c.hosts, len(scheme.Switches), len(scheme.Hosts))
+		}
+
+		patches := 0
+		addrs := make(map[string]bool)
+
+		for _, sw := range scheme.Switches {
+			for _, port := range sw.Ports {
+				if len(port.Name) > 15 {
+					t.Fatalf("%s: port name %s is too long", c.name, port.Name)
+				}
+
+				peer, found := scheme.GetNode(port.Peer.NodeName)
+				if !found {
+					t.Fatalf("%s: unknown peer of %s: %v", c.name, port.Name, port.Peer)
+				}
+
+				if _, ok := peer.(*Switch); ok {
+					patches++
+				}
+
+				l := peer.GetLinks().LinkByPeer(Peer{NodeName: port.Peer.NodeName, IfName: port.Peer.IfName})
+				if l.Peer.NodeName != sw.Name || l.Peer.IfName != port.Name {
+					t.Fatalf("%s: port %s of %s isn't mirrored by %v", c.name, port.Name, sw.Name, l)
+				}
+			}
+		}
+
+		for _, h := range scheme.Hosts {
+			if len(h.Links) != 1 || addrs[h.Links[0].Cidr] {
+				t.Fatalf("%s: unexpected links of %s: %v", c.name, h.Name, h.Links)
+			}
+			addrs[h.Links[0].Cidr] = true
+		}
+
+		// loops are allowed by STP only
+		for _, sw := range scheme.Switches {
+			if sw.STP != c.stp {
+				t.Fatalf("%s\nExpected: stp of %s %t\nObtained: %t", c.name, sw.Name, c.stp, sw.STP)
+			}
+		}
+
+		// generated topo is the same every time
+		again, _ := Generate(c.name, c.args...)
+		for i, h := range again.Hosts {
+			if h.Links[0].Cidr != scheme.Hosts[i].Links[0].Cidr {
+				t.Fatalf("%s\nExpected: %s %s\nObtained: %s", c.name, h.Name, scheme.Hosts[i].Links[0].Cidr, h.Links[0].Cidr)
+			}
+		}
+
+		// every patch link is counted by both switches
+		if patches/2 != c.patches {
+			t.Fatalf("%s\nExpected: %d patch links\nObtained: %d", c.name, c.patches, patches/2)
+		}
+	}
+
+	if _, err := Generate("tree", 2); err == nil {
+		t.Fatal("Expected error for wrong number of arguments")
+	}
+
+	if _, err := Generate("fattree", 3); err == nil {
+		t.Fatal("Expected error for odd k")
+	}
+}
@@ -6,26 +6,31 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"time"
 )
 
 // Host structure
 type Host struct {
-	Cgroup *Cgroup
-	Name   string
-	netns  *NetNs
-	Links  Links
-	Procs  Procs
+	Cgroup   *Cgroup
+	Name     string
+	netns    *NetNs
+	Links    Links
+	Procs    Procs
+	exec     Executor
+	topology string
 }
 
-// NewRouter creates a host instance with forwarding enabled
+// NewRouter creates a host instance with forwarding enabled. Its links
+// aren't known yet, so IPv6 forwarding is enabled too, unless IPv6 is
+// disabled on the system.
 func NewRouter(name ...string) (*Host, error) {
 	host, err := NewHost(name...)
 	if err != nil {
 		return nil, err
 	}
 
-	if err = host.enableForwarding(); err != nil {
+	if err = host.setForwarding("1", ipv4Forwarding, ipv6Forwarding); err != nil {
 		return nil, err
 	}
 
@@ -64,7 +69,8 @@ func (h Host) String() string {
 	return string(out)
 }
 
-// UnmarshalJSON satisfies Mashaller
+// UnmarshalJSON satisfies Mashaller, it only decodes the host,
+// netns is created by Scheme.Apply
 func (h *Host) UnmarshalJSON(b []byte) error {
 	type tmp Host
 	host := tmp{}
@@ -79,16 +85,6 @@ func (h *Host) UnmarshalJSON(b []byte) error {
 	h.Procs = host.Procs
 	h.Cgroup = host.Cgroup
 
-	if !h.netns.Exists() {
-		if err := h.NetNs().Create(); err != nil {
-			return err
-		}
-	}
-
-	if len(h.Links) > 1 {
-		h.enableForwarding()
-	}
-
 	return nil
 }
 
@@ -178,23 +174,111 @@ func (h Host) RunCommand(args ...string) (string, error) {
 		command = h.Cgroup.CgExecCommand()
 	}
 
-	ipCmd := FullPathFor("ip")
-	if ipCmd == "" {
-		return "", errors.New("ip command not found the PATH")
-	}
-
 	if h.NetNs() != nil {
-		command = append(command, []string{ipCmd, "netns", "exec", h.NetNs().Name()}...)
+		command = append(command, []string{"ip", "netns", "exec", h.NetNs().Name()}...)
 	}
 
 	command = append(command, args...)
 
-	return RunCommand(command[0], command[1:]...)
+	return h.executor().Run(command[0], command[1:]...)
+}
+
+// SetExecutor sets executor for the host, its netns and links
+func (h *Host) SetExecutor(e Executor) {
+	h.exec = e
+
+	if h.netns != nil {
+		h.netns.SetExecutor(e)
+	}
+
+	for i := range h.Links {
+		h.Links[i] = h.Links[i].SetExecutor(e)
+	}
+}
+
+// SetTopology sets ID of the topology, which owns the host, its netns and links
+func (h *Host) SetTopology(id string) {
+	h.topology = id
+
+	if h.netns != nil {
+		h.netns.SetTopology(id)
+	}
+
+	for i := range h.Links {
+		h.Links[i] = h.Links[i].SetTopology(id)
+	}
+}
+
+func (h Host) executor() Executor {
+	return executorOrDefault(h.exec)
+}
+
+const (
+	ipv4Forwarding = "net.ipv4.ip_forward"
+	ipv6Forwarding = "net.ipv6.conf.all.forwarding"
+)
+
+// forwardingKeys are sysctl keys of forwarding, IPv6 one is used only if
+// the host has IPv6 addresses or SLAAC links
+func (h Host) forwardingKeys() []string {
+	for _, l := range h.Links {
+		if l.SLAAC || l.HasIPv6() {
+			return []string{ipv4Forwarding, ipv6Forwarding}
+		}
+	}
+
+	return []string{ipv4Forwarding}
+}
+
+// forwardingTarget is a plan target of enabling forwarding
+func (h Host) forwardingTarget() string {
+	keys := h.forwardingKeys()
+	for i := range keys {
+		keys[i] += "=1"
+	}
+
+	return strings.Join(keys, " ")
 }
 
+// setForwarding sets forwarding keys to the value, keys, which are absent,
+// e.g. IPv6 one if IPv6 is disabled, are ignored
+func (h Host) setForwarding(value string, keys ...string) error {
+	args := []string{"sysctl", "-e"}
+	for _, key := range keys {
+		args = append(args, key+"="+value)
+	}
+
+	out, err := h.RunCommand(args...)
+	if err != nil {
+		return fmt.Errorf("Error: %v, output: %s", err, out)
+	}
+
+	return nil
+}
+
+// enableForwarding enables IPv4 forwarding, and IPv6 one if it's used
 func (h Host) enableForwarding() error {
-	_, err := h.RunCommand("sysctl", "net.ipv4.ip_forward=1")
-	return err
+	return h.setForwarding("1", h.forwardingKeys()...)
+}
+
+func (h Host) disableForwarding() error {
+	return h.setForwarding("0", h.forwardingKeys()...)
+}
+
+func (h Host) forwardingEnabled() bool {
+	out, err := h.RunCommand(append([]string{"sysctl", "-n", "-e"}, h.forwardingKeys()...)...)
+	if err != nil {
+		return false
+	}
+
+	values := strings.Fields(out)
+	for _, v := range values {
+		if v != "1" {
+			return false
+		}
+	}
+
+	return len(values) > 0
 }
 
 // NodeName host name getter
@@ -253,35 +337,14 @@ func (h Host) Release() error {
 
 // AddLink add link into host's links array
 func (h *Host) AddLink(l Link) error {
-	h.Links = append(h.Links, l)
-	return nil
-}
-
-func (h *Host) recoverProcs() error {
-	for i, proc := range h.Procs {
-		fmt.Println("Recovering ", proc.Command, proc.Args)
-
-		var p *os.Process
-		var err error
-
-		if p, err = proc.findProcessByName(h.NetNs().Name()); err != nil {
-			return err
-		}
-
-		if p != nil {
-			proc.Process = p
-		} else {
-			c := append([]string{proc.Command}, proc.Args...)
-			result, err := h.runProcess(c...)
-			if err != nil {
-				return err
-			}
-
-			h.Procs[i].Process = result.Process
+	if l.exec == nil {
+		l.exec = h.exec
+	}
 
-			continue
-		}
+	if l.topology == "" {
+		l.topology = h.topology
 	}
 
+	h.Links = append(h.Links, l)
 	return nil
 }
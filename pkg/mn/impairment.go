@@ -0,0 +1,343 @@
+package mn
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Impairment is an emulated quality of the link, like mininet's TCLink.
+// It's applied by tc to egress of the interface: htb limits the rate,
+// netem adds delay, jitter, loss, duplication and reordering. Percents
+// are 0-100, Limit is a queue size in packets.
+type Impairment struct {
+	Rate      string  `json:",omitempty"`
+	Delay     string  `json:",omitempty"`
+	Jitter    string  `json:",omitempty"`
+	Loss      float64 `json:",omitempty"`
+	Duplicate float64 `json:",omitempty"`
+	Reorder   float64 `json:",omitempty"`
+	Limit     int     `json:",omitempty"`
+}
+
+var (
+	rateRe = regexp.MustCompile(`^\d+(\.\d+)?(bit|kbit|mbit|gbit|tbit|bps|kbps|mbps|gbps|tbps)$`)
+	timeRe = regexp.MustCompile(`^\d+(\.\d+)?(us|ms|s)$`)
+)
+
+// String satisfies stringer interface, it's the same as ParseImpairment input
+func (im Impairment) String() string {
+	return strings.Join(im.args(), " ")
+}
+
+func (im Impairment) args() []string {
+	result := make([]string, 0)
+
+	add := func(key, value string) {
+		result = append(result, key, value)
+	}
+
+	if im.Rate != "" {
+		add("rate", im.Rate)
+	}
+
+	if im.Delay != "" {
+		add("delay", im.Delay)
+	}
+
+	if im.Jitter != "" {
+		add("jitter", im.Jitter)
+	}
+
+	if im.Loss > 0 {
+		add("loss", percent(im.Loss))
+	}
+
+	if im.Duplicate > 0 {
+		add("duplicate", percent(im.Duplicate))
+	}
+
+	if im.Reorder > 0 {
+		add("reorder", percent(im.Reorder))
+	}
+
+	if im.Limit > 0 {
+		add("limit", strconv.Itoa(im.Limit))
+	}
+
+	return result
+}
+
+// ParseImpairment parses "key value" pairs, e.g. "delay 50ms loss 1%",
+// into the copy of the base impairment. Keys are rate (or bw), delay,
+// jitter, loss, duplicate, reorder and limit.
+func ParseImpairment(args []string, base *Impairment) (*Impairment, error) {
+	result := &Impairment{}
+	if base != nil {
+		*result = *base
+	}
+
+	if len(args)%2 != 0 {
+		return nil, fmt.Errorf("Expected key value pairs, obtained: %s", strings.Join(args, " "))
+	}
+
+	for i := 0; i < len(args); i += 2 {
+		key, value := args[i], args[i+1]
+
+		var err error
+
+		switch key {
+		case "rate", "bw":
+			result.Rate = value
+		case "delay":
+			result.Delay = value
+		case "jitter":
+			result.Jitter = value
+		case "loss":
+			result.Loss, err = parsePercent(value)
+		case "duplicate":
+			result.Duplicate, err = parsePercent(value)
+		case "reorder":
+			result.Reorder, err = parsePercent(value)
+		case "limit":
+			result.Limit, err = strconv.Atoi(value)
+		default:
+			err = fmt.Errorf("unknown parameter")
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("Wrong %s %s: %v", key, value, err)
+		}
+	}
+
+	if problems := result.problems(); len(problems) > 0 {
+		return nil, fmt.Errorf("Wrong impairment: %s", strings.Join(problems, ", "))
+	}
+
+	return result, nil
+}
+
+// problems returns what's wrong with the impairment
+func (im Impairment) problems() []string {
+	result := make([]string, 0)
+
+	if im.Rate != "" && !rateRe.MatchString(im.Rate) {
+		result = append(result, fmt.Sprintf("invalid rate %s, expected e.g. 10mbit", im.Rate))
+	}
+
+	for _, t := range []struct{ name, value string }{{"delay", im.Delay}, {"jitter", im.Jitter}} {
+		if t.value != "" && !timeRe.MatchString(t.value) {
+			result = append(result, fmt.Sprintf("invalid %s %s, expected e.g. 50ms", t.name, t.value))
+		}
+	}
+
+	for _, p := range []struct {
+		name  string
+		value float64
+	}{{"loss", im.Loss}, {"duplicate", im.Duplicate}, {"reorder", im.Reorder}} {
+		if p.value < 0 || p.value > 100 {
+			result = append(result, fmt.Sprintf("%s %v isn't in 0-100%%", p.name, p.value))
+		}
+	}
+
+	if im.Limit < 0 {
+		result = append(result, fmt.Sprintf("negative limit %d", im.Limit))
+	}
+
+	if im.Jitter != "" && im.Delay == "" {
+		result = append(result, "jitter without delay")
+	}
+
+	if im.Reorder > 0 && im.Delay == "" {
+		result = append(result, "reorder without delay")
+	}
+
+	return result
+}
+
+// netem returns netem parameters, empty if there are none
+func (im Impairment) netem() []string {
+	result := make([]string, 0)
+
+	if im.Delay != "" {
+		result = append(result, "delay", im.Delay)
+
+		if im.Jitter != "" {
+			result = append(result, im.Jitter)
+		}
+	}
+
+	if im.Loss > 0 {
+		result = append(result, "loss", percent(im.Loss))
+	}
+
+	if im.Duplicate > 0 {
+		result = append(result, "duplicate", percent(im.Duplicate))
+	}
+
+	if im.Reorder > 0 {
+		result = append(result, "reorder", percent(im.Reorder))
+	}
+
+	if im.Limit > 0 {
+		result = append(result, "limit", strconv.Itoa(im.Limit))
+	}
+
+	return result
+}
+
+// ApplyImpairment sets the link's impairment by tc, the previous one
+// should be cleared
+func (l Link) ApplyImpairment() error {
+	if !l.impaired() {
+		return nil
+	}
+
+	netem := l.Impairment.netem()
+	parent := []string{"root"}
+
+	if l.Impairment.Rate != "" {
+		if err := l.tc("qdisc", "add", "dev", l.Name, "root", "handle", "1:", "htb", "default", "1"); err != nil {
+			return err
+		}
+
+		if err := l.tc("class", "add", "dev", l.Name, "parent", "1:", "classid", "1:1", "htb", "rate", l.Impairment.Rate); err != nil {
+			return err
+		}
+
+		parent = []string{"parent", "1:1"}
+	}
+
+	if len(netem) == 0 {
+		return nil
+	}
+
+	args := append([]string{"qdisc", "add", "dev", l.Name}, parent...)
+	args = append(args, "handle", "10:", "netem")
+
+	return l.tc(append(args, netem...)...)
+}
+
+// ClearImpairment removes the link's qdiscs, if the impairment is set
+func (l Link) ClearImpairment() error {
+	if !l.impaired() {
+		return nil
+	}
+
+	return l.tc("qdisc", "del", "dev", l.Name, "root")
+}
+
+// replaceImpairment clears the link's impairment and applies the one of nl
+func (l Link) replaceImpairment(nl Link) error {
+	if err := l.ClearImpairment(); err != nil {
+		return err
+	}
+
+	return nl.ApplyImpairment()
+}
+
+func impairmentString(l Link) string {
+	if !l.impaired() {
+		return "none"
+	}
+
+	return l.Impairment.String()
+}
+
+func (l Link) impaired() bool {
+	return l.Impairment != nil && len(l.Impairment.args()) > 0
+}
+
+func (l Link) tc(args ...string) error {
+	commands := append([]string{"tc"}, args...)
+	if l.NetNs != "" {
+		commands = append([]string{"ip", "netns", "exec", l.NetNs}, commands...)
+	}
+
+	if out, err := l.executor().Run(commands[0], commands[1:]...); err != nil {
+		return fmt.Errorf("Error: %v, output: %s", err, out)
+	}
+
+	return nil
+}
+
+// SetImpairment applies the impairment to the node's link and updates
+// the scheme, nil impairment clears it
+func (s *Scheme) SetImpairment(node, ifname string, im *Impairment) error {
+	l, err := s.link(node, ifname)
+	if err != nil {
+		return err
+	}
+
+	if im != nil {
+		if problems := im.problems(); len(problems) > 0 {
+			return fmt.Errorf("Wrong impairment: %s", strings.Join(problems, ", "))
+		}
+	}
+
+	nl := *l
+	nl.Impairment = im
+
+	if err := l.replaceImpairment(nl); err != nil {
+		return err
+	}
+
+	l.Impairment = im
+
+	return nil
+}
+
+// link returns pointer to the node's link, so it could be updated
+func (s *Scheme) link(node, ifname string) (*Link, error) {
+	n, found := s.GetNode(node)
+	if !found {
+		return nil, fmt.Errorf("Node %s not found", node)
+	}
+
+	var links Links
+
+	switch t := n.(type) {
+	case *Host:
+		links = t.Links
+	case *Switch:
+		links = t.Ports
+	}
+
+	for i := range links {
+		if links[i].Name == ifname {
+			return &links[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("Link %s of %s not found", ifname, node)
+}
+
+// LinkOwner returns name of the node, which has the link with the name,
+// the name should be unique in the scheme
+func (s Scheme) LinkOwner(ifname string) (string, error) {
+	owners := make([]string, 0)
+
+	for n := range s.Nodes() {
+		if _, found := n.GetLinks().ByName(ifname); found {
+			owners = append(owners, n.NodeName())
+		}
+	}
+
+	switch len(owners) {
+	case 0:
+		return "", fmt.Errorf("Link %s not found", ifname)
+	case 1:
+		return owners[0], nil
+	}
+
+	return "", fmt.Errorf("Link %s is ambiguous, it's found at %s", ifname, strings.Join(owners, ", "))
+}
+
+func percent(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64) + "%"
+}
+
+func parsePercent(s string) (float64, error) {
+	return strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+}
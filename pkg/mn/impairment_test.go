@@ -0,0 +1,112 @@
+package mn
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestImpairmentCommands(t *testing.T) {
+	fake := NewRecordingExecutor()
+
+	s1 := newFakeSwitch("s1", fake)
+	h1 := newFakeHost("h1", fake)
+
+	p := NewLink(s1, h1,
+		Link{Name: "h1-eth0", Cidr: noip, Impairment: &Impairment{Rate: "10mbit", Delay: "50ms", Jitter: "5ms", Loss: 1.5}},
+		Link{Name: "eth0", Cidr: noip, Impairment: &Impairment{Duplicate: 1, Limit: 100}},
+	)
+
+	if _, err := p.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	err := fake.Verify(
+		"ip link set h1-eth0 up",
+		"ip netns exec h1 ip link set eth0 up",
+		"tc qdisc add dev h1-eth0 root handle 1: htb default 1",
+		"tc class add dev h1-eth0 parent 1: classid 1:1 htb rate 10mbit",
+		"tc qdisc add dev h1-eth0 parent 1:1 handle 10: netem delay 50ms 5ms loss 1.5%",
+		"ip netns exec h1 tc qdisc add dev eth0 root handle 10: netem duplicate 1% limit 100",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake = NewRecordingExecutor()
+	scheme := newFakeScheme(fake)
+
+	im, err := ParseImpairment([]string{"delay", "20ms", "loss", "2%"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := scheme.SetImpairment("h1", "veth0", im); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := scheme.SetImpairment("h1", "veth0", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	err = fake.Verify(
+		"ip netns exec h1 tc qdisc add dev veth0 root handle 10: netem delay 20ms loss 2%",
+		"ip netns exec h1 tc qdisc del dev veth0 root",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := scheme.SetImpairment("h1", "eth9", im); err == nil {
+		t.Fatal("Expected error for unknown link")
+	}
+
+	desired := newFakeScheme(fake)
+	h2, _ := desired.GetHost("h2")
+	h2.Links[0].Impairment = im
+
+	plan, err := scheme.Diff(desired)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(plan) == 0 || plan[0].String() != "set impairment h2 delay 20ms loss 2% dev eth0@h2" {
+		t.Fatal("Unexpected plan:\n", plan)
+	}
+}
+
+func TestParseImpairment(t *testing.T) {
+	base := &Impairment{Rate: "1gbit", Delay: "10ms"}
+
+	im, err := ParseImpairment([]string{"bw", "100mbit", "jitter", "1ms", "reorder", "25", "limit", "50"}, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &Impairment{Rate: "100mbit", Delay: "10ms", Jitter: "1ms", Reorder: 25, Limit: 50}
+	if !reflect.DeepEqual(im, expected) {
+		t.Fatalf("\nExpected: %v\nObtained: %v", expected, im)
+	}
+
+	if base.Rate != "1gbit" {
+		t.Fatal("Base impairment is modified:", base)
+	}
+
+	if s := im.String(); s != "rate 100mbit delay 10ms jitter 1ms reorder 25% limit 50" {
+		t.Fatal("Unexpected string:", s)
+	}
+
+	for _, args := range [][]string{
+		{"delay"},
+		{"delay", "50"},
+		{"rate", "fast"},
+		{"loss", "101%"},
+		{"jitter", "5ms"},
+		{"reorder", "10"},
+		{"limit", "-1"},
+		{"mtu", "1500"},
+	} {
+		if _, err := ParseImpairment(args, nil); err == nil {
+			t.Fatal("Expected error for", args)
+		}
+	}
+}
@@ -0,0 +1,458 @@
+package mn
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Formats of the imported topologies
+const (
+	FormatMiniEdit     = "miniedit"
+	FormatContainerlab = "clab"
+)
+
+// ImportMininet creates scheme from original mininet's command line options:
+// --topo, --controller and --ipbase, e.g. "--topo tree,depth=2,fanout=3
+// --controller remote,ip=127.0.0.1,port=6653". Bare "tree,2,3" is a topo.
+// Supported topos are minimal, single, reversed, linear and tree, other
+// options are ignored and returned by the scheme's Warnings.
+func ImportMininet(args string) (*Scheme, error) {
+	var spec, controller, ipbase string
+	var warnings []string
+
+	fields := strings.Fields(args)
+
+	for i := 0; i < len(fields); i++ {
+		opt, value := fields[i], ""
+
+		if j := strings.Index(opt, "="); strings.HasPrefix(opt, "-") && j > 0 {
+			opt, value = opt[:j], opt[j+1:]
+		} else if strings.HasPrefix(opt, "-") && i+1 < len(fields) && !strings.HasPrefix(fields[i+1], "-") {
+			value = fields[i+1]
+			i++
+		}
+
+		switch opt {
+		case "--topo":
+			spec = value
+		case "--controller":
+			controller = value
+		case "-i", "--ipbase":
+			ipbase = value
+		default:
+			if !strings.HasPrefix(opt, "-") {
+				spec = opt
+				continue
+			}
+			warnings = append(warnings, fmt.Sprintf("Option %s is ignored", opt))
+		}
+	}
+
+	if spec == "" {
+		spec = "minimal"
+	}
+
+	t, err := mininetTopo(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	if ipbase != "" {
+		if _, _, err := net.ParseCIDR(ipbase); err != nil {
+			return nil, err
+		}
+		t.Pool = ipbase
+	}
+
+	if controller != "" {
+		addr, err := mininetController(controller)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range t.Switches {
+			t.Switches[i].Controller = addr
+		}
+	}
+
+	return withWarnings(t, warnings)
+}
+
+// withWarnings expands the topo and sets warnings of the import to the scheme
+func withWarnings(t *Topo, warnings []string) (*Scheme, error) {
+	scheme, err := t.Scheme()
+	if err != nil {
+		return nil, err
+	}
+
+	scheme.warnings = warnings
+
+	return scheme, nil
+}
+
+// mininetTopo parses "name,arg,key=value" topo
+func mininetTopo(spec string) (*Topo, error) {
+	parts := strings.Split(spec, ",")
+	name := parts[0]
+
+	params := map[string][]string{
+		"minimal":  {},
+		"single":   {"k"},
+		"reversed": {"k"},
+		"linear":   {"k", "n"},
+		"tree":     {"depth", "fanout"},
+	}
+
+	// mininet defaults
+	values := map[string]int{"k": 2, "n": 1, "depth": 1, "fanout": 2}
+
+	names, found := params[name]
+	if !found {
+		return nil, fmt.Errorf("Unsupported mininet topo %s", name)
+	}
+
+	for i, arg := range parts[1:] {
+		key := ""
+
+		if j := strings.Index(arg, "="); j >= 0 {
+			key, arg = arg[:j], arg[j+1:]
+		} else if i < len(names) {
+			key = names[i]
+		}
+
+		v, err := strconv.Atoi(arg)
+		if err != nil || !contains(names, key) {
+			return nil, fmt.Errorf("Wrong argument %s of mininet topo %s", parts[i+1], name)
+		}
+
+		values[key] = v
+	}
+
+	switch name {
+	case "minimal":
+		return Single(2)
+	case "single", "reversed":
+		return Single(values["k"])
+	case "tree":
+		return Tree(values["depth"], values["fanout"])
+	}
+
+	if values["n"] == 1 {
+		return Linear(values["k"])
+	}
+
+	// hosts are named as mininet does, h{host}s{switch}
+	t := newGeneratedTopo()
+
+	for i := 1; i <= values["k"]; i++ {
+		sw := nodeName("s", i)
+		t.AddSwitch(sw)
+
+		for j := 1; j <= values["n"]; j++ {
+			h := fmt.Sprintf("h%ds%d", j, i)
+			t.AddHost(h).AddLink(sw, h)
+		}
+
+		if i > 1 {
+			t.AddLink(nodeName("s", i-1), sw)
+		}
+	}
+
+	return t, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// mininetController converts "remote,ip=A,port=P" into the controller address
+func mininetController(spec string) (string, error) {
+	parts := strings.Split(spec, ",")
+
+	if parts[0] != "remote" {
+		return "", fmt.Errorf("Unsupported mininet controller %s, only remote is", parts[0])
+	}
+
+	ip, port := "127.0.0.1", "6653"
+
+	for _, part := range parts[1:] {
+		switch {
+		case strings.HasPrefix(part, "ip="):
+			ip = strings.TrimPrefix(part, "ip=")
+		case strings.HasPrefix(part, "port="):
+			port = strings.TrimPrefix(part, "port=")
+		}
+	}
+
+	return "tcp:" + ip + ":" + port, nil
+}
+
+// miniEdit is a topology saved by mininet's MiniEdit
+type miniEdit struct {
+	Application struct {
+		IPBase string `json:"ipBase"`
+	} `json:"application"`
+	Controllers []miniEditNode `json:"controllers"`
+	Hosts       []miniEditNode `json:"hosts"`
+	Switches    []miniEditNode `json:"switches"`
+	Links       []struct {
+		Src  string `json:"src"`
+		Dest string `json:"dest"`
+	} `json:"links"`
+}
+
+type miniEditNode struct {
+	Opts struct {
+		Hostname           string      `json:"hostname"`
+		IP                 string      `json:"ip"`
+		DefaultRoute       string      `json:"defaultRoute"`
+		SwitchType         string      `json:"switchType"`
+		Controllers        []string    `json:"controllers"`
+		ControllerType     string      `json:"controllerType"`
+		ControllerProtocol string      `json:"controllerProtocol"`
+		RemoteIP           string      `json:"remoteIP"`
+		RemotePort         json.Number `json:"remotePort"`
+	} `json:"opts"`
+}
+
+// ImportMiniEdit creates scheme from MiniEdit .mn file. Legacy routers are
+// hosts, hosts' IPs without prefix length get it from the ipBase. Hosts
+// without IPs get the next addresses of the ipBase, as mininet does,
+// explicit IPs of other hosts are skipped.
+func ImportMiniEdit(data []byte) (*Scheme, error) {
+	me := miniEdit{}
+
+	if err := json.Unmarshal(data, &me); err != nil {
+		return nil, err
+	}
+
+	t := NewTopo()
+	t.Pool = me.Application.IPBase
+	if t.Pool == "" {
+		t.Pool = GeneratorNet
+	}
+
+	_, ipbase, err := net.ParseCIDR(t.Pool)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix, _ := ipbase.Mask.Size()
+
+	controllers := make(map[string]string)
+
+	for _, c := range me.Controllers {
+		ip, port, proto := c.Opts.RemoteIP, c.Opts.RemotePort.String(), c.Opts.ControllerProtocol
+
+		// reference controller runs locally
+		if c.Opts.ControllerType == "ref" || ip == "" {
+			ip = "127.0.0.1"
+		}
+		if port == "" {
+			port = "6633"
+		}
+		if proto == "" {
+			proto = "tcp"
+		}
+
+		controllers[c.Opts.Hostname] = proto + ":" + ip + ":" + port
+	}
+
+	addrs := make(map[string]string)
+
+	for _, h := range me.Hosts {
+		t.AddHost(h.Opts.Hostname)
+
+		if ip := h.Opts.IP; ip != "" {
+			if !strings.Contains(ip, "/") {
+				ip = fmt.Sprintf("%s/%d", ip, prefix)
+			}
+			addrs[h.Opts.Hostname] = ip
+		}
+
+		if h.Opts.DefaultRoute != "" {
+			t.AddRoute(h.Opts.Hostname, "0.0.0.0/0", h.Opts.DefaultRoute)
+		}
+	}
+
+	for _, sw := range me.Switches {
+		if sw.Opts.SwitchType == "legacyRouter" {
+			t.AddHost(sw.Opts.Hostname)
+			continue
+		}
+
+		ts := TopoSwitch{Name: sw.Opts.Hostname}
+		if len(sw.Opts.Controllers) > 0 {
+			ts.Controller = controllers[sw.Opts.Controllers[0]]
+		}
+
+		t.Switches = append(t.Switches, ts)
+	}
+
+	for _, l := range me.Links {
+		tl := TopoLink{Left: TopoEndpoint{Node: l.Src}, Right: TopoEndpoint{Node: l.Dest}}
+
+		// host's IP is set to its first link, as mininet does
+		for _, ep := range []*TopoEndpoint{&tl.Left, &tl.Right} {
+			if addr, found := addrs[ep.Node]; found {
+				ep.Cidr = addr
+				delete(addrs, ep.Node)
+			}
+		}
+
+		t.Links = append(t.Links, tl)
+	}
+
+	return t.Scheme()
+}
+
+// containerlab is a topology of containerlab .clab.yml file
+type containerlab struct {
+	Name     string `yaml:"name"`
+	Topology struct {
+		Defaults struct {
+			Kind string `yaml:"kind"`
+		} `yaml:"defaults"`
+		Nodes map[string]struct {
+			Kind string   `yaml:"kind"`
+			Exec []string `yaml:"exec"`
+		} `yaml:"nodes"`
+		Links []struct {
+			Endpoints []interface{} `yaml:"endpoints"`
+		} `yaml:"links"`
+	} `yaml:"topology"`
+}
+
+var (
+	clabAddrRe  = regexp.MustCompile(`ip\s+(?:-4\s+)?a(?:ddr(?:ess)?)?\s+(?:add|replace)\s+(\S+)\s+dev\s+(\S+)`)
+	clabRouteRe = regexp.MustCompile(`ip\s+(?:-4\s+)?r(?:oute)?\s+(?:add|replace)\s+(\S+)\s+via\s+(\S+)`)
+)
+
+// ImportContainerlab creates scheme from containerlab .clab.yml file. Bridge and
+// ovs-bridge nodes are switches, other nodes are hosts, containers aren't run.
+// Addresses and routes are taken from "ip addr add" and "ip route add" of the
+// nodes' exec, links to the container host and management network are skipped
+// and returned by the scheme's Warnings.
+func ImportContainerlab(data []byte) (*Scheme, error) {
+	clab := containerlab{}
+	var warnings []string
+
+	if err := yaml.Unmarshal(data, &clab); err != nil {
+		return nil, err
+	}
+
+	t := NewTopo(clab.Name)
+	addrs := make(map[string]string)
+
+	names := make([]string, 0, len(clab.Topology.Nodes))
+	for name := range clab.Topology.Nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		node := clab.Topology.Nodes[name]
+
+		kind := node.Kind
+		if kind == "" {
+			kind = clab.Topology.Defaults.Kind
+		}
+
+		if kind == "bridge" || kind == "ovs-bridge" {
+			t.AddSwitch(name)
+			continue
+		}
+
+		t.AddHost(name)
+
+		for _, cmd := range node.Exec {
+			if m := clabAddrRe.FindStringSubmatch(cmd); m != nil {
+				addrs[name+":"+m[2]] = m[1]
+			}
+
+			if m := clabRouteRe.FindStringSubmatch(cmd); m != nil {
+				dst := m[1]
+				if dst == "default" {
+					dst = "0.0.0.0/0"
+				}
+				t.AddRoute(name, dst, m[2])
+			}
+		}
+	}
+
+	for i, l := range clab.Topology.Links {
+		if len(l.Endpoints) != 2 {
+			return nil, fmt.Errorf("Link #%d should have two endpoints", i)
+		}
+
+		eps := make([]TopoEndpoint, 0, 2)
+
+		for _, raw := range l.Endpoints {
+			ep, err := clabEndpoint(raw)
+			if err != nil {
+				return nil, fmt.Errorf("Link #%d: %v", i, err)
+			}
+
+			eps = append(eps, ep)
+		}
+
+		_, left := clab.Topology.Nodes[eps[0].Node]
+		_, right := clab.Topology.Nodes[eps[1].Node]
+
+		if !left || !right {
+			warnings = append(warnings, fmt.Sprintf("Link %s:%s <-> %s:%s is skipped", eps[0].Node, eps[0].IfName, eps[1].Node, eps[1].IfName))
+			continue
+		}
+
+		// interfaces without addresses in exec aren't addressed
+		for j := range eps {
+			if addr, found := addrs[eps[j].Node+":"+eps[j].IfName]; found {
+				eps[j].Cidr = addr
+			} else {
+				eps[j].Cidr = noip
+			}
+		}
+
+		t.Links = append(t.Links, TopoLink{Left: eps[0], Right: eps[1]})
+	}
+
+	return withWarnings(t, warnings)
+}
+
+// clabEndpoint parses "node:interface" or {node: ..., interface: ...} endpoint
+func clabEndpoint(raw interface{}) (TopoEndpoint, error) {
+	switch ep := raw.(type) {
+	case string:
+		parts := strings.SplitN(ep, ":", 2)
+		if len(parts) != 2 {
+			return TopoEndpoint{}, fmt.Errorf("wrong endpoint %s, expected node:interface", ep)
+		}
+
+		return TopoEndpoint{Node: parts[0], IfName: parts[1]}, nil
+
+	case map[string]interface{}:
+		node, _ := ep["node"].(string)
+		ifname, _ := ep["interface"].(string)
+
+		if node == "" || ifname == "" {
+			return TopoEndpoint{}, fmt.Errorf("wrong endpoint %v, expected node and interface", ep)
+		}
+
+		return TopoEndpoint{Node: node, IfName: ifname}, nil
+	}
+
+	return TopoEndpoint{}, fmt.Errorf("wrong endpoint %v", raw)
+}
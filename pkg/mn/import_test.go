@@ -0,0 +1,163 @@
+package mn
+
+import (
+	"testing"
+)
+
+func TestImportMininet(t *testing.T) {
+	scheme, err := ImportMininet("--topo=tree,depth=2,fanout=2 --controller remote,ip=10.10.0.1 --ipbase 10.88.0.0/16 --mac")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(scheme.Switches) != 3 || len(scheme.Hosts) != 4 {
+		t.Fatalf("Unexpected tree:\n%s", scheme)
+	}
+
+	if ctrl := scheme.Switches[0].Controller; ctrl != "tcp:10.10.0.1:6653" {
+		t.Fatalf("\nExpected: tcp:10.10.0.1:6653\nObtained: %s", ctrl)
+	}
+
+	if cidr := scheme.Hosts[0].Links[0].Cidr; cidr != "10.88.0.1/16" {
+		t.Fatalf("\nExpected: 10.88.0.1/16\nObtained: %s", cidr)
+	}
+
+	if w := scheme.Warnings(); len(w) != 1 || w[0] != "Option --mac is ignored" {
+		t.Fatalf("Unexpected warnings: %v", w)
+	}
+
+	scheme, err = ImportMininet("linear,2,3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if h, found := scheme.GetHost("h3s2"); !found || len(scheme.Hosts) != 6 || h.Links[0].Peer.NodeName != "s2" {
+		t.Fatalf("Unexpected linear:\n%s", scheme)
+	}
+
+	for _, args := range []string{"torus,3,3", "tree,foo=2", "single,3 --controller=ovsc"} {
+		if _, err := ImportMininet(args); err == nil {
+			t.Fatal("Expected error for", args)
+		}
+	}
+}
+
+func TestImportMiniEdit(t *testing.T) {
+	scheme, err := ImportMiniEdit([]byte(`{
+    "application": {"ipBase": "10.89.0.0/16", "startCLI": "0", "switchType": "ovs"},
+    "controllers": [
+        {"opts": {"controllerProtocol": "tcp", "controllerType": "remote", "hostname": "c0", "remoteIP": "10.10.0.2", "remotePort": 6633}, "x": "50", "y": "50"}
+    ],
+    "hosts": [
+        {"number": "1", "opts": {"hostname": "h1", "nodeNum": 1, "ip": "10.89.1.1", "defaultRoute": "10.89.0.254", "sched": "host"}, "x": "10", "y": "10"},
+        {"number": "2", "opts": {"hostname": "h2", "nodeNum": 2, "sched": "host"}, "x": "20", "y": "10"}
+    ],
+    "links": [
+        {"dest": "s1", "opts": {}, "src": "h1"},
+        {"dest": "h2", "opts": {}, "src": "s1"},
+        {"dest": "r1", "opts": {}, "src": "s1"}
+    ],
+    "switches": [
+        {"number": "1", "opts": {"controllers": ["c0"], "hostname": "s1", "nodeNum": 1, "switchType": "default"}, "x": "15", "y": "30"},
+        {"number": "2", "opts": {"controllers": [], "hostname": "r1", "nodeNum": 2, "switchType": "legacyRouter"}, "x": "15", "y": "50"}
+    ],
+    "version": "2"
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s1, _ := scheme.GetSwitch("s1")
+	h1, _ := scheme.GetHost("h1")
+	h2, _ := scheme.GetHost("h2")
+
+	if _, found := scheme.GetHost("r1"); !found || s1.Controller != "tcp:10.10.0.2:6633" || len(s1.Ports) != 3 {
+		t.Fatalf("Unexpected scheme:\n%s", scheme)
+	}
+
+	if l := h1.Links[0]; l.Cidr != "10.89.1.1/16" || l.Routes[0].Gw != "10.89.0.254" {
+		t.Fatalf("Unexpected link of h1: %v", l)
+	}
+
+	if cidr := h2.Links[0].Cidr; cidr != "10.89.0.1/16" {
+		t.Fatalf("\nExpected: 10.89.0.1/16\nObtained: %s", cidr)
+	}
+
+	// hosts are numbered around explicit IPs
+	scheme, err = ImportMiniEdit([]byte(`{
+    "application": {"ipBase": "10.89.0.0/16"},
+    "hosts": [{"opts": {"hostname": "h1"}}, {"opts": {"hostname": "h2", "ip": "10.89.0.1"}}],
+    "switches": [{"opts": {"hostname": "s1"}}],
+    "links": [{"src": "h1", "dest": "s1"}, {"src": "h2", "dest": "s1"}]
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cidrs := scheme.Hosts[0].Links[0].Cidr + " " + scheme.Hosts[1].Links[0].Cidr; cidrs != "10.89.0.2/16 10.89.0.1/16" {
+		t.Fatalf("\nExpected: 10.89.0.2/16 10.89.0.1/16\nObtained: %s", cidrs)
+	}
+}
+
+func TestImportContainerlab(t *testing.T) {
+	scheme, err := ImportContainerlab([]byte(`
+name: clab1
+topology:
+  defaults:
+    kind: linux
+  nodes:
+    br1:
+      kind: ovs-bridge
+    h1:
+      image: alpine
+      exec:
+        - ip addr add 10.90.0.1/24 dev eth1
+        - ip route add default via 10.90.0.254
+    h2:
+      kind: linux
+      exec:
+        - ip address add 10.90.1.2/24 dev eth1
+  links:
+    - endpoints: ["h1:eth1", "br1:br1-h1"]
+    - endpoints:
+        - node: h2
+          interface: eth1
+        - node: br1
+          interface: br1-h2
+    - endpoints: ["h1:eth2", "h2:eth2"]
+    - endpoints: ["h1:eth0", "host:h1-mgmt"]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if scheme.Topology != "clab1" || len(scheme.Switches) != 1 || len(scheme.Hosts) != 2 {
+		t.Fatalf("Unexpected scheme:\n%s", scheme)
+	}
+
+	br1, _ := scheme.GetSwitch("br1")
+	h1, _ := scheme.GetHost("h1")
+	h2, _ := scheme.GetHost("h2")
+
+	if br1.Ports[0].Name != "br1-h1" || br1.Ports[1].Name != "br1-h2" {
+		t.Fatalf("Unexpected ports: %v", br1.Ports)
+	}
+
+	if l := h1.Links[0]; l.Name != "eth1" || l.Cidr != "10.90.0.1/24" || l.Routes[0].Dst != "0.0.0.0/0" {
+		t.Fatalf("Unexpected link of h1: %v", l)
+	}
+
+	if len(h2.Links) != 2 || h2.Links[0].Cidr != "10.90.1.2/24" || h2.Links[1].Cidr != noip {
+		t.Fatalf("Unexpected links of h2: %v", h2.Links)
+	}
+
+	if w := scheme.Warnings(); len(w) != 1 || w[0] != "Link h1:eth0 <-> host:h1-mgmt is skipped" {
+		t.Fatalf("Unexpected warnings: %v", w)
+	}
+
+	for fname, expected := range map[string]string{"lab.clab.yml": FormatContainerlab, "lab.mn": FormatMiniEdit, "lab.yml": FormatYAML} {
+		if format, _ := FormatByExt(fname); format != expected {
+			t.Fatalf("%s\nExpected: %s\nObtained: %s", fname, expected, format)
+		}
+	}
+}
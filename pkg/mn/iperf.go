@@ -0,0 +1,94 @@
+package mn
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/3d0c/mininet/pkg/perf"
+)
+
+// PerfCommand is a traffic generator, which is run inside hosts, see cmd/mn-perf
+var PerfCommand = "mn-perf"
+
+// Iperf measures throughput from the client host to the primary address
+// of the first server's link. Server is started for the measurement only.
+func (s *Scheme) Iperf(client, server string, o perf.Options) (perf.Result, error) {
+	c, found := s.GetHost(client)
+	if !found {
+		return perf.Result{}, fmt.Errorf("Host %s not found", client)
+	}
+
+	srv, found := s.GetHost(server)
+	if !found {
+		return perf.Result{}, fmt.Errorf("Host %s not found", server)
+	}
+
+	addr := ""
+	for _, l := range srv.Links {
+		if _, _, err := net.ParseCIDR(l.Cidr); err == nil {
+			addr = l.IP()
+			break
+		}
+	}
+
+	if addr == "" {
+		return perf.Result{}, fmt.Errorf("Host %s has no address", server)
+	}
+
+	path := FullPathFor(PerfCommand)
+	if path == "" {
+		return perf.Result{}, fmt.Errorf("%s not found in the PATH, please do \"go install ./cmd/mn-perf\"", PerfCommand)
+	}
+
+	p, err := srv.runProcess(path, "-s", "-p", strconv.Itoa(perf.DefaultPort))
+	if err != nil {
+		return perf.Result{}, err
+	}
+
+	defer p.Stop()
+
+	out, err := c.RunCommand(append([]string{path}, perfArgs(addr, o)...)...)
+	if err != nil {
+		return perf.Result{}, fmt.Errorf("Error: %v, output: %s", err, out)
+	}
+
+	return parsePerf(out)
+}
+
+// perfArgs returns client's arguments, zero options are mn-perf defaults
+func perfArgs(addr string, o perf.Options) []string {
+	args := []string{"-c", addr, "-p", strconv.Itoa(perf.DefaultPort), "-json"}
+
+	if o.Protocol == "udp" {
+		args = append(args, "-u")
+	}
+
+	if o.Duration > 0 {
+		args = append(args, "-t", o.Duration.String())
+	}
+
+	if o.Rate > 0 {
+		args = append(args, "-b", strconv.FormatInt(o.Rate, 10))
+	}
+
+	if o.Length > 0 {
+		args = append(args, "-l", strconv.Itoa(o.Length))
+	}
+
+	return args
+}
+
+// parsePerf parses the last line of the output, the previous ones are logs
+func parsePerf(out string) (perf.Result, error) {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+
+	r := perf.Result{}
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &r); err != nil {
+		return r, fmt.Errorf("Unable to parse %s output, error: %v, output: %s", PerfCommand, err, out)
+	}
+
+	return r, nil
+}
@@ -0,0 +1,73 @@
+package mn
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/3d0c/mininet/pkg/perf"
+	"github.com/3d0c/mininet/pkg/pool"
+)
+
+func TestPerfArgs(t *testing.T) {
+	args := strings.Join(perfArgs("10.0.0.2", perf.Options{Protocol: "udp", Duration: 5 * time.Second, Rate: 10000000}), " ")
+	if args != "-c 10.0.0.2 -p 5201 -json -u -t 5s -b 10000000" {
+		t.Fatal("Unexpected args:", args)
+	}
+
+	r, err := parsePerf("2020/05/01 10:00:00 Listening\n" + `{"protocol":"tcp","seconds":1,"bytes":1000,"bandwidth_bps":8000}` + "\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Protocol != "tcp" || r.Bandwidth != 8000 {
+		t.Fatal("Unexpected result:", r)
+	}
+}
+
+func TestIperf(t *testing.T) {
+	requireSystem(t)
+
+	if FullPathFor(PerfCommand) == "" {
+		t.Skip(PerfCommand, "isn't installed")
+	}
+
+	pool.ThePool("192.168.55.1/24")
+
+	h1, err := NewHost()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h1.Release()
+
+	h2, err := NewHost()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h2.Release()
+
+	p := NewLink(h1, h2, Link{}, Link{Impairment: &Impairment{Rate: "10mbit"}})
+
+	if err := p.Create(); err != nil {
+		t.Fatal(err)
+	}
+
+	if p, err = p.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	h1.AddLink(p.Left)
+	h2.AddLink(p.Right)
+
+	scheme := NewScheme().AddNode(h1).AddNode(h2)
+
+	// h2 sends, its egress is limited
+	r, err := scheme.Iperf(h2.NodeName(), h1.NodeName(), perf.Options{Duration: 2 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Bandwidth < 5000000 || r.Bandwidth > 12000000 {
+		t.Fatal("Expected about 10 Mbit/s, obtained:", r)
+	}
+}
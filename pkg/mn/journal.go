@@ -0,0 +1,54 @@
+package mn
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+type journalEntry struct {
+	name string
+	undo func() error
+}
+
+// Journal records applied changes together with the way to revert them
+type Journal struct {
+	entries []journalEntry
+}
+
+// NewJournal creates empty journal
+func NewJournal() *Journal {
+	return &Journal{}
+}
+
+// Record adds applied change, nil undo means nothing to revert
+func (j *Journal) Record(name string, undo func() error) {
+	j.entries = append(j.entries, journalEntry{name: name, undo: undo})
+}
+
+// Rollback reverts recorded changes in reverse order. It doesn't stop
+// on errors, all of them are collected and returned together.
+func (j *Journal) Rollback() error {
+	errs := make([]string, 0)
+
+	for i := len(j.entries) - 1; i >= 0; i-- {
+		entry := j.entries[i]
+		if entry.undo == nil {
+			continue
+		}
+
+		log.Println("Rolling back:", entry.name)
+
+		if err := entry.undo(); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", entry.name, err))
+		}
+	}
+
+	j.entries = nil
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
@@ -0,0 +1,227 @@
+package mn
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// JSONSchemaID is an ID of the published JSON Schema of the current version
+var JSONSchemaID = fmt.Sprintf("https://github.com/3d0c/mininet/cmd/schemes/scheme.v%d.schema.json", SchemeVersion)
+
+// schemaHints are additional constraints of the scalar fields
+var schemaHints = map[string]map[string]interface{}{
+	"Scheme.Version":  {"minimum": 0, "maximum": SchemeVersion},
+	"Scheme.Topology": {"description": "Topology ID, created resources are tagged with it"},
+	"Link.Cidr":       {"description": "Address with prefix length, e.g. 10.0.0.1/24, or noip"},
+	"Link.Addrs":      {"description": "Additional IPv4 or IPv6 addresses with prefix length, e.g. fd00::1/64"},
+	"Link.SLAAC":      {"description": "IPv6 addresses are autoconfigured from router advertisements, static otherwise"},
+	"Link.HwAddr":     {"pattern": "^([0-9a-fA-F]{2}:){5}[0-9a-fA-F]{2}$"},
+	"Link.Name":       {"maxLength": ifNameSize - 1},
+	"Link.NetNs":      {"description": "Network namespace of the link, root or empty is the root one"},
+	"Controller.Name": {"enum": knownControllers()},
+
+	"Impairment.Rate":      {"pattern": rateRe.String(), "description": "Bandwidth in tc units, e.g. 10mbit"},
+	"Impairment.Delay":     {"pattern": timeRe.String(), "description": "Delay, e.g. 50ms"},
+	"Impairment.Jitter":    {"pattern": timeRe.String(), "description": "Delay variation, requires Delay"},
+	"Impairment.Loss":      {"minimum": 0, "maximum": 100, "description": "Packet loss, percent"},
+	"Impairment.Duplicate": {"minimum": 0, "maximum": 100, "description": "Packet duplication, percent"},
+	"Impairment.Reorder":   {"minimum": 0, "maximum": 100, "description": "Packet reordering, percent, requires Delay"},
+	"Impairment.Limit":     {"minimum": 0, "description": "Queue size, packets"},
+}
+
+// JSONSchema returns JSON Schema of the scheme file, it's generated from
+// the Scheme, Host, Switch, Link, Impairment, Route, Peer, Cgroup, Controller
+// and Set
+func JSONSchema() ([]byte, error) {
+	g := &schemaGenerator{definitions: make(map[string]interface{})}
+
+	root := g.object(reflect.TypeOf(Scheme{}))
+	root["$schema"] = "http://json-schema.org/draft-07/schema#"
+	root["$id"] = JSONSchemaID
+	root["title"] = fmt.Sprintf("mininet scheme v%d", SchemeVersion)
+	root["properties"].(map[string]interface{})["$schema"] = map[string]interface{}{"type": "string"}
+	root["definitions"] = g.definitions
+
+	return json.MarshalIndent(root, "", "    ")
+}
+
+type schemaGenerator struct {
+	definitions map[string]interface{}
+}
+
+func (g *schemaGenerator) object(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+
+	for _, f := range jsonFields(t) {
+		s := g.typeSchema(f.Type)
+
+		for k, v := range schemaHints[t.Name()+"."+f.Name] {
+			s[k] = v
+		}
+
+		properties[f.Name] = s
+	}
+
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
+
+func (g *schemaGenerator) typeSchema(t reflect.Type) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return map[string]interface{}{
+			"oneOf": []interface{}{g.typeSchema(t.Elem()), map[string]interface{}{"type": "null"}},
+		}
+
+	case reflect.Struct:
+		if _, found := g.definitions[t.Name()]; !found {
+			// placeholder stops recursion
+			g.definitions[t.Name()] = nil
+			g.definitions[t.Name()] = g.object(t)
+		}
+
+		return map[string]interface{}{"$ref": "#/definitions/" + t.Name()}
+
+	case reflect.Slice:
+		return map[string]interface{}{"type": []string{"array", "null"}, "items": g.typeSchema(t.Elem())}
+
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	}
+
+	// Set's Value
+	return map[string]interface{}{"type": []string{"string", "number", "boolean"}}
+}
+
+// jsonField is a field of the json object
+type jsonField struct {
+	Name string
+	Type reflect.Type
+}
+
+// jsonFields returns fields of the struct the same way encoding/json
+// does: unexported and "-" fields are skipped, embedded structs are flattened
+func jsonFields(t reflect.Type) []jsonField {
+	result := make([]jsonField, 0, t.NumField())
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := strings.Split(f.Tag.Get("json"), ",")
+
+		if tag[0] == "-" {
+			continue
+		}
+
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+
+		if f.Anonymous && tag[0] == "" && ft.Kind() == reflect.Struct {
+			result = append(result, jsonFields(ft)...)
+			continue
+		}
+
+		if f.PkgPath != "" {
+			continue
+		}
+
+		name := f.Name
+		if tag[0] != "" {
+			name = tag[0]
+		}
+
+		result = append(result, jsonField{Name: name, Type: f.Type})
+	}
+
+	return result
+}
+
+// checkFields reports fields of the decoded json, which are unknown for the type.
+// Names are matched case-insensitively as encoding/json does.
+func checkFields(v interface{}, t reflect.Type, path string) ValidationError {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	problems := make(ValidationError, 0)
+
+	switch t.Kind() {
+	case reflect.Struct:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			break
+		}
+
+		fields := jsonFields(t)
+
+		for _, key := range sortedKeys(obj) {
+			if path == "$" && key == "$schema" {
+				continue
+			}
+
+			found := false
+
+			for _, f := range fields {
+				if strings.EqualFold(f.Name, key) {
+					problems = append(problems, checkFields(obj[key], f.Type, path+"."+key)...)
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				problems = append(problems, Problem{Path: path + "." + key, Message: fmt.Sprintf("unknown field %s of %s", key, t.Name())})
+			}
+		}
+
+	case reflect.Slice:
+		items, _ := v.([]interface{})
+
+		for i, item := range items {
+			problems = append(problems, checkFields(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	}
+
+	return problems
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+func knownControllers() []string {
+	result := make([]string, 0, len(cgroupControllers))
+
+	for name := range cgroupControllers {
+		result = append(result, name)
+	}
+
+	sort.Strings(result)
+
+	return result
+}
@@ -0,0 +1,80 @@
+package mn
+
+import (
+	"fmt"
+	"io/ioutil"
+	"testing"
+)
+
+func TestJSONSchemaPublished(t *testing.T) {
+	fname := fmt.Sprintf("scheme.v%d.schema.json", SchemeVersion)
+
+	published, err := ioutil.ReadFile("../../cmd/schemes/" + fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	generated, err := JSONSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(published) != string(generated)+"\n" {
+		t.Fatal("Published schema is outdated, run: mn-ctl schema > cmd/schemes/" + fname)
+	}
+}
+
+func TestSchemeVersion(t *testing.T) {
+	// version 0 file, as it was exported before versioning
+	scheme, err := NewSchemeFromData([]byte(`{
+    "Hosts": [
+        {
+            "Cgroup": {"Name": "", "Controllers": null},
+            "Name": "h1",
+            "Links": [{"Name": "eth0", "NodeName": "h1", "Cidr": "10.0.0.1/24", "PeerName": ""}]
+        }
+    ]
+}`), FormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if scheme.Version != SchemeVersion || scheme.Hosts[0].Cgroup != nil {
+		t.Fatalf("Scheme isn't migrated:\n%s", scheme)
+	}
+
+	// version 1 links were DOWN, until they were set up
+	scheme, err = NewSchemeFromData([]byte(`{
+    "Version": 1,
+    "Hosts": [{"Name": "h1", "Links": [{"Name": "eth0", "Cidr": "10.0.0.1/24", "State": "DOWN"}, {"Name": "eth1", "Cidr": "10.0.1.1/24", "State": "UP"}]}]
+}`), FormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l := scheme.Hosts[0].Links; l[0].State != "" || l[1].State != "UP" {
+		t.Fatalf("Scheme isn't migrated:\n%s", scheme)
+	}
+
+	if _, err := NewSchemeFromData([]byte(`{"Version": 100}`), FormatJSON); err == nil {
+		t.Fatal("Expected error for newer version")
+	}
+
+	_, err = NewSchemeFromData([]byte(`{
+    "$schema": "scheme.v2.schema.json",
+    "Version": 2,
+    "Switches": [{"Name": "s1", "Contoller": "tcp:127.0.0.1:6633"}],
+    "Hosts": [{"Name": "h1", "Links": [{"Name": "eth0", "cidr": "10.0.0.1/24", "Peer": {"Node": "s1"}}]}]
+}`), FormatJSON)
+	if err == nil {
+		t.Fatal("Expected error for unknown fields")
+	}
+
+	expected := "Invalid scheme:\n" +
+		"$.Hosts[0].Links[0].Peer.Node: unknown field Node of Peer\n" +
+		"$.Switches[0].Contoller: unknown field Contoller of Switch"
+
+	if err.Error() != expected {
+		t.Fatalf("\nExpected:\n%s\nObtained:\n%s", expected, err)
+	}
+}
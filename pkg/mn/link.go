@@ -1,7 +1,6 @@
 package mn
 
 import (
-	"errors"
 	"fmt"
 	"net"
 	"reflect"
@@ -28,19 +27,28 @@ type Peer struct {
 	NodeName string
 }
 
-// Link definition
+// Link definition, Cidr is the primary address, additional IPv4 and IPv6
+// ones are in Addrs. IPv6 addresses are static, unless SLAAC is set.
+// Impairment emulates link's bandwidth, delay, loss etc. State is UP or
+// DOWN once the link is set up or down, it's empty until then. Link, which
+// is declared DOWN, is kept down by Apply and Reconcile.
 type Link struct {
-	Cidr      string
-	HwAddr    string
-	Name      string
-	NodeName  string
-	NetNs     string
-	State     string
-	Routes    []Route
-	PeerName  string
-	Peer      Peer
-	patch     bool
-	ForceRoot bool `json:"-"`
+	Cidr       string
+	Addrs      []string `json:",omitempty"`
+	SLAAC      bool     `json:",omitempty"`
+	HwAddr     string
+	Name       string
+	NodeName   string
+	NetNs      string
+	State      string
+	Routes     []Route
+	Impairment *Impairment `json:",omitempty"`
+	PeerName   string      `json:"-"`
+	Peer       Peer
+	patch      bool
+	ForceRoot  bool `json:"-"`
+	exec       Executor
+	topology   string
 }
 
 const noip = "noip"
@@ -64,15 +72,31 @@ func NewLink(left, right Node, refs ...Link) Pair {
 		result.Right = result.Right.SetNodeName(right).SetName(right, "pp").SetPatch()
 	} else {
 		result.Left = result.Left.SetCidr().SetHwAddr().
-			SetNetNs(left).SetName(right, "eth").SetNodeName(left).SetState("DOWN").SetRoute()
+			SetNetNs(left).SetCidr6().SetName(right, "eth").SetNodeName(left).SetRoute()
 
 		result.Right = result.Right.SetCidr().SetHwAddr().
-			SetNetNs(right).SetName(right, "eth").SetNodeName(right).SetState("DOWN").SetRoute()
+			SetNetNs(right).SetCidr6().SetName(right, "eth").SetNodeName(right).SetRoute()
 	}
 
 	result.Left = result.Left.SetPeer(result.Right)
 	result.Right = result.Right.SetPeer(result.Left)
 
+	if result.Left.exec == nil {
+		result.Left.exec = nodeExecutor(left)
+	}
+
+	if result.Right.exec == nil {
+		result.Right.exec = nodeExecutor(right)
+	}
+
+	if result.Left.topology == "" {
+		result.Left.topology = nodeTopology(left)
+	}
+
+	if result.Right.topology == "" {
+		result.Right.topology = nodeTopology(right)
+	}
+
 	return result
 }
 
@@ -98,25 +122,18 @@ func (pr Pair) ByNodeName(n Node) Link {
 	return pr.Right
 }
 
-// Create creates link between veth pair
+// Create creates link between veth pair, both ends are tagged
+// with the topology alias
 func (pr Pair) Create() error {
-	command := []string{"link", "add", "name", pr.Left.Name, "type", "veth", "peer", "name", pr.Right.Name}
-
-	if pr.Right.NetNs != "" {
-		command = append(command, "netns", pr.Right.NetNs)
-	}
-
-	if out, err := RunCommand("ip", command...); err != nil {
-		return fmt.Errorf("Error: %v, output: %s", err, out)
+	if err := pr.Left.backend().CreatePair(pr.Left, pr.Right); err != nil {
+		return err
 	}
 
-	if pr.Left.NetNs != "" {
-		if err := pr.Left.MoveToNs(pr.Left.NetNs); err != nil {
-			return err
-		}
+	if err := pr.Left.tag(); err != nil {
+		return err
 	}
 
-	return nil
+	return pr.Right.tag()
 }
 
 // Up sets pair on
@@ -126,23 +143,39 @@ func (pr Pair) Up() (Pair, error) {
 	}
 
 	if err := pr.Left.ApplyCidr(); err != nil {
-		return pr, errors.New(fmt.Sprint("Unable to Left.ApplyCidr, error:", err))
+		return pr, fmt.Errorf("Unable to Left.ApplyCidr, error: %w", err)
 	}
 
 	if err := pr.Right.ApplyCidr(); err != nil {
-		return pr, errors.New(fmt.Sprint("Unable to Right.ApplyCidr, error:", err))
+		return pr, fmt.Errorf("Unable to Right.ApplyCidr, error: %w", err)
+	}
+
+	for _, l := range []Link{pr.Left, pr.Right} {
+		if err := l.ApplyIPv6(); err != nil {
+			return pr, fmt.Errorf("Unable to ApplyIPv6(), error: %w", err)
+		}
+
+		if err := l.ApplyAddrs(); err != nil {
+			return pr, fmt.Errorf("Unable to ApplyAddrs(), error: %w", err)
+		}
 	}
 
 	if err := pr.Left.Up(); err != nil {
-		return pr, errors.New(fmt.Sprint("Unable to Left.Up(), error:", err))
+		return pr, fmt.Errorf("Unable to Left.Up(), error: %w", err)
 	}
 
 	if err := pr.Right.Up(); err != nil {
-		return pr, errors.New(fmt.Sprint("Unable to Right.Up(), error:", err))
+		return pr, fmt.Errorf("Unable to Right.Up(), error: %w", err)
+	}
+
+	for _, l := range []Link{pr.Left, pr.Right} {
+		if err := l.ApplyImpairment(); err != nil {
+			return pr, fmt.Errorf("Unable to ApplyImpairment(), error: %w", err)
+		}
 	}
 
 	if err := pr.Right.ApplyRoutes(); err != nil {
-		return pr, errors.New(fmt.Sprint("Unable to ApplyRoutes(), error:", err))
+		return pr, fmt.Errorf("Unable to ApplyRoutes(), error: %w", err)
 	}
 
 	pr.Left = pr.Left.SetState("UP")
@@ -153,6 +186,53 @@ func (pr Pair) Up() (Pair, error) {
 	return pr, nil
 }
 
+// Down sets both links of the pair off
+func (pr Pair) Down() (Pair, error) {
+	if pr.Left.patch {
+		return pr, fmt.Errorf("Patch ports %s and %s can't be set down", pr.Left.Name, pr.Right.Name)
+	}
+
+	for _, l := range []*Link{&pr.Left, &pr.Right} {
+		if err := l.Down(); err != nil {
+			return pr, fmt.Errorf("Unable to Down(), error: %w", err)
+		}
+
+		*l = l.SetState("DOWN")
+	}
+
+	fmt.Println("[Link]", pr.Left.NodeName, pr.Left.Name, "<-/->", pr.Right.NodeName, pr.Right.Name)
+
+	return pr, nil
+}
+
+// Restore sets pair, which has been set down by Down, on again. Addresses
+// are kept, routes flushed by kernel are replaced.
+func (pr Pair) Restore() (Pair, error) {
+	if pr.Left.patch {
+		return pr, nil
+	}
+
+	for _, l := range []*Link{&pr.Left, &pr.Right} {
+		if err := l.Up(); err != nil {
+			return pr, fmt.Errorf("Unable to Up(), error: %w", err)
+		}
+
+		*l = l.SetState("UP")
+	}
+
+	for _, l := range []Link{pr.Left, pr.Right} {
+		for _, route := range l.Routes {
+			if err := l.route("replace", route); err != nil {
+				return pr, fmt.Errorf("Unable to restore route, error: %w", err)
+			}
+		}
+	}
+
+	fmt.Println("[Link]", pr.Left.NodeName, pr.Left.Name, "<--->", pr.Right.NodeName, pr.Right.Name)
+
+	return pr, nil
+}
+
 // Release pair
 func (pr Pair) Release() {
 	pr.Left.Release()
@@ -166,55 +246,106 @@ func (pr Pair) IsPatch() bool {
 
 // Release the link
 func (l Link) Release() {
-	command := []string{"ip", "link", "delete", l.Name}
-
-	if l.NetNs != "" {
-		command = append([]string{"ip", "netns", "exec", l.NetNs}, command...)
-	}
+	l.Delete()
+}
 
-	RunCommand(command[0], command[1:]...)
+// Delete deletes the link, veth peer is deleted by kernel
+func (l Link) Delete() error {
+	return l.backend().Delete(l)
 }
 
 // ApplyMac applies MAC address
 func (l Link) ApplyMac() error {
-	if out, err := RunCommand("ip", "link", "set", "dev", l.Name, "address", l.HwAddr); err != nil {
-		return fmt.Errorf("Error: %v, output: %s", err, out)
-	}
-
-	return nil
+	return l.backend().SetHwAddr(l)
 }
 
 // Up sets link to on
 func (l Link) Up() error {
-	command := []string{"ip", "link", "set", l.Name, "up"}
+	return l.backend().SetUp(l)
+}
 
-	if l.NetNs != "" {
-		command = append([]string{"ip", "netns", "exec", l.NetNs}, command...)
+// Down sets link to off, kernel flushes routes of the link
+func (l Link) Down() error {
+	return l.backend().SetDown(l)
+}
+
+// ApplyCidr applies CIDR to the link
+func (l Link) ApplyCidr() error {
+	if _, _, err := net.ParseCIDR(l.Cidr); err != nil {
+		// omit setting ip, by passing some garbage to input
+		return nil
 	}
 
-	if out, err := RunCommand(command[0], command[1:]...); err != nil {
-		return fmt.Errorf("Error: %v, output: %s", err, out)
+	return l.backend().AddAddr(l)
+}
+
+// RemoveCidr removes link's CIDR from the link
+func (l Link) RemoveCidr() error {
+	if _, _, err := net.ParseCIDR(l.Cidr); err != nil {
+		return nil
 	}
 
-	l.State = "UP"
+	return l.backend().DelAddr(l)
+}
+
+// ApplyAddrs applies additional addresses to the link
+func (l Link) ApplyAddrs() error {
+	for _, addr := range l.Addrs {
+		if err := l.withCidr(addr).ApplyCidr(); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
 
-// ApplyCidr applies CIDR to the link
-func (l Link) ApplyCidr() error {
-	if _, _, err := net.ParseCIDR(l.Cidr); err != nil {
-		// omit setting ip, by passing some garbage to input
+// RemoveAddrs removes additional addresses from the link
+func (l Link) RemoveAddrs() error {
+	for _, addr := range l.Addrs {
+		if err := l.withCidr(addr).RemoveCidr(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// withCidr returns copy of the link with the address, backends apply Cidr
+func (l Link) withCidr(cidr string) Link {
+	l.Cidr = cidr
+	l.Addrs = nil
+
+	return l
+}
+
+// ApplyIPv6 chooses IPv6 addressing of the link. SLAAC link accepts router
+// advertisements, even if forwarding is enabled. Static one doesn't, so it has
+// only addresses of the scheme. Links without IPv6 addresses are untouched.
+func (l Link) ApplyIPv6() error {
+	if !l.SLAAC && !l.HasIPv6() {
 		return nil
 	}
 
-	command := []string{"ip", "addr", "add", l.Cidr, "dev", l.Name}
+	autoconf, acceptRa := "0", "0"
+	if l.SLAAC {
+		autoconf, acceptRa = "1", "2"
+	}
 
+	// static addresses survive link down, like IPv4 ones
+	return l.sysctl(
+		"net.ipv6.conf."+l.Name+".autoconf="+autoconf,
+		"net.ipv6.conf."+l.Name+".accept_ra="+acceptRa,
+		"net.ipv6.conf."+l.Name+".keep_addr_on_down=1",
+	)
+}
+
+func (l Link) sysctl(values ...string) error {
+	commands := append([]string{"sysctl", "-w"}, values...)
 	if l.NetNs != "" {
-		command = append([]string{"ip", "netns", "exec", l.NetNs}, command...)
+		commands = append([]string{"ip", "netns", "exec", l.NetNs}, commands...)
 	}
 
-	if out, err := RunCommand(command[0], command[1:]...); err != nil {
+	if out, err := l.executor().Run(commands[0], commands[1:]...); err != nil {
 		return fmt.Errorf("Error: %v, output: %s", err, out)
 	}
 
@@ -224,33 +355,78 @@ func (l Link) ApplyCidr() error {
 // ApplyRoutes adds routing rule to the link
 func (l Link) ApplyRoutes() error {
 	for _, route := range l.Routes {
-		commands := []string{"route", "add", "-net", route.Dst, "gw", route.Gw}
-		if l.NetNs != "" {
-			commands = append([]string{"ip", "netns", "exec", l.NetNs}, commands...)
+		if err := l.route("add", route); err != nil {
+			return err
 		}
+	}
 
-		out, err := RunCommand(commands[0], commands[1:]...)
-		if err != nil {
-			return fmt.Errorf("Error: %v, output: %s", err, out)
+	return nil
+}
+
+// RemoveRoutes removes link's routing rules
+func (l Link) RemoveRoutes() error {
+	for _, route := range l.Routes {
+		if err := l.route("del", route); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
+// route runs "ip route", IPv6 routes are recognized by the gateway
+func (l Link) route(action string, r Route) error {
+	commands := []string{"ip", "route", action, r.Dst, "via", r.Gw, "dev", l.Name}
+	if isIPv6(r.Gw) {
+		commands = append([]string{"ip", "-6"}, commands[1:]...)
+	}
+
+	if l.NetNs != "" {
+		commands = append([]string{"ip", "netns", "exec", l.NetNs}, commands...)
+	}
+
+	out, err := l.executor().Run(commands[0], commands[1:]...)
+	if err != nil {
+		return fmt.Errorf("Error: %v, output: %s", err, out)
+	}
+
+	return nil
+}
+
 // Exists checks wheter link exist or not
 func (l Link) Exists() bool {
-	_, err := RunCommand("ip", "link", "show", l.Name)
-	return err == nil
+	return l.backend().Exists(l)
 }
 
 // MoveToNs moves link to another network namespace
 func (l Link) MoveToNs(netns string) error {
-	if out, err := RunCommand("ip", "link", "set", l.Name, "netns", netns); err != nil {
-		return fmt.Errorf("Error: %v, output: %s", err, out)
-	}
+	return l.backend().MoveToNs(l, netns)
+}
 
-	return nil
+// SetExecutor sets executor for the link commands. Link with explicitly set
+// executor is managed by ExecBackend instead of the default one.
+func (l Link) SetExecutor(e Executor) Link {
+	l.exec = e
+	return l
+}
+
+// SetTopology sets ID of the topology, which owns the link
+func (l Link) SetTopology(id string) Link {
+	l.topology = id
+	return l
+}
+
+// tag marks link as owned by its topology
+func (l Link) tag() error {
+	return l.backend().SetAlias(l, linkAlias(l.topology))
+}
+
+func (l Link) executor() Executor {
+	return executorOrDefault(l.exec)
+}
+
+func (l Link) backend() LinkBackend {
+	return backendFor(l.exec)
 }
 
 // SetCidr sets next CIDR from the pool to the link
@@ -271,6 +447,18 @@ func (l Link) SetHwAddr() Link {
 	return l
 }
 
+// SetCidr6 adds next IPv6 CIDR from the pool to the namespaced link,
+// if IPv6 network of the pool is set
+func (l Link) SetCidr6() Link {
+	if pool.ThePool().Network6() == "" || l.NetNs == "" || l.Cidr == noip || l.HasIPv6() {
+		return l
+	}
+
+	l.Addrs = append(l.Addrs, pool.ThePool().NextCidr6())
+
+	return l
+}
+
 // SetName sets the name,
 // interface pairs naming rules:
 //   left (host node)          {peer_host}-{prefix}X
@@ -347,9 +535,52 @@ func (l Link) IP() string {
 	return ip.String()
 }
 
+// Cidrs returns all addresses of the link, the primary one is the first
+func (l Link) Cidrs() []string {
+	result := make([]string, 0, len(l.Addrs)+1)
+
+	if _, _, err := net.ParseCIDR(l.Cidr); err == nil {
+		result = append(result, l.Cidr)
+	}
+
+	return append(result, l.Addrs...)
+}
+
+// HasIPv6 checks whether link has IPv6 address
+func (l Link) HasIPv6() bool {
+	for _, cidr := range l.Cidrs() {
+		if isIPv6(cidr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isIPv6 checks whether address or CIDR is IPv6 one
+func isIPv6(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		ip, _, _ = net.ParseCIDR(addr)
+	}
+
+	return ip != nil && ip.To4() == nil
+}
+
 // Links is a set of Links
 type Links []Link
 
+// ByName search link by interface name
+func (ls Links) ByName(name string) (Link, bool) {
+	for _, link := range ls {
+		if link.Name == name {
+			return link, true
+		}
+	}
+
+	return Link{}, false
+}
+
 // LinkByPeer search link by peer
 func (ls Links) LinkByPeer(peer Peer) Link {
 	for _, link := range ls {
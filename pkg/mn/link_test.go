@@ -1,6 +1,8 @@
 package mn
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"reflect"
 	"testing"
@@ -13,6 +15,8 @@ func init() {
 }
 
 func TestProperties(t *testing.T) {
+	requireSystem(t)
+
 	n1, err := NewSwitch("left")
 	if err != nil {
 		t.Fatal(err)
@@ -73,6 +77,8 @@ func TestProperties(t *testing.T) {
 }
 
 func TestLink(t *testing.T) {
+	requireSystem(t)
+
 	h1, err := NewSwitch()
 	if err != nil {
 		t.Fatal(err)
@@ -95,38 +101,32 @@ func TestLink(t *testing.T) {
 			refs: []Link{{Cidr: "192.168.66.1/24", Routes: []Route{}}, {Cidr: "192.168.66.2/24", Routes: []Route{{"0.0.0.0/0", "192.168.66.1"}}}},
 			expected: Pair{
 				Link{
-					"192.168.66.1/24",
-					"00:00:00:00:00:00",
-					h2.NodeName() + "-eth0",
-					h1.NodeName(),
-					"",
-					"DOWN",
-					[]Route{},
-					"",
-					Peer{
+					Cidr:     "192.168.66.1/24",
+					HwAddr:   "00:00:00:00:00:00",
+					Name:     h2.NodeName() + "-eth0",
+					NodeName: h1.NodeName(),
+					NetNs:    "",
+					Routes:   []Route{},
+					PeerName: "",
+					Peer: Peer{
 						Name:     "veth0",
 						IfName:   "veth0",
 						NodeName: h2.NodeName(),
 					},
-					false,
-					false,
 				},
 				Link{
-					"192.168.66.2/24",
-					"00:00:00:00:00:00",
-					"veth0",
-					h2.NodeName(),
-					h2.NodeName(),
-					"DOWN",
-					[]Route{{"0.0.0.0/0", "192.168.66.1"}},
-					"",
-					Peer{
+					Cidr:     "192.168.66.2/24",
+					HwAddr:   "00:00:00:00:00:00",
+					Name:     "veth0",
+					NodeName: h2.NodeName(),
+					NetNs:    h2.NodeName(),
+					Routes:   []Route{{"0.0.0.0/0", "192.168.66.1"}},
+					PeerName: "",
+					Peer: Peer{
 						Name:     h2.NodeName() + "-eth0",
 						IfName:   h2.NodeName() + "-eth0",
 						NodeName: h1.NodeName(),
 					},
-					false,
-					false,
 				},
 			},
 		},
@@ -213,6 +213,8 @@ func TestLink(t *testing.T) {
 }
 
 func TestPatchPort(t *testing.T) {
+	requireSystem(t)
+
 	s1, err := NewSwitch("s1")
 	if err != nil {
 		t.Fatal(err)
@@ -264,6 +266,8 @@ func TestPatchPort(t *testing.T) {
 }
 
 func TestHostToHost(t *testing.T) {
+	requireSystem(t)
+
 	pool.ThePool("192.168.55.1/24")
 
 	h1, err := NewHost()
@@ -296,3 +300,56 @@ func TestHostToHost(t *testing.T) {
 		t.Fatal("Ping failed:", err)
 	}
 }
+
+func TestLinkBackends(t *testing.T) {
+	requireSystem(t)
+
+	nl, err := NewNetlinkBackend()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer SetLinkBackend(DefaultLinkBackend())
+
+	for i, b := range []LinkBackend{NewExecBackend(), nl} {
+		SetLinkBackend(b)
+
+		h1, err := NewHost()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer h1.Release()
+
+		h2, err := NewHost()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer h2.Release()
+
+		p := NewLink(h1, h2, Link{Cidr: fmt.Sprintf("192.168.7%d.1/24", i)}, Link{Cidr: fmt.Sprintf("192.168.7%d.2/24", i)})
+
+		if err := p.Create(); err != nil {
+			t.Fatal(err)
+		}
+
+		if !p.Left.Exists() || !p.Right.Exists() {
+			t.Fatal("Expected links", p.Left.Name, p.Right.Name, "exist")
+		}
+
+		if p, err = p.Up(); err != nil {
+			t.Fatal(err)
+		}
+
+		if !ifaceAddr(p.Right.Name, h2.NetNs().Name(), p.Right.IP()) {
+			t.Fatal("Expecting ipv4", p.Right.IP(), "not found")
+		}
+
+		// the same pair can't be created twice
+		err = p.Create()
+
+		var lerr *LinkError
+		if !errors.As(err, &lerr) {
+			t.Fatal("Expected LinkError, obtained:", err)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package mn
+
+import (
+	"fmt"
+)
+
+// SchemeVersion is a current version of the scheme format. Files without
+// version are version 0, they are migrated on loading.
+const SchemeVersion = 2
+
+// migrations[N] converts decoded scheme of version N into version N+1
+var migrations = []func(scheme map[string]interface{}){
+	// 0 -> 1: Link's PeerName was never used, empty Cgroup of the old
+	// exports means no cgroup, instead of cgroup without name
+	func(scheme map[string]interface{}) {
+		for _, sw := range objects(scheme["Switches"]) {
+			for _, port := range objects(sw["Ports"]) {
+				delete(port, "PeerName")
+			}
+		}
+
+		for _, h := range objects(scheme["Hosts"]) {
+			for _, l := range objects(h["Links"]) {
+				delete(l, "PeerName")
+			}
+
+			if cg, ok := h["Cgroup"].(map[string]interface{}); ok && (cg["Name"] == nil || cg["Name"] == "") {
+				delete(h, "Cgroup")
+			}
+		}
+	},
+	// 1 -> 2: every new link was DOWN, it wasn't tracked and the link
+	// was set up anyway, so it's unknown state now
+	func(scheme map[string]interface{}) {
+		links := make([]map[string]interface{}, 0)
+
+		for _, sw := range objects(scheme["Switches"]) {
+			links = append(links, objects(sw["Ports"])...)
+		}
+
+		for _, h := range objects(scheme["Hosts"]) {
+			links = append(links, objects(h["Links"])...)
+		}
+
+		for _, l := range links {
+			if l["State"] == "DOWN" {
+				delete(l, "State")
+			}
+		}
+	},
+}
+
+// migrate converts decoded scheme of any older version into the current one
+func migrate(scheme map[string]interface{}) error {
+	version := 0
+
+	if v, found := scheme["Version"]; found {
+		f, ok := v.(float64)
+		if !ok || f != float64(int(f)) || f < 0 {
+			return fmt.Errorf("Wrong scheme version: %v", v)
+		}
+
+		version = int(f)
+	}
+
+	if version > SchemeVersion {
+		return fmt.Errorf("Scheme version %d is newer than supported %d", version, SchemeVersion)
+	}
+
+	for ; version < SchemeVersion; version++ {
+		migrations[version](scheme)
+	}
+
+	scheme["Version"] = SchemeVersion
+
+	return nil
+}
+
+// objects returns json objects of the array, everything else is skipped
+func objects(v interface{}) []map[string]interface{} {
+	items, _ := v.([]interface{})
+	result := make([]map[string]interface{}, 0, len(items))
+
+	for _, item := range items {
+		if obj, ok := item.(map[string]interface{}); ok {
+			result = append(result, obj)
+		}
+	}
+
+	return result
+}
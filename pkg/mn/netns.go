@@ -13,9 +13,7 @@ import "C"
 import (
 	"fmt"
 	"log"
-	"os"
 	"strings"
-	"syscall"
 )
 
 // NetnsRunDir default netns run dir
@@ -23,7 +21,10 @@ const NetnsRunDir = "/var/run/netns"
 
 // NetNs definition
 type NetNs struct {
-	name string
+	name     string
+	exec     Executor
+	topology string
+	created  bool
 }
 
 // NewNetNs creates a NetNs instance
@@ -43,18 +44,24 @@ func NewNetNs(name string) (*NetNs, error) {
 	return netns, nil
 }
 
-// Create network namespace
-func (n NetNs) Create() error {
-	if out, err := RunCommand("ip", "netns", "add", n.name); err != nil {
+// Create network namespace, its owner is recorded in the StateDir
+func (n *NetNs) Create() error {
+	if out, err := executorOrDefault(n.exec).Run("ip", "netns", "add", n.name); err != nil {
 		return fmt.Errorf("Error: %v, output: %s", err, out)
 	}
 
+	n.created = true
+
+	if err := setNetnsOwner(n.name, n.topology); err != nil {
+		log.Println("Unable to record owner of netns", n.name, err)
+	}
+
 	return nil
 }
 
 // Exists check whether network namespace exists or not
 func (n NetNs) Exists() bool {
-	out, err := RunCommand("ip", "netns", "list")
+	out, err := executorOrDefault(n.exec).Run("ip", "netns", "list")
 	if err != nil {
 		log.Printf("Error: %v, output: %s", err, out)
 		return true
@@ -73,15 +80,31 @@ func (n NetNs) Release() error {
 		return nil
 	}
 
-	name := NetnsRunDir + "/" + n.name
+	if out, err := executorOrDefault(n.exec).Run("ip", "netns", "delete", n.name); err != nil {
+		return fmt.Errorf("Error: %v, output: %s", err, out)
+	}
 
-	syscall.Unmount(name, syscall.MNT_DETACH)
+	delNetnsOwner(n.name)
 
-	if err := os.Remove(name); err != nil {
-		return err
+	return nil
+}
+
+// SetExecutor sets executor for netns commands
+func (n *NetNs) SetExecutor(e Executor) {
+	n.exec = e
+}
+
+// SetTopology sets ID of the topology, which owns network namespace.
+// Owner of the namespace, which has been created already, e.g. by NewHost
+// before the host is added to the scheme, is recorded again.
+func (n *NetNs) SetTopology(id string) {
+	if n.created && n.topology != id {
+		if err := setNetnsOwner(n.name, id); err != nil {
+			log.Println("Unable to record owner of netns", n.name, err)
+		}
 	}
 
-	return nil
+	n.topology = id
 }
 
 // Name getter for network namespace
@@ -1,13 +1,36 @@
 package mn
 
 import (
+	"flag"
+	"io/ioutil"
 	"log"
 	"os"
 	"strings"
 	"testing"
 )
 
-func init() {
+func TestMain(m *testing.M) {
+	flag.Parse()
+
+	// system tests are skipped in short mode, so unit tests can run unprivileged
+	if !testing.Short() {
+		checkPrerequisites()
+	}
+
+	dir, err := ioutil.TempDir("", "mn-state")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	StateDir = dir
+
+	code := m.Run()
+
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func checkPrerequisites() {
 	_, err := os.Stat(os.Getenv("GOPATH") + "/bin/mn-vmmock")
 	if os.IsNotExist(err) {
 		log.Println("Test cases depend from some binaries. Please do \"go install ./...\" before")
@@ -29,6 +52,8 @@ func init() {
 }
 
 func TestNewHost(t *testing.T) {
+	requireSystem(t)
+
 	h, err := NewHost(hostname(65535))
 	if err != nil {
 		t.Fatal(err)
@@ -44,6 +69,8 @@ func TestNewHost(t *testing.T) {
 }
 
 func TestHostRunProcess(t *testing.T) {
+	requireSystem(t)
+
 	h, err := NewHost()
 	if err != nil {
 		t.Fatal(err)
@@ -78,6 +105,8 @@ func TestHostRunProcess(t *testing.T) {
 }
 
 func TestRouter(t *testing.T) {
+	requireSystem(t)
+
 	r, err := NewRouter()
 	if err != nil {
 		t.Fatal(err)
@@ -91,6 +120,8 @@ func TestRouter(t *testing.T) {
 }
 
 func TestSwitchExists(t *testing.T) {
+	requireSystem(t)
+
 	s1, err := NewSwitch()
 	if err != nil {
 		t.Fatal(err)
@@ -109,6 +140,8 @@ func TestSwitchExists(t *testing.T) {
 }
 
 func TestNetNsExists(t *testing.T) {
+	requireSystem(t)
+
 	ns1, err := NewNetNs(hostname())
 	if err != nil {
 		t.Fatal(err)
@@ -0,0 +1,92 @@
+package mn
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultTopology is an ID of the topology, which owns resources
+// created by nodes which don't belong to the tagged scheme
+var DefaultTopology = "mininet"
+
+// StateDir keeps runtime state, e.g. owners of network namespaces
+var StateDir = "/var/run/mininet"
+
+const (
+	// topologyKey is the OVS external_ids key of bridges
+	topologyKey = "mn-topology"
+	// aliasPrefix is a prefix of veth alias, followed by topology ID
+	aliasPrefix = "mn:"
+)
+
+func topologyOrDefault(id string) string {
+	if id == "" {
+		return DefaultTopology
+	}
+
+	return id
+}
+
+// linkAlias returns alias, which marks veth as owned by the topology
+func linkAlias(id string) string {
+	return aliasPrefix + topologyOrDefault(id)
+}
+
+// nodeTopology returns topology ID explicitly set to the node
+func nodeTopology(n Node) string {
+	switch t := n.(type) {
+	case *Host:
+		return t.topology
+	case *Switch:
+		return t.topology
+	}
+
+	return ""
+}
+
+func netnsOwnerDir() string {
+	return filepath.Join(StateDir, "netns")
+}
+
+func netnsOwnerFile(name string) string {
+	return filepath.Join(netnsOwnerDir(), name)
+}
+
+// setNetnsOwner records topology ID, which network namespace belongs to
+func setNetnsOwner(name, id string) error {
+	if err := os.MkdirAll(netnsOwnerDir(), 0755); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(netnsOwnerFile(name), []byte(topologyOrDefault(id)+"\n"), 0644)
+}
+
+func delNetnsOwner(name string) {
+	os.Remove(netnsOwnerFile(name))
+}
+
+// ownedNetns returns names of network namespaces owned by the topology,
+// including ones left by crashed runs
+func ownedNetns(id string) []string {
+	result := make([]string, 0)
+
+	files, err := ioutil.ReadDir(netnsOwnerDir())
+	if err != nil {
+		return result
+	}
+
+	for _, f := range files {
+		b, err := ioutil.ReadFile(netnsOwnerFile(f.Name()))
+		if err != nil {
+			continue
+		}
+
+		if strings.TrimSpace(string(b)) == topologyOrDefault(id) {
+			result = append(result, f.Name())
+		}
+	}
+
+	return result
+}
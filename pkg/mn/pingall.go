@@ -0,0 +1,230 @@
+package mn
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// PingOptions of PingAll, zero values are defaults: 1 ping of every
+// address, 1s timeout and 16 pings in parallel
+type PingOptions struct {
+	Count    int
+	Timeout  time.Duration
+	Parallel int
+}
+
+// PingResult is a result of pinging of the host's address
+type PingResult struct {
+	Src      string  `json:"src" yaml:"src"`
+	Dst      string  `json:"dst" yaml:"dst"`
+	Addr     string  `json:"addr" yaml:"addr"`
+	Sent     int     `json:"sent" yaml:"sent"`
+	Received int     `json:"received" yaml:"received"`
+	Loss     float64 `json:"loss" yaml:"loss"`
+	RTT      float64 `json:"rtt_ms" yaml:"rtt_ms"`
+	Error    string  `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// PingReport is a list of results ordered by source, destination and address
+type PingReport []PingResult
+
+var (
+	pingStatsRe = regexp.MustCompile(`(\d+) packets transmitted, (\d+) (packets )?received`)
+	pingRttRe   = regexp.MustCompile(`= [\d.]+/([\d.]+)/`)
+)
+
+// PingAll pings from every host's netns the primary addresses of all
+// links of other hosts, see Link.IP. Pings are run in parallel, failed
+// ones are reported, not returned as an error.
+func (s Scheme) PingAll(opts ...PingOptions) (PingReport, error) {
+	o := PingOptions{}
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+
+	if o.Count <= 0 {
+		o.Count = 1
+	}
+
+	if o.Timeout <= 0 {
+		o.Timeout = time.Second
+	}
+
+	if o.Parallel <= 0 {
+		o.Parallel = 16
+	}
+
+	report := make(PingReport, 0)
+	hosts := make([]*Host, 0)
+
+	for _, src := range s.Hosts {
+		for _, dst := range s.Hosts {
+			if src == dst {
+				continue
+			}
+
+			for _, l := range dst.Links {
+				if _, _, err := net.ParseCIDR(l.Cidr); err != nil {
+					continue
+				}
+
+				report = append(report, PingResult{Src: src.Name, Dst: dst.Name, Addr: l.IP()})
+				hosts = append(hosts, src)
+			}
+		}
+	}
+
+	if len(report) == 0 {
+		return nil, fmt.Errorf("Nothing to ping, at least two hosts with addresses are required")
+	}
+
+	wg := sync.WaitGroup{}
+	sem := make(chan struct{}, o.Parallel)
+
+	for i := range report {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(r *PingResult, src *Host) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			r.ping(src, o)
+		}(&report[i], hosts[i])
+	}
+
+	wg.Wait()
+
+	return report, nil
+}
+
+func (r *PingResult) ping(src *Host, o PingOptions) {
+	wait := strconv.Itoa(int(math.Ceil(o.Timeout.Seconds())))
+
+	// ping exits with 1 if there is no reply, statistics are printed anyway
+	out, err := src.RunCommand("ping", "-n", "-q", "-c", strconv.Itoa(o.Count), "-i", "0.2", "-W", wait, r.Addr)
+
+	if perr := r.parse(out); perr != nil {
+		r.Sent, r.Received, r.Loss = o.Count, 0, 100
+		r.Error = perr.Error()
+
+		if err != nil {
+			r.Error = fmt.Sprintf("Error: %v, output: %s", err, strings.TrimSpace(out))
+		}
+	}
+}
+
+// parse parses statistics of iputils or busybox ping
+func (r *PingResult) parse(out string) error {
+	m := pingStatsRe.FindStringSubmatch(out)
+	if m == nil {
+		return fmt.Errorf("Unable to parse ping output: %s", strings.TrimSpace(out))
+	}
+
+	r.Sent, _ = strconv.Atoi(m[1])
+	r.Received, _ = strconv.Atoi(m[2])
+
+	if r.Sent > 0 {
+		r.Loss = math.Round(float64(r.Sent-r.Received)*10000/float64(r.Sent)) / 100
+	}
+
+	if m := pingRttRe.FindStringSubmatch(out); m != nil {
+		r.RTT, _ = strconv.ParseFloat(m[1], 64)
+	}
+
+	return nil
+}
+
+// Sent returns number of sent pings
+func (pr PingReport) Sent() int {
+	result := 0
+	for _, r := range pr {
+		result += r.Sent
+	}
+
+	return result
+}
+
+// Received returns number of received replies
+func (pr PingReport) Received() int {
+	result := 0
+	for _, r := range pr {
+		result += r.Received
+	}
+
+	return result
+}
+
+// Loss returns loss percentage of all pings
+func (pr PingReport) Loss() float64 {
+	if pr.Sent() == 0 {
+		return 100
+	}
+
+	return math.Round(float64(pr.Sent()-pr.Received())*10000/float64(pr.Sent())) / 100
+}
+
+// String prints reachability matrix, rows are sources, columns are
+// destinations: ok, X for unreachable, or loss percent, and the summary
+func (pr PingReport) String() string {
+	names := make([]string, 0)
+	cells := make(map[[2]string]*PingResult)
+
+	for _, r := range pr {
+		for _, name := range []string{r.Src, r.Dst} {
+			if !contains(names, name) {
+				names = append(names, name)
+			}
+		}
+
+		key := [2]string{r.Src, r.Dst}
+		if cells[key] == nil {
+			cells[key] = &PingResult{}
+		}
+
+		cells[key].Sent += r.Sent
+		cells[key].Received += r.Received
+	}
+
+	buf := &bytes.Buffer{}
+
+	w := tabwriter.NewWriter(buf, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "SRC\\DST\t"+strings.Join(names, "\t"))
+	for _, src := range names {
+		row := []string{src}
+
+		for _, dst := range names {
+			row = append(row, reachability(cells[[2]string{src, dst}]))
+		}
+
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	w.Flush()
+
+	fmt.Fprintf(buf, "Results: %v%% dropped (%d/%d received)\n", pr.Loss(), pr.Received(), pr.Sent())
+
+	return buf.String()
+}
+
+func reachability(r *PingResult) string {
+	switch {
+	case r == nil:
+		return "-"
+	case r.Sent > 0 && r.Received == r.Sent:
+		return "ok"
+	case r.Received == 0:
+		return "X"
+	}
+
+	return strconv.FormatFloat(float64(r.Sent-r.Received)*100/float64(r.Sent), 'f', 0, 64) + "%"
+}
@@ -0,0 +1,61 @@
+package mn
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPingAll(t *testing.T) {
+	fake := NewRecordingExecutor().
+		On("ip netns exec h1 ping", `PING 10.1.0.2 (10.1.0.2) 56(84) bytes of data.
+
+--- 10.1.0.2 ping statistics ---
+2 packets transmitted, 2 received, 0% packet loss, time 201ms
+rtt min/avg/max/mdev = 0.041/0.052/0.063/0.011 ms
+`, nil).
+		On("ip netns exec h2 ping -n -q -c 2 -i 0.2 -W 1 10.0.0.1", `PING 10.0.0.1 (10.0.0.1) 56(84) bytes of data.
+
+--- 10.0.0.1 ping statistics ---
+2 packets transmitted, 1 received, 50% packet loss, time 201ms
+rtt min/avg/max/mdev = 0.050/0.050/0.050/0.000 ms
+`, errors.New("exit status 1")).
+		On("ip netns exec h2 ping -n -q -c 2 -i 0.2 -W 1 10.1.0.1", "connect: Network is unreachable\n", errors.New("exit status 2"))
+
+	scheme := newFakeScheme(fake)
+
+	report, err := scheme.PingAll(PingOptions{Count: 2, Timeout: 500 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := PingReport{
+		{Src: "h1", Dst: "h2", Addr: "10.1.0.2", Sent: 2, Received: 2, RTT: 0.052},
+		{Src: "h2", Dst: "h1", Addr: "10.0.0.1", Sent: 2, Received: 1, Loss: 50, RTT: 0.05},
+		{Src: "h2", Dst: "h1", Addr: "10.1.0.1", Sent: 2, Loss: 100, Error: "Error: exit status 2, output: connect: Network is unreachable"},
+	}
+
+	if len(report) != len(expected) {
+		t.Fatalf("\nExpected: %v\nObtained: %v", expected, report)
+	}
+
+	for i := range expected {
+		if report[i] != expected[i] {
+			t.Fatalf("\nExpected: %v\nObtained: %v", expected[i], report[i])
+		}
+	}
+
+	if report.Sent() != 6 || report.Received() != 3 || report.Loss() != 50 {
+		t.Fatalf("Unexpected summary: %d/%d, %v%%", report.Received(), report.Sent(), report.Loss())
+	}
+
+	lines := strings.Split(report.String(), "\n")
+	if fields := strings.Fields(lines[2]); strings.Join(fields, " ") != "h2 75% -" {
+		t.Fatalf("Unexpected matrix:\n%s", report)
+	}
+
+	if lines[3] != "Results: 50% dropped (3/6 received)" {
+		t.Fatalf("Unexpected summary:\n%s", report)
+	}
+}
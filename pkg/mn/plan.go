@@ -0,0 +1,408 @@
+package mn
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+// Operation is a single step of the scheme building
+type Operation struct {
+	Action string
+	Kind   string
+	Node   string
+	Target string
+	do     func() error
+	undo   func() error
+}
+
+// String satisfies stringer interface
+func (o Operation) String() string {
+	return fmt.Sprintf("%s %s %s %s", o.Action, o.Kind, o.Node, o.Target)
+}
+
+// Plan is an ordered list of operations
+type Plan []Operation
+
+// String prints plan as a table
+func (p Plan) String() string {
+	buf := &bytes.Buffer{}
+
+	w := tabwriter.NewWriter(buf, 0, 8, 2, ' ', 0)
+	for i, op := range p {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", i+1, op.Action, op.Kind, op.Node, op.Target)
+	}
+	w.Flush()
+
+	return buf.String()
+}
+
+// Apply executes operations in order. Every applied operation is recorded
+// in the journal, so if some step fails, everything that has been done
+// is rolled back in reverse order.
+func (p Plan) Apply() error {
+	journal := NewJournal()
+
+	for _, op := range p {
+		if err := op.do(); err != nil {
+			err = fmt.Errorf("Unable to %s: %w", op, err)
+
+			if rerr := journal.Rollback(); rerr != nil {
+				return fmt.Errorf("%v, rollback failed: %v", err, rerr)
+			}
+
+			return err
+		}
+
+		journal.Record(op.String(), op.undo)
+	}
+
+	return nil
+}
+
+func (p *Plan) add(action, kind, node, target string, do, undo func() error) {
+	*p = append(*p, Operation{Action: action, Kind: kind, Node: node, Target: target, do: do, undo: undo})
+}
+
+// Plan computes ordered list of operations, which are needed to build the scheme.
+// Nothing is executed, system is only queried for existing objects.
+// Invalid scheme isn't planned, see Validate.
+func (s Scheme) Plan() (Plan, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+
+	return s.plan(nil)
+}
+
+// plan computes operations, links from the gone set are
+// considered as non-existent, because they are going to be deleted
+func (s Scheme) plan(gone map[string]bool) (Plan, error) {
+	plan := make(Plan, 0)
+	pairs := make(map[string]bool)
+
+	for _, sw := range s.Switches {
+		s.planSwitch(&plan, sw)
+	}
+
+	for _, host := range s.Hosts {
+		s.planHost(&plan, host)
+	}
+
+	for _, sw := range s.Switches {
+		if err := s.planSwitchPorts(&plan, sw, pairs, gone); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, host := range s.Hosts {
+		if err := s.planHostLinks(&plan, host, pairs, gone); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, host := range s.Hosts {
+		if err := s.planProcs(&plan, host); err != nil {
+			return nil, err
+		}
+	}
+
+	return plan, nil
+}
+
+func (s Scheme) planSwitch(plan *Plan, sw *Switch) {
+	exists := sw.Exists()
+
+	if !exists {
+		plan.add("create", "bridge", sw.NodeName(), sw.NodeName(), sw.Create, sw.Release)
+	}
+
+	if exists && sw.STP && !sw.STPEnabled() {
+		plan.add("enable", "stp", sw.NodeName(), sw.NodeName(), func() error {
+			return sw.SetSTP(true)
+		}, func() error {
+			return sw.SetSTP(false)
+		})
+	}
+
+	if sw.Controller == "" {
+		return
+	}
+
+	var prev string
+
+	if exists {
+		if ctrl, err := sw.GetController(); err == nil {
+			if ctrl == sw.Controller {
+				return
+			}
+
+			prev = ctrl
+		}
+	}
+
+	addr := sw.Controller
+	plan.add("set", "controller", sw.NodeName(), addr, func() error {
+		return sw.SetController(addr)
+	}, func() error {
+		if prev == "" {
+			return sw.DelController()
+		}
+
+		return sw.SetController(prev)
+	})
+}
+
+func (s Scheme) planHost(plan *Plan, h *Host) {
+	exists := h.NetNs().Exists()
+
+	if !exists {
+		plan.add("create", "netns", h.NodeName(), h.NetNs().Name(), h.NetNs().Create, h.NetNs().Release)
+	}
+
+	if h.LinksCount() > 1 && (!exists || !h.forwardingEnabled()) {
+		plan.add("enable", "forwarding", h.NodeName(), h.forwardingTarget(), h.enableForwarding, h.disableForwarding)
+	}
+
+	if h.Cgroup != nil && !h.Cgroup.Exists() {
+		plan.add("create", "cgroup", h.NodeName(), h.Cgroup.Name, h.Cgroup.Setup, func() error {
+			h.Cgroup.Release()
+			return nil
+		})
+	}
+}
+
+// planSwitchPorts plans switch to host and switch to switch connectivity
+func (s Scheme) planSwitchPorts(plan *Plan, sw *Switch, pairs, gone map[string]bool) error {
+	for _, port := range sw.Ports {
+		peer, found := s.GetNode(port.Peer.NodeName)
+		if !found {
+			return fmt.Errorf("Can't find host %s", port.Peer.NodeName)
+		}
+
+		link := peer.GetLinks().LinkByPeer(port.Peer)
+
+		hash := pairHash(port, link)
+		if pairs[hash] {
+			continue
+		}
+
+		pairs[hash] = true
+
+		// patch link
+		if sw2, found := s.GetSwitch(peer.NodeName()); found {
+			planPatchPort(plan, sw, port, gone)
+			planPatchPort(plan, sw2, link, gone)
+			continue
+		}
+
+		pair := Pair{port, link}
+		exists := port.Exists() && !gone[linkKey(port)]
+
+		if !exists {
+			planPairCreate(plan, pair)
+		}
+
+		if !sw.HasPort(port.Name) || gone[linkKey(port)] {
+			sw, port := sw, port
+			plan.add("add", "port", sw.NodeName(), port.Name, func() error {
+				return sw.addPort(port)
+			}, func() error {
+				return sw.delPort(port.Name)
+			})
+		}
+
+		if !exists {
+			s.planPairUp(plan, pair)
+		}
+	}
+
+	return nil
+}
+
+// planHostLinks plans host to host connectivity
+func (s Scheme) planHostLinks(plan *Plan, h *Host, pairs, gone map[string]bool) error {
+	for _, left := range h.Links {
+		peer, found := s.GetHost(left.Peer.NodeName)
+		if !found {
+			continue
+		}
+
+		right := peer.Links.LinkByPeer(left.Peer)
+		if right.NodeName == "" {
+			// nothing found
+			// @todo-maybe return (Link, bool) form LinkByPeer
+			continue
+		}
+
+		hash := pairHash(left, right)
+		if pairs[hash] {
+			continue
+		}
+
+		pairs[hash] = true
+
+		if left.Exists() && !gone[linkKey(left)] {
+			continue
+		}
+
+		pair := Pair{left, right}
+
+		planPairCreate(plan, pair)
+		s.planPairUp(plan, pair)
+	}
+
+	return nil
+}
+
+func (s Scheme) planProcs(plan *Plan, h *Host) error {
+	for i := range h.Procs {
+		proc := h.Procs[i]
+		cmdline := proc.CommandLine()
+
+		var p *os.Process
+		var err error
+
+		if h.NetNs().Exists() {
+			if p, err = proc.findProcessByName(h.executor(), h.NetNs().Name()); err != nil {
+				return err
+			}
+		}
+
+		if p != nil {
+			plan.add("attach", "process", h.NodeName(), cmdline, func() error {
+				proc.Process = p
+				return nil
+			}, nil)
+
+			continue
+		}
+
+		h := h
+		plan.add("start", "process", h.NodeName(), cmdline, func() error {
+			result, err := h.runProcess(append([]string{proc.Command}, proc.Args...)...)
+			if err != nil {
+				return err
+			}
+
+			proc.Process = result.Process
+			proc.Output = result.Output
+
+			return nil
+		}, func() error {
+			return proc.Stop()
+		})
+	}
+
+	return nil
+}
+
+func planPatchPort(plan *Plan, sw *Switch, l Link, gone map[string]bool) {
+	if sw.HasPort(l.Name) && !gone[linkKey(l)] {
+		return
+	}
+
+	plan.add("add", "patch", sw.NodeName(), l.Name+" peer="+l.Peer.Name, func() error {
+		return sw.addPatchPort(l)
+	}, func() error {
+		return sw.delPort(l.Name)
+	})
+}
+
+func planPairCreate(plan *Plan, pr Pair) {
+	plan.add("create", "veth", pr.Left.NodeName, linkTarget(pr.Left)+" <-> "+linkTarget(pr.Right), pr.Create, pr.Left.Delete)
+}
+
+// planPairUp plans MACs, addresses, state, impairment and routes of the new pair.
+// Links, which are declared DOWN, are left down without routes, they are
+// replaced once the link is set up. State of the links, which are set up,
+// is recorded in the scheme.
+func (s Scheme) planPairUp(plan *Plan, pr Pair) {
+	for _, l := range []Link{pr.Left, pr.Right} {
+		// kernel gives the new veth random MAC
+		if l.HwAddr != "" {
+			plan.add("set", "mac", l.NodeName, l.HwAddr+" dev "+linkTarget(l), l.ApplyMac, nil)
+		}
+
+		if l.SLAAC || l.HasIPv6() {
+			plan.add("set", "ipv6", l.NodeName, ipv6Mode(l)+" dev "+linkTarget(l), l.ApplyIPv6, nil)
+		}
+
+		for _, cidr := range l.Cidrs() {
+			al := l.withCidr(cidr)
+			plan.add("add", "addr", l.NodeName, cidr+" dev "+linkTarget(l), al.ApplyCidr, al.RemoveCidr)
+		}
+	}
+
+	for _, l := range []Link{pr.Left, pr.Right} {
+		if l.State == "DOWN" {
+			continue
+		}
+
+		l := l
+		plan.add("set", "up", l.NodeName, linkTarget(l), func() error {
+			if err := l.Up(); err != nil {
+				return err
+			}
+
+			if sl, err := s.link(l.NodeName, l.Name); err == nil {
+				sl.State = "UP"
+			}
+
+			return nil
+		}, nil)
+	}
+
+	for _, l := range []Link{pr.Left, pr.Right} {
+		if l.impaired() {
+			plan.add("set", "impairment", l.NodeName, l.Impairment.String()+" dev "+linkTarget(l), l.ApplyImpairment, l.ClearImpairment)
+		}
+	}
+
+	for _, l := range []Link{pr.Left, pr.Right} {
+		if l.State == "DOWN" {
+			continue
+		}
+
+		for _, route := range l.Routes {
+			l := l
+			l.Routes = []Route{route}
+			plan.add("add", "route", l.NodeName, route.Dst+" via "+route.Gw+" dev "+linkTarget(l), l.ApplyRoutes, l.RemoveRoutes)
+		}
+	}
+}
+
+func ipv6Mode(l Link) string {
+	if l.SLAAC {
+		return "slaac"
+	}
+
+	return "static"
+}
+
+// linkTarget returns link name with namespace, e.g. eth0@h1
+func linkTarget(l Link) string {
+	if l.NetNs == "" {
+		return l.Name
+	}
+
+	return l.Name + "@" + l.NetNs
+}
+
+// linkKey identifies the link in the scheme
+func linkKey(l Link) string {
+	return l.NodeName + "/" + l.Name
+}
+
+// pairHash returns the same value for both sides of the pair
+func pairHash(l1, l2 Link) string {
+	a := linkKey(l1)
+	b := linkKey(l2)
+
+	if a > b {
+		a, b = b, a
+	}
+
+	return a + "-" + b
+}
@@ -0,0 +1,219 @@
+package mn
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// newFakeScheme creates scheme: s1 <---> h1 <---> h2
+func newFakeScheme(fake Executor) *Scheme {
+	s1 := newFakeSwitch("s1", fake)
+	h1 := newFakeHost("h1", fake)
+	h2 := newFakeHost("h2", fake)
+
+	p1 := NewLink(s1, h1, Link{Cidr: noip, HwAddr: "02:00:00:00:01:01"}, Link{Cidr: "10.0.0.1/24", HwAddr: "02:00:00:00:01:02"})
+	s1.Ports = append(s1.Ports, p1.Left)
+	h1.Links = append(h1.Links, p1.Right)
+
+	p2 := NewLink(h1, h2,
+		Link{Name: "eth1", Cidr: "10.1.0.1/24", HwAddr: "02:00:00:00:02:01"},
+		Link{Name: "eth0", Cidr: "10.1.0.2/24", HwAddr: "02:00:00:00:02:02", Routes: []Route{{Dst: "10.0.0.0/24", Gw: "10.1.0.1"}}},
+	)
+	h1.Links = append(h1.Links, p2.Left)
+	h2.Links = append(h2.Links, p2.Right)
+
+	return NewScheme().SetExecutor(fake).AddNode(s1).AddNode(h1).AddNode(h2)
+}
+
+func TestPlan(t *testing.T) {
+	fake := NewRecordingExecutor().
+		On("ovs-vsctl br-exists", "", errors.New("exit status 2")).
+		On("ip link show", "", errors.New("exit status 1")).
+		On("ip netns exec h1 ip link show", "", errors.New("exit status 1"))
+
+	scheme := newFakeScheme(fake)
+
+	h2, _ := scheme.GetHost("h2")
+	h2.Procs = append(h2.Procs, &Process{Command: "ping", Args: []string{"-c1", "10.0.0.1"}})
+
+	plan, err := scheme.Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"create bridge s1 s1",
+		"create netns h1 h1",
+		"enable forwarding h1 net.ipv4.ip_forward=1",
+		"create netns h2 h2",
+		"create veth s1 h1-eth0 <-> veth0@h1",
+		"add port s1 h1-eth0",
+		"set mac s1 02:00:00:00:01:01 dev h1-eth0",
+		"set mac h1 02:00:00:00:01:02 dev veth0@h1",
+		"add addr h1 10.0.0.1/24 dev veth0@h1",
+		"set up s1 h1-eth0",
+		"set up h1 veth0@h1",
+		"create veth h1 eth1@h1 <-> eth0@h2",
+		"set mac h1 02:00:00:00:02:01 dev eth1@h1",
+		"add addr h1 10.1.0.1/24 dev eth1@h1",
+		"set mac h2 02:00:00:00:02:02 dev eth0@h2",
+		"add addr h2 10.1.0.2/24 dev eth0@h2",
+		"set up h1 eth1@h1",
+		"set up h2 eth0@h2",
+		"add route h2 10.0.0.0/24 via 10.1.0.1 dev eth0@h2",
+		"start process h2 ping -c1 10.0.0.1",
+	}
+
+	if len(plan) != len(expected) {
+		t.Fatal("Expected", len(expected), "operations, obtained:\n", plan)
+	}
+
+	for i := range expected {
+		if plan[i].String() != expected[i] {
+			t.Fatalf("Expected operation #%d %q, obtained %q", i, expected[i], plan[i])
+		}
+	}
+
+	// planning only queries the system
+	for _, call := range fake.Calls() {
+		if !strings.Contains(call, "exists") && !strings.Contains(call, " show ") &&
+			!strings.Contains(call, " list") {
+			t.Fatal("Unexpected command during planning:", call)
+		}
+	}
+
+	fake.Reset()
+
+	// everything except the process
+	if err := plan[:len(plan)-1].Apply(); err != nil {
+		t.Fatal(err)
+	}
+
+	err = fake.Verify(
+		"ovs-vsctl add-br s1 -- set bridge s1 external_ids:mn-topology=mininet",
+		"ip netns add h1",
+		"ip netns exec h1 sysctl -e net.ipv4.ip_forward=1",
+		"ip netns add h2",
+		"ip link add name h1-eth0 type veth peer name veth0 netns h1",
+		"ip link set dev h1-eth0 alias mn:mininet",
+		"ip netns exec h1 ip link set dev veth0 alias mn:mininet",
+		"ovs-vsctl add-port s1 h1-eth0",
+		"ip link set dev h1-eth0 address 02:00:00:00:01:01",
+		"ip netns exec h1 ip link set dev veth0 address 02:00:00:00:01:02",
+		"ip netns exec h1 ip addr add 10.0.0.1/24 dev veth0",
+		"ip link set h1-eth0 up",
+		"ip netns exec h1 ip link set veth0 up",
+		"ip link add name eth1 type veth peer name eth0 netns h2",
+		"ip link set eth1 netns h1",
+		"ip netns exec h1 ip link set dev eth1 alias mn:mininet",
+		"ip netns exec h2 ip link set dev eth0 alias mn:mininet",
+		"ip netns exec h1 ip link set dev eth1 address 02:00:00:00:02:01",
+		"ip netns exec h1 ip addr add 10.1.0.1/24 dev eth1",
+		"ip netns exec h2 ip link set dev eth0 address 02:00:00:00:02:02",
+		"ip netns exec h2 ip addr add 10.1.0.2/24 dev eth0",
+		"ip netns exec h1 ip link set eth1 up",
+		"ip netns exec h2 ip link set eth0 up",
+		"ip netns exec h2 ip route add 10.0.0.0/24 via 10.1.0.1 dev eth0",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPlanNothingToDo(t *testing.T) {
+	fake := NewRecordingExecutor().
+		On("ip netns list", "h1\nh2\n", nil).
+		On("ip netns exec h1 sysctl -n -e net.ipv4.ip_forward", "1\n", nil).
+		On("ovs-vsctl list-ports s1", "h1-eth0\n", nil)
+
+	plan, err := newFakeScheme(fake).Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(plan) != 0 {
+		t.Fatal("Expected empty plan, obtained:\n", plan)
+	}
+}
+
+func TestPlanRollback(t *testing.T) {
+	fake := NewRecordingExecutor().
+		On("ovs-vsctl br-exists", "", errors.New("exit status 2")).
+		On("ip link show", "", errors.New("exit status 1")).
+		On("ip netns exec h1 ip link show", "", errors.New("exit status 1"))
+
+	plan, err := newFakeScheme(fake).Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake.Reset()
+	fake.On("ovs-vsctl add-port", "", errors.New("exit status 1"))
+
+	if err := plan.Apply(); err == nil {
+		t.Fatal("Expected error, obtained nil")
+	}
+
+	err = fake.Verify(
+		"ovs-vsctl add-br s1 -- set bridge s1 external_ids:mn-topology=mininet",
+		"ip netns add h1",
+		"ip netns exec h1 sysctl -e net.ipv4.ip_forward=1",
+		"ip netns add h2",
+		"ip link add name h1-eth0 type veth peer name veth0 netns h1",
+		"ip link set dev h1-eth0 alias mn:mininet",
+		"ip netns exec h1 ip link set dev veth0 alias mn:mininet",
+		"ovs-vsctl add-port s1 h1-eth0",
+		// rollback
+		"ip link delete h1-eth0",
+		"ip netns delete h2",
+		"ip netns exec h1 sysctl -e net.ipv4.ip_forward=0",
+		"ip netns delete h1",
+		"ovs-vsctl del-br s1",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPlanLinkState(t *testing.T) {
+	fake := NewRecordingExecutor().
+		On("ovs-vsctl br-exists", "", errors.New("exit status 2")).
+		On("ip link show", "", errors.New("exit status 1")).
+		On("ip netns exec h1 ip link show", "", errors.New("exit status 1"))
+
+	// h1-h2 link is declared down on h2 side
+	scheme := newFakeScheme(fake)
+
+	h2, _ := scheme.GetHost("h2")
+	h2.Links[0].State = "DOWN"
+
+	plan, err := scheme.Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, op := range plan {
+		if op.Node == "h2" && (op.Action == "set" && op.Kind == "up" || op.Kind == "route") {
+			t.Fatal("Unexpected operation of the down link:", op)
+		}
+	}
+
+	if err := plan.Apply(); err != nil {
+		t.Fatal(err)
+	}
+
+	// state of the links, which are set up, is recorded
+	h1, _ := scheme.GetHost("h1")
+	s1, _ := scheme.GetSwitch("s1")
+
+	for _, l := range []Link{h1.Links[0], h1.Links[1], s1.Ports[0]} {
+		if l.State != "UP" {
+			t.Fatalf("\nExpected: %s UP\nObtained: %s", l.Name, l.State)
+		}
+	}
+
+	if h2.Links[0].State != "DOWN" {
+		t.Fatalf("\nExpected: %s DOWN\nObtained: %s", h2.Links[0].Name, h2.Links[0].State)
+	}
+}
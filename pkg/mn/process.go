@@ -34,6 +34,17 @@ func (ps Procs) GetByPid(pid int) *Process {
 	return nil
 }
 
+// GetByCommandLine gets process by command line
+func (ps Procs) GetByCommandLine(cmdline string) *Process {
+	for i := range ps {
+		if ps[i].CommandLine() == cmdline {
+			return ps[i]
+		}
+	}
+
+	return nil
+}
+
 // GetPid gets process pid
 func (p Process) GetPid() int {
 	if p.Process != nil {
@@ -43,6 +54,11 @@ func (p Process) GetPid() int {
 	return 0
 }
 
+// CommandLine returns command with arguments
+func (p Process) CommandLine() string {
+	return strings.Join(append([]string{p.Command}, p.Args...), " ")
+}
+
 // Stop sends Interrupt signal to the process
 func (p Process) Stop() error {
 	if p.Process == nil {
@@ -66,13 +82,13 @@ func (p Process) Stop() error {
 //
 // But we can't be sure, that found process actually the same
 // so it looks, that finding it by name makes sense.
-func (p Process) findProcessByName(netns string) (*os.Process, error) {
-	out, err := RunCommand("ps", "-A", "-eo", "%p,%a")
+func (p Process) findProcessByName(e Executor, netns string) (*os.Process, error) {
+	out, err := e.Run("ps", "-A", "-eo", "%p,%a")
 	if err != nil {
 		return nil, err
 	}
 
-	name := p.Command + " " + strings.Join(p.Args, " ")
+	name := p.CommandLine()
 
 	lines := strings.Split(out, "\n")
 
@@ -91,7 +107,7 @@ func (p Process) findProcessByName(netns string) (*os.Process, error) {
 		}
 
 		// check that process is in the right netns
-		if netns == netnsByPid(pid) {
+		if netns == netnsByPid(e, pid) {
 			return os.FindProcess(pid)
 		}
 	}
@@ -99,9 +115,47 @@ func (p Process) findProcessByName(netns string) (*os.Process, error) {
 	return nil, nil
 }
 
+// attach attaches to the running process by pid, it succeeds only if
+// command line of the running process is the same and it's in the netns
+func (p *Process) attach(e Executor, pid int, netns string) bool {
+	out, err := e.Run("ps", "-o", "args=", "-p", strconv.Itoa(pid))
+	if err != nil || strings.TrimSpace(out) != p.CommandLine() {
+		return false
+	}
+
+	if netnsByPid(e, pid) != netns {
+		return false
+	}
+
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+
+	p.Process = proc
+
+	return true
+}
+
+// alive checks whether the process is running in the netns, the known pid
+// is checked, otherwise process is searched by command line
+func (p Process) alive(e Executor, netns string) bool {
+	if p.Process == nil {
+		proc, err := p.findProcessByName(e, netns)
+		return err == nil && proc != nil
+	}
+
+	out, err := e.Run("ps", "-o", "args=", "-p", strconv.Itoa(p.Pid))
+	if err != nil || strings.TrimSpace(out) != p.CommandLine() {
+		return false
+	}
+
+	return netnsByPid(e, p.Pid) == netns
+}
+
 // Beware, the old versions of ip utility don't support 'identify' command
-func netnsByPid(pid int) string {
-	out, err := RunCommand("ip", "netns", "identify", strconv.Itoa(pid))
+func netnsByPid(e Executor, pid int) string {
+	out, err := e.Run("ip", "netns", "identify", strconv.Itoa(pid))
 	if err != nil {
 		log.Println(err)
 		return ""
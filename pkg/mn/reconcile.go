@@ -0,0 +1,287 @@
+package mn
+
+import (
+	"net"
+)
+
+// Diff computes operations, which converge the scheme into the desired one.
+// Nodes, links, ports, routes and processes which were dropped are deleted,
+// changed addresses, MACs, routes and link states are updated and new things
+// are created.
+func (s *Scheme) Diff(desired *Scheme) (Plan, error) {
+	if err := desired.Validate(); err != nil {
+		return nil, err
+	}
+
+	plan := make(Plan, 0)
+	gone := make(map[string]bool)
+
+	if desired.exec == nil && s.exec != nil {
+		desired.SetExecutor(s.exec)
+	}
+
+	for _, h := range s.Hosts {
+		dh, _ := desired.GetHost(h.NodeName())
+		s.diffProcs(&plan, h, dh)
+	}
+
+	for _, sw := range s.Switches {
+		dsw, _ := desired.GetSwitch(sw.NodeName())
+		s.diffSwitch(&plan, sw, dsw, gone)
+	}
+
+	for _, h := range s.Hosts {
+		dh, _ := desired.GetHost(h.NodeName())
+		s.diffHost(&plan, h, dh, gone)
+	}
+
+	creates, err := desired.plan(gone)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(plan, creates...), nil
+}
+
+// Reconcile applies the diff and makes desired scheme the current one
+func (s *Scheme) Reconcile(desired *Scheme) error {
+	plan, err := s.Diff(desired)
+	if err != nil {
+		return err
+	}
+
+	if err := plan.Apply(); err != nil {
+		return err
+	}
+
+	s.Switches = desired.Switches
+	s.Hosts = desired.Hosts
+
+	return nil
+}
+
+func (s *Scheme) diffProcs(plan *Plan, h, desired *Host) {
+	for _, proc := range h.Procs {
+		if proc.GetPid() == 0 {
+			continue
+		}
+
+		if desired != nil && desired.Procs.GetByCommandLine(proc.CommandLine()) != nil {
+			continue
+		}
+
+		plan.add("stop", "process", h.NodeName(), proc.CommandLine(), proc.Stop, nil)
+	}
+}
+
+func (s *Scheme) diffSwitch(plan *Plan, sw, desired *Switch, gone map[string]bool) {
+	for _, port := range sw.Ports {
+		if desired != nil {
+			if dp, found := desired.Ports.ByName(port.Name); found && !peerChanged(port, dp) {
+				diffLink(plan, port, dp, gone)
+				continue
+			}
+
+			sw, name := sw, port.Name
+			plan.add("delete", "port", sw.NodeName(), name, func() error {
+				return sw.DelPort(name)
+			}, nil)
+		}
+
+		if _, patch := s.GetSwitch(port.Peer.NodeName); !patch {
+			planLinkDelete(plan, port, gone)
+		}
+
+		gone[linkKey(port)] = true
+		gone[peerKey(port)] = true
+	}
+
+	if desired == nil {
+		plan.add("delete", "bridge", sw.NodeName(), sw.NodeName(), sw.Release, nil)
+		return
+	}
+
+	if sw.STP && !desired.STP {
+		plan.add("disable", "stp", sw.NodeName(), sw.NodeName(), func() error {
+			return sw.SetSTP(false)
+		}, func() error {
+			return sw.SetSTP(true)
+		})
+	}
+
+	if sw.Controller != "" && desired.Controller == "" {
+		addr := sw.Controller
+		plan.add("delete", "controller", sw.NodeName(), addr, sw.DelController, func() error {
+			return sw.SetController(addr)
+		})
+	}
+}
+
+func (s *Scheme) diffHost(plan *Plan, h, desired *Host, gone map[string]bool) {
+	if desired == nil {
+		plan.add("delete", "host", h.NodeName(), h.NodeName(), h.Release, nil)
+
+		for _, l := range h.Links {
+			gone[linkKey(l)] = true
+			gone[peerKey(l)] = true
+		}
+
+		return
+	}
+
+	for _, l := range h.Links {
+		if dl, found := desired.Links.ByName(l.Name); found && !peerChanged(l, dl) {
+			diffLink(plan, l, dl, gone)
+			continue
+		}
+
+		planLinkDelete(plan, l, gone)
+	}
+}
+
+// diffLink plans updates of the existing link
+func diffLink(plan *Plan, l, desired Link, gone map[string]bool) {
+	if gone[linkKey(l)] {
+		return
+	}
+
+	cidrChanged := l.Cidr != desired.Cidr
+
+	if cidrChanged {
+		if _, _, err := net.ParseCIDR(l.Cidr); err == nil {
+			plan.add("delete", "addr", l.NodeName, l.Cidr+" dev "+linkTarget(l), l.RemoveCidr, l.ApplyCidr)
+		}
+
+		if _, _, err := net.ParseCIDR(desired.Cidr); err == nil {
+			nl := l
+			nl.Cidr = desired.Cidr
+			plan.add("add", "addr", l.NodeName, nl.Cidr+" dev "+linkTarget(l), nl.ApplyCidr, nl.RemoveCidr)
+		}
+	}
+
+	for _, addr := range l.Addrs {
+		if !contains(desired.Addrs, addr) {
+			al := l.withCidr(addr)
+			plan.add("delete", "addr", l.NodeName, addr+" dev "+linkTarget(l), al.RemoveCidr, al.ApplyCidr)
+		}
+	}
+
+	nl := l
+	nl.Cidr, nl.Addrs, nl.SLAAC = desired.Cidr, desired.Addrs, desired.SLAAC
+
+	// static is set once the first IPv6 address is added
+	if l.SLAAC != desired.SLAAC || (!l.HasIPv6() && desired.HasIPv6()) {
+		plan.add("set", "ipv6", l.NodeName, ipv6Mode(nl)+" dev "+linkTarget(l), nl.ApplyIPv6, l.ApplyIPv6)
+	}
+
+	for _, addr := range desired.Addrs {
+		if !contains(l.Addrs, addr) {
+			al := l.withCidr(addr)
+			plan.add("add", "addr", l.NodeName, addr+" dev "+linkTarget(l), al.ApplyCidr, al.RemoveCidr)
+		}
+	}
+
+	if desired.HwAddr != "" && desired.HwAddr != l.HwAddr {
+		nl := l
+		nl.HwAddr = desired.HwAddr
+		plan.add("set", "mac", l.NodeName, nl.HwAddr+" dev "+linkTarget(l), nl.ApplyMac, l.ApplyMac)
+	}
+
+	if impairmentString(l) != impairmentString(desired) {
+		nl := l
+		nl.Impairment = desired.Impairment
+		plan.add("set", "impairment", l.NodeName, impairmentString(nl)+" dev "+linkTarget(l), func() error {
+			return l.replaceImpairment(nl)
+		}, func() error {
+			return nl.replaceImpairment(l)
+		})
+	}
+
+	state := desired.State
+	if state == "" {
+		state = l.State
+	}
+
+	switch {
+	case l.State == "DOWN" && state == "DOWN":
+		// routes of the down link are flushed by kernel, desired ones are
+		// replaced once it's set up
+	case l.State == "DOWN":
+		plan.add("set", "up", l.NodeName, linkTarget(l), func() error {
+			_, err := Pair{Left: l}.restore(false)
+			return err
+		}, l.Down)
+
+		diffRoutes(plan, l, desired, cidrChanged)
+	case state == "DOWN":
+		diffRoutes(plan, l, desired, cidrChanged)
+
+		plan.add("set", "down", l.NodeName, linkTarget(l), l.Down, func() error {
+			_, err := Pair{Left: l}.restore(false)
+			return err
+		})
+	default:
+		diffRoutes(plan, l, desired, cidrChanged)
+	}
+}
+
+// diffRoutes plans updates of routes of the link, which is up
+func diffRoutes(plan *Plan, l, desired Link, cidrChanged bool) {
+	// routes via removed address are flushed by kernel
+	if !cidrChanged {
+		for _, route := range l.Routes {
+			if hasRoute(desired.Routes, route) {
+				continue
+			}
+
+			nl := l
+			nl.Routes = []Route{route}
+			plan.add("delete", "route", l.NodeName, route.Dst+" via "+route.Gw+" dev "+linkTarget(l), nl.RemoveRoutes, nl.ApplyRoutes)
+		}
+	}
+
+	for _, route := range desired.Routes {
+		if !cidrChanged && hasRoute(l.Routes, route) {
+			continue
+		}
+
+		nl := l
+		nl.Routes = []Route{route}
+		plan.add("add", "route", l.NodeName, route.Dst+" via "+route.Gw+" dev "+linkTarget(l), nl.ApplyRoutes, nl.RemoveRoutes)
+	}
+}
+
+// planLinkDelete deletes veth pair, peer is deleted by kernel
+func planLinkDelete(plan *Plan, l Link, gone map[string]bool) {
+	if !gone[linkKey(l)] {
+		plan.add("delete", "link", l.NodeName, linkTarget(l), func() error {
+			if !l.Exists() {
+				return nil
+			}
+
+			return l.Delete()
+		}, nil)
+	}
+
+	gone[linkKey(l)] = true
+	gone[peerKey(l)] = true
+}
+
+func peerChanged(l1, l2 Link) bool {
+	return l1.Peer.NodeName != l2.Peer.NodeName || l1.Peer.IfName != l2.Peer.IfName
+}
+
+// peerKey identifies link's peer in the scheme
+func peerKey(l Link) string {
+	return l.Peer.NodeName + "/" + l.Peer.IfName
+}
+
+func hasRoute(routes []Route, route Route) bool {
+	for _, r := range routes {
+		if r == route {
+			return true
+		}
+	}
+
+	return false
+}
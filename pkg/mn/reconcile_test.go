@@ -0,0 +1,183 @@
+package mn
+
+import (
+	"testing"
+)
+
+func TestReconcile(t *testing.T) {
+	fake := NewRecordingExecutor().On("ip netns list", "h1\nh2\n", nil)
+
+	live := newFakeScheme(fake)
+
+	// s1 is dropped together with h1's veth0,
+	// h1-h2 link gets new address on h2 side and new MAC on h1 side
+	desired := newFakeScheme(fake)
+	desired.Switches = desired.Switches[:0]
+
+	h1, _ := desired.GetHost("h1")
+	h1.Links = h1.Links[1:]
+	h1.Links[0].HwAddr = "02:00:00:00:00:01"
+
+	h2, _ := desired.GetHost("h2")
+	h2.Links[0].Cidr = "10.1.0.3/24"
+
+	plan, err := live.Diff(desired)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"delete link s1 h1-eth0",
+		"delete bridge s1 s1",
+		"set mac h1 02:00:00:00:00:01 dev eth1@h1",
+		"delete addr h2 10.1.0.2/24 dev eth0@h2",
+		"add addr h2 10.1.0.3/24 dev eth0@h2",
+		"add route h2 10.0.0.0/24 via 10.1.0.1 dev eth0@h2",
+	}
+
+	if len(plan) != len(expected) {
+		t.Fatal("Expected", len(expected), "operations, obtained:\n", plan)
+	}
+
+	for i := range expected {
+		if plan[i].String() != expected[i] {
+			t.Fatalf("Expected operation #%d %q, obtained %q", i, expected[i], plan[i])
+		}
+	}
+
+	fake.Reset()
+
+	if err := live.Reconcile(desired); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(live.Switches) != 0 {
+		t.Fatal("Expected no switches, obtained:", len(live.Switches))
+	}
+
+	if h, _ := live.GetHost("h2"); h.Links[0].Cidr != "10.1.0.3/24" {
+		t.Fatal("Expected h2 address 10.1.0.3/24, obtained:", h.Links[0].Cidr)
+	}
+}
+
+func TestReconcileRoutesAndPorts(t *testing.T) {
+	fake := NewRecordingExecutor().
+		On("ip netns list", "h1\nh2\n", nil).
+		On("ip netns exec h1 sysctl -n -e net.ipv4.ip_forward", "1\n", nil)
+
+	live := newFakeScheme(fake)
+
+	desired := newFakeScheme(fake)
+
+	h2, _ := desired.GetHost("h2")
+	h2.Links[0].Routes = []Route{{Dst: "10.2.0.0/24", Gw: "10.1.0.1"}}
+
+	// s1 port is reconnected to another interface of h1
+	s1, _ := desired.GetSwitch("s1")
+	s1.Ports[0].Peer.IfName = "veth1"
+	s1.Ports[0].Peer.Name = "veth1"
+
+	h1, _ := desired.GetHost("h1")
+	h1.Links[0].Name = "veth1"
+
+	plan, err := live.Diff(desired)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"delete port s1 h1-eth0",
+		"delete link s1 h1-eth0",
+		"delete route h2 10.0.0.0/24 via 10.1.0.1 dev eth0@h2",
+		"add route h2 10.2.0.0/24 via 10.1.0.1 dev eth0@h2",
+		"create veth s1 h1-eth0 <-> veth1@h1",
+		"add port s1 h1-eth0",
+		"set mac s1 02:00:00:00:01:01 dev h1-eth0",
+		"set mac h1 02:00:00:00:01:02 dev veth1@h1",
+		"add addr h1 10.0.0.1/24 dev veth1@h1",
+		"set up s1 h1-eth0",
+		"set up h1 veth1@h1",
+	}
+
+	if len(plan) != len(expected) {
+		t.Fatal("Expected", len(expected), "operations, obtained:\n", plan)
+	}
+
+	for i := range expected {
+		if plan[i].String() != expected[i] {
+			t.Fatalf("Expected operation #%d %q, obtained %q", i, expected[i], plan[i])
+		}
+	}
+}
+
+func TestReconcileLinkState(t *testing.T) {
+	fake := NewRecordingExecutor().
+		On("ip netns list", "h1\nh2\n", nil).
+		On("ip netns exec h1 sysctl -n -e net.ipv4.ip_forward", "1\n", nil).
+		On("ovs-vsctl list-ports s1", "h1-eth0\n", nil)
+
+	cases := []struct {
+		live, desired string
+		expected      []string
+	}{
+		// untracked state is left as is
+		{"", "", nil},
+		{"DOWN", "", nil},
+		{"UP", "UP", nil},
+		{"UP", "DOWN", []string{"set down h2 eth0@h2"}},
+		{"", "DOWN", []string{"set down h2 eth0@h2"}},
+		{"DOWN", "UP", []string{"set up h2 eth0@h2"}},
+	}
+
+	for _, c := range cases {
+		live := newFakeScheme(fake)
+		h2, _ := live.GetHost("h2")
+		h2.Links[0].State = c.live
+
+		desired := newFakeScheme(fake)
+		dh2, _ := desired.GetHost("h2")
+		dh2.Links[0].State = c.desired
+
+		plan, err := live.Diff(desired)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		obtained := make([]string, 0)
+		for _, op := range plan {
+			obtained = append(obtained, op.String())
+		}
+
+		if len(obtained) != len(c.expected) || len(obtained) > 0 && obtained[0] != c.expected[0] {
+			t.Fatalf("%s -> %s\nExpected: %v\nObtained: %v", c.live, c.desired, c.expected, obtained)
+		}
+	}
+
+	// routes flushed by kernel are restored, once the link is set up
+	live := newFakeScheme(fake)
+	h2, _ := live.GetHost("h2")
+	h2.Links[0].State = "DOWN"
+
+	desired := newFakeScheme(fake)
+	dh2, _ := desired.GetHost("h2")
+	dh2.Links[0].State = "UP"
+
+	plan, err := live.Diff(desired)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake.Reset()
+
+	if err := plan.Apply(); err != nil {
+		t.Fatal(err)
+	}
+
+	err = fake.Verify(
+		"ip netns exec h2 ip link set eth0 up",
+		"ip netns exec h2 ip route replace 10.0.0.0/24 via 10.1.0.1 dev eth0",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,519 @@
+package mn
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Scenario is a list of steps, which are run against the scheme in order.
+// Scheme is a file of the scheme, relative to the scenario file, it's
+// brought up before the steps and released after them.
+type Scenario struct {
+	Name   string
+	Scheme string `json:",omitempty"`
+	Steps  []Step
+	dir    string
+}
+
+// Step has one action: Run starts process in the host, Exec runs command
+// and checks its exit status and Output regexp, Wait sleeps, Ping pings
+// host or address, optionally checking that the first hop is Via host,
+// Connect opens TCP connection to host:port, Link sets node's link State
+// down or up. Expect is success by default, or failure, e.g. ping of the
+// isolated host. Timeout of ping, connect and exec is 2s by default.
+type Step struct {
+	Name    string   `json:",omitempty"`
+	Node    string   `json:",omitempty"`
+	Run     []string `json:",omitempty"`
+	Exec    []string `json:",omitempty"`
+	Wait    string   `json:",omitempty"`
+	Ping    string   `json:",omitempty"`
+	Via     string   `json:",omitempty"`
+	Connect string   `json:",omitempty"`
+	Link    string   `json:",omitempty"`
+	State   string   `json:",omitempty"`
+	Output  string   `json:",omitempty"`
+	Expect  string   `json:",omitempty"`
+	Timeout string   `json:",omitempty"`
+}
+
+const (
+	expectSuccess = "success"
+	expectFailure = "failure"
+)
+
+// NewScenarioFromFile loads json, yaml or toml scenario, format is
+// detected by extension
+func NewScenarioFromFile(fname string) (*Scenario, error) {
+	format, err := FormatByExt(fname)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+
+	sc, err := NewScenarioFromData(data, format)
+	if err != nil {
+		return nil, err
+	}
+
+	sc.dir = filepath.Dir(fname)
+
+	return sc, nil
+}
+
+// NewScenarioFromData parses the scenario, unknown fields and steps
+// without exactly one action are rejected
+func NewScenarioFromData(data []byte, format string) (*Scenario, error) {
+	data, err := toJSON(data, format)
+	if err != nil {
+		return nil, err
+	}
+
+	sc := &Scenario{}
+
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.DisallowUnknownFields()
+
+	if err := d.Decode(sc); err != nil {
+		return nil, fmt.Errorf("Unable to parse scenario, error: %v", err)
+	}
+
+	problems := make([]string, 0)
+	for i, step := range sc.Steps {
+		if err := step.check(); err != nil {
+			problems = append(problems, fmt.Sprintf("step #%d %s: %v", i+1, step, err))
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("Wrong scenario: %s", strings.Join(problems, "; "))
+	}
+
+	return sc, nil
+}
+
+// action returns the step's action
+func (st Step) action() string {
+	actions := make([]string, 0)
+
+	for name, set := range map[string]bool{
+		"run":     len(st.Run) > 0,
+		"exec":    len(st.Exec) > 0,
+		"wait":    st.Wait != "",
+		"ping":    st.Ping != "",
+		"connect": st.Connect != "",
+		"link":    st.Link != "",
+	} {
+		if set {
+			actions = append(actions, name)
+		}
+	}
+
+	if len(actions) != 1 {
+		return ""
+	}
+
+	return actions[0]
+}
+
+func (st Step) check() error {
+	action := st.action()
+	if action == "" {
+		return fmt.Errorf("expected exactly one of Run, Exec, Wait, Ping, Connect or Link")
+	}
+
+	if action != "wait" && st.Node == "" {
+		return fmt.Errorf("Node is empty")
+	}
+
+	for _, d := range []string{st.Wait, st.Timeout} {
+		if _, err := time.ParseDuration(d); d != "" && err != nil {
+			return err
+		}
+	}
+
+	switch st.Expect {
+	case "", expectSuccess, expectFailure:
+	default:
+		return fmt.Errorf("unknown Expect %s, expected success or failure", st.Expect)
+	}
+
+	if _, err := regexp.Compile(st.Output); err != nil {
+		return err
+	}
+
+	if action == "connect" {
+		if _, _, err := net.SplitHostPort(st.Connect); err != nil {
+			return err
+		}
+	}
+
+	if action == "link" && st.State != "up" && st.State != "down" {
+		return fmt.Errorf("unknown link State %s, expected down or up", st.State)
+	}
+
+	// wait can't fail, run and link have no output to match
+	switch {
+	case action == "wait" && st.Expect != "":
+		return fmt.Errorf("Expect isn't allowed for wait")
+	case (action == "wait" || action == "run" || action == "link") && st.Output != "":
+		return fmt.Errorf("Output isn't allowed for %s, it has no output", action)
+	}
+
+	return nil
+}
+
+// String satisfies stringer interface, it's a default name of the step
+func (st Step) String() string {
+	if st.Name != "" {
+		return st.Name
+	}
+
+	var s string
+
+	switch st.action() {
+	case "run":
+		s = st.Node + " runs " + strings.Join(st.Run, " ")
+	case "exec":
+		s = st.Node + " executes " + strings.Join(st.Exec, " ")
+	case "wait":
+		s = "wait " + st.Wait
+	case "ping":
+		s = st.Node + " pings " + st.Ping
+		if st.Via != "" {
+			s += " via " + st.Via
+		}
+	case "connect":
+		s = st.Node + " connects to " + st.Connect
+	case "link":
+		s = st.Node + " link " + st.Link + " " + st.State
+	default:
+		return "unknown"
+	}
+
+	if st.Expect == expectFailure {
+		s += " fails"
+	}
+
+	return s
+}
+
+// StepResult is a result of the step, Failure is empty if it's passed
+type StepResult struct {
+	Name    string  `json:"name" yaml:"name"`
+	Seconds float64 `json:"seconds" yaml:"seconds"`
+	Failure string  `json:"failure,omitempty" yaml:"failure,omitempty"`
+	Output  string  `json:"output,omitempty" yaml:"output,omitempty"`
+}
+
+// ScenarioReport is a result of the scenario
+type ScenarioReport struct {
+	Name    string       `json:"name" yaml:"name"`
+	Started time.Time    `json:"started" yaml:"started"`
+	Seconds float64      `json:"seconds" yaml:"seconds"`
+	Steps   []StepResult `json:"steps" yaml:"steps"`
+}
+
+// Failures returns number of failed steps
+func (r ScenarioReport) Failures() int {
+	result := 0
+	for _, step := range r.Steps {
+		if step.Failure != "" {
+			result++
+		}
+	}
+
+	return result
+}
+
+// String prints results as a table
+func (r ScenarioReport) String() string {
+	buf := &bytes.Buffer{}
+
+	w := tabwriter.NewWriter(buf, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "STEP\tRESULT\tTIME\tFAILURE")
+	for _, step := range r.Steps {
+		result := "ok"
+		if step.Failure != "" {
+			result = "FAIL"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%.2fs\t%s\n", step.Name, result, step.Seconds, step.Failure)
+	}
+	w.Flush()
+
+	fmt.Fprintf(buf, "%s: %d of %d steps failed\n", r.Name, r.Failures(), len(r.Steps))
+
+	return buf.String()
+}
+
+// Run runs the steps against the scheme, nil scheme is loaded from the
+// scenario's Scheme file, brought up and released after the steps. Failed
+// steps don't stop the scenario, they are reported.
+func (sc Scenario) Run(s *Scheme) (report ScenarioReport) {
+	report = ScenarioReport{Name: sc.Name, Started: time.Now(), Steps: make([]StepResult, 0, len(sc.Steps))}
+
+	defer func() {
+		report.Seconds = seconds(time.Since(report.Started))
+	}()
+
+	if s == nil {
+		start := time.Now()
+
+		var err error
+		if s, err = sc.up(); err != nil {
+			report.Steps = append(report.Steps, StepResult{Name: "up " + sc.Scheme, Seconds: seconds(time.Since(start)), Failure: err.Error()})
+			return report
+		}
+
+		defer func() {
+			s.Release()
+			s.Cleanup()
+		}()
+	}
+
+	for _, step := range sc.Steps {
+		start := time.Now()
+		out, err := step.run(s)
+
+		result := StepResult{Name: step.String(), Seconds: seconds(time.Since(start)), Output: out}
+		if err != nil {
+			result.Failure = err.Error()
+		}
+
+		report.Steps = append(report.Steps, result)
+	}
+
+	return report
+}
+
+func (sc Scenario) up() (*Scheme, error) {
+	if sc.Scheme == "" {
+		return nil, fmt.Errorf("Scenario has no scheme")
+	}
+
+	fname := sc.Scheme
+	if !filepath.IsAbs(fname) {
+		fname = filepath.Join(sc.dir, fname)
+	}
+
+	s, err := NewSchemeFromFile(fname)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.Apply(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// run runs the step, it returns output of the command if any
+func (st Step) run(s *Scheme) (string, error) {
+	timeout := 2 * time.Second
+	if st.Timeout != "" {
+		timeout, _ = time.ParseDuration(st.Timeout)
+	}
+
+	var h *Host
+
+	if action := st.action(); action != "wait" && action != "link" {
+		var found bool
+
+		if h, found = s.GetHost(st.Node); !found {
+			return "", fmt.Errorf("Host %s not found", st.Node)
+		}
+	}
+
+	var out string
+	var err error
+
+	switch st.action() {
+	case "wait":
+		d, _ := time.ParseDuration(st.Wait)
+		time.Sleep(d)
+
+	case "link":
+		err = s.SetLinkState(st.Node, st.Link, strings.ToUpper(st.State))
+
+	case "run":
+		_, err = h.RunProcess(st.Run...)
+
+	case "exec":
+		out, err = h.RunCommand(append([]string{"timeout", secondsArg(timeout)}, st.Exec...)...)
+
+	case "ping":
+		out, err = st.ping(s, h, timeout)
+
+	case "connect":
+		out, err = st.connect(s, h, timeout)
+	}
+
+	if st.Output != "" && !regexp.MustCompile(st.Output).MatchString(out) {
+		return out, fmt.Errorf("Output doesn't match %s", st.Output)
+	}
+
+	switch {
+	case st.Expect == expectFailure && err == nil:
+		return out, fmt.Errorf("Expected failure, but it succeeded")
+	case st.Expect == expectFailure:
+		return out, nil
+	}
+
+	return out, err
+}
+
+func (st Step) ping(s *Scheme, h *Host, timeout time.Duration) (string, error) {
+	addr := s.address(st.Ping)
+
+	out, _ := h.RunCommand("ping", "-n", "-q", "-c", "3", "-i", "0.2", "-W", secondsArg(timeout), addr)
+
+	r := PingResult{}
+	if err := r.parse(out); err != nil {
+		return out, err
+	}
+
+	if r.Received == 0 {
+		return out, fmt.Errorf("%s is unreachable from %s", addr, h.Name)
+	}
+
+	if st.Via == "" {
+		return out, nil
+	}
+
+	via, found := s.GetHost(st.Via)
+	if !found {
+		return out, fmt.Errorf("Host %s not found", st.Via)
+	}
+
+	route, err := h.RunCommand("ip", "route", "get", addr)
+	if err != nil {
+		return route, fmt.Errorf("Error: %v, output: %s", err, route)
+	}
+
+	fields := strings.Fields(route)
+	for i := range fields {
+		if fields[i] != "via" || i+1 == len(fields) {
+			continue
+		}
+
+		for _, l := range via.Links {
+			for _, cidr := range l.Cidrs() {
+				if ip, _, _ := net.ParseCIDR(cidr); ip.String() == fields[i+1] {
+					return out, nil
+				}
+			}
+		}
+
+		return route, fmt.Errorf("%s is reached via %s, not %s", addr, fields[i+1], st.Via)
+	}
+
+	return route, fmt.Errorf("%s is directly connected, not via %s", addr, st.Via)
+}
+
+// connect opens TCP connection by bash, which is available everywhere
+func (st Step) connect(s *Scheme, h *Host, timeout time.Duration) (string, error) {
+	host, port, _ := net.SplitHostPort(st.Connect)
+
+	out, err := h.RunCommand("timeout", secondsArg(timeout), "bash", "-c", "exec 3<>/dev/tcp/"+s.address(host)+"/"+port)
+	if err != nil {
+		return out, fmt.Errorf("Unable to connect to %s, error: %v", st.Connect, err)
+	}
+
+	return out, nil
+}
+
+// address returns primary address of the host, or the target itself
+func (s *Scheme) address(target string) string {
+	h, found := s.GetHost(target)
+	if !found {
+		return target
+	}
+
+	for _, l := range h.Links {
+		if _, _, err := net.ParseCIDR(l.Cidr); err == nil {
+			return l.IP()
+		}
+	}
+
+	return target
+}
+
+func secondsArg(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+func seconds(d time.Duration) float64 {
+	return math.Round(d.Seconds()*1000) / 1000
+}
+
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name      string      `xml:"name,attr"`
+	Tests     int         `xml:"tests,attr"`
+	Failures  int         `xml:"failures,attr"`
+	Time      float64     `xml:"time,attr"`
+	Timestamp string      `xml:"timestamp,attr"`
+	Cases     []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// JUnit returns the report as JUnit XML, every step is a test case
+func (r ScenarioReport) JUnit() ([]byte, error) {
+	suite := junitSuite{
+		Name:      r.Name,
+		Tests:     len(r.Steps),
+		Failures:  r.Failures(),
+		Time:      r.Seconds,
+		Timestamp: r.Started.UTC().Format("2006-01-02T15:04:05"),
+		Cases:     make([]junitCase, 0, len(r.Steps)),
+	}
+
+	for _, step := range r.Steps {
+		c := junitCase{Name: step.Name, Classname: r.Name, Time: step.Seconds, SystemOut: step.Output}
+		if step.Failure != "" {
+			c.Failure = &junitFailure{Message: step.Failure, Text: step.Output}
+		}
+
+		suite.Cases = append(suite.Cases, c)
+	}
+
+	out, err := xml.MarshalIndent(junitSuites{Suites: []junitSuite{suite}}, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), append(out, '\n')...), nil
+}
@@ -0,0 +1,134 @@
+package mn
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestScenario(t *testing.T) {
+	sc, err := NewScenarioFromData([]byte(`
+name: smoke
+steps:
+  - node: h2
+    ping: h1
+    via: h1
+  - node: h1
+    ping: 10.9.0.1
+    expect: failure
+  - node: h2
+    connect: h1:80
+  - node: h1
+    exec: [ip, addr]
+    output: 10\.0\.0\.1/24
+  - node: h1
+    link: eth1
+    state: down
+  - node: h2
+    ping: h1
+    expect: failure
+    name: h1 is isolated
+`), FormatYAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake := NewRecordingExecutor().
+		On("ip netns exec h2 ping", "2 packets transmitted, 2 received, 0% packet loss", nil).
+		On("ip netns exec h1 ping", "3 packets transmitted, 0 received, 100% packet loss", errors.New("exit status 1")).
+		On("ip netns exec h2 ip route get 10.0.0.1", "10.0.0.1 via 10.1.0.1 dev eth0 src 10.1.0.2 uid 0", nil).
+		On("ip netns exec h2 timeout 2 bash", "bash: connect: Connection refused", errors.New("exit status 1")).
+		On("ip netns exec h1 timeout 2 ip addr", "inet 10.0.0.1/24 scope global veth0", nil)
+
+	scheme := newFakeScheme(fake)
+
+	report := sc.Run(scheme)
+
+	expected := []struct{ name, failure string }{
+		{"h2 pings h1 via h1", ""},
+		{"h1 pings 10.9.0.1 fails", ""},
+		{"h2 connects to h1:80", "Unable to connect to h1:80, error: exit status 1"},
+		{"h1 executes ip addr", ""},
+		{"h1 link eth1 down", ""},
+		{"h1 is isolated", "Expected failure, but it succeeded"},
+	}
+
+	if len(report.Steps) != len(expected) {
+		t.Fatalf("Unexpected report:\n%s", report)
+	}
+
+	for i, e := range expected {
+		if report.Steps[i].Name != e.name || report.Steps[i].Failure != e.failure {
+			t.Fatalf("Step #%d\nExpected: %s %q\nObtained: %s %q", i, e.name, e.failure, report.Steps[i].Name, report.Steps[i].Failure)
+		}
+	}
+
+	if h2, _ := scheme.GetHost("h2"); h2.Links[0].State != "DOWN" || report.Failures() != 2 {
+		t.Fatalf("Unexpected report:\n%s", report)
+	}
+
+	out, err := report.JUnit()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{
+		`<testsuite name="smoke" tests="6" failures="2"`,
+		`<testcase name="h2 connects to h1:80" classname="smoke"`,
+		`<failure message="Expected failure, but it succeeded">2 packets transmitted, 2 received, 0% packet loss</failure>`,
+	} {
+		if !strings.Contains(string(out), s) {
+			t.Fatalf("Expected %s in:\n%s", s, out)
+		}
+	}
+}
+
+func TestScenarioErrors(t *testing.T) {
+	for _, data := range []string{
+		`{"Steps": [{"Node": "h1", "Ping": "h2", "Connect": "h2:80"}]}`,
+		`{"Steps": [{"Ping": "h2"}]}`,
+		`{"Steps": [{"Node": "h1", "Connect": "h2"}]}`,
+		`{"Steps": [{"Node": "h1", "Link": "eth0", "State": "flapping"}]}`,
+		`{"Steps": [{"Wait": "forever"}]}`,
+		`{"Steps": [{"Node": "h1", "Exec": ["true"], "Expect": "maybe"}]}`,
+		`{"Steps": [{"Node": "h1", "Exec": ["true"], "Output": "("}]}`,
+		`{"Steps": [{"Node": "h1", "Traceroute": "h2"}]}`,
+		`{"Steps": [{"Wait": "1s", "Expect": "failure"}]}`,
+		`{"Steps": [{"Node": "h1", "Link": "eth1", "State": "down", "Output": "down"}]}`,
+		`{"Steps": [{"Node": "h1", "Run": ["sleep", "1"], "Output": "."}]}`,
+	} {
+		if _, err := NewScenarioFromData([]byte(data), FormatJSON); err == nil {
+			t.Fatal("Expected error for", data)
+		}
+	}
+
+	// scheme, which can't be loaded, is a failed step
+	sc := Scenario{Name: "broken", Scheme: "missing.json", Steps: []Step{{Wait: "1s"}}}
+	if report := sc.Run(nil); report.Failures() != 1 || report.Steps[0].Name != "up missing.json" {
+		t.Fatalf("Unexpected report:\n%s", report)
+	}
+}
+
+func TestScenarioLinkExpect(t *testing.T) {
+	sc, err := NewScenarioFromData([]byte(`
+steps:
+  - node: h1
+    link: eth9
+    state: down
+    expect: failure
+  - node: h1
+    link: eth1
+    state: down
+    expect: failure
+`), FormatYAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// expected failure of the link, which isn't there, is a success
+	report := sc.Run(newFakeScheme(NewRecordingExecutor()))
+
+	if report.Steps[0].Failure != "" || report.Steps[1].Failure != "Expected failure, but it succeeded" {
+		t.Fatalf("Unexpected report:\n%s", report)
+	}
+}
@@ -2,16 +2,18 @@ package mn
 
 import (
 	"encoding/json"
-	"fmt"
 	"io/ioutil"
 	"log"
 )
 
 // Scheme defenition
 type Scheme struct {
+	Version  int
+	Topology string `json:",omitempty"`
 	Switches []*Switch
 	Hosts    []*Host
-	pairs    map[string]bool
+	exec     Executor
+	warnings []string
 }
 
 // Satisfies stringer interface
@@ -27,9 +29,9 @@ func (s Scheme) String() string {
 // NewScheme creates instance of the scheme
 func NewScheme() *Scheme {
 	return &Scheme{
-		make([]*Switch, 0),
-		make([]*Host, 0),
-		make(map[string]bool),
+		Version:  SchemeVersion,
+		Switches: make([]*Switch, 0),
+		Hosts:    make([]*Host, 0),
 	}
 }
 
@@ -40,22 +42,27 @@ func NewSchemeFromJSON(fname string) (*Scheme, error) {
 		return nil, err
 	}
 
-	scheme := NewScheme()
-
-	err = json.Unmarshal(data, scheme)
-	if err != nil {
-		return nil, err
-	}
-
-	return scheme, nil
+	return NewSchemeFromData(data, FormatJSON)
 }
 
 // AddNode adds node into scheme
 func (s *Scheme) AddNode(n interface{}) *Scheme {
 	switch t := n.(type) {
 	case *Switch:
+		if s.exec != nil {
+			t.SetExecutor(s.exec)
+		}
+		if s.Topology != "" {
+			t.SetTopology(s.Topology)
+		}
 		s.Switches = append(s.Switches, n.(*Switch))
 	case *Host:
+		if s.exec != nil {
+			t.SetExecutor(s.exec)
+		}
+		if s.Topology != "" {
+			t.SetTopology(s.Topology)
+		}
 		s.Hosts = append(s.Hosts, n.(*Host))
 	default:
 		log.Printf("Wrong call, unknown type %s for %v\n", t, n)
@@ -64,6 +71,42 @@ func (s *Scheme) AddNode(n interface{}) *Scheme {
 	return s
 }
 
+// SetExecutor sets executor for the scheme and all its nodes
+func (s *Scheme) SetExecutor(e Executor) *Scheme {
+	s.exec = e
+
+	for _, sw := range s.Switches {
+		sw.SetExecutor(e)
+	}
+
+	for _, host := range s.Hosts {
+		host.SetExecutor(e)
+	}
+
+	return s
+}
+
+// SetTopology sets ID of the topology, which owns all scheme's resources
+func (s *Scheme) SetTopology(id string) *Scheme {
+	s.Topology = id
+
+	for _, sw := range s.Switches {
+		sw.SetTopology(id)
+	}
+
+	for _, host := range s.Hosts {
+		host.SetTopology(id)
+	}
+
+	return s
+}
+
+// Warnings returns problems of the import, which didn't fail it,
+// e.g. ignored options and skipped links
+func (s Scheme) Warnings() []string {
+	return s.warnings
+}
+
 // GetNode returns Node depending on type
 func (s *Scheme) GetNode(name string) (Node, bool) {
 	if n, found := s.GetHost(name); found {
@@ -123,119 +166,21 @@ func (s Scheme) Export() string {
 	return s.String()
 }
 
-// Recover nodes scheme
-func (s Scheme) Recover() error {
-	for node := range s.Nodes() {
-		switch t := node.(type) {
-		case *Switch:
-			s.recoverSwitchPorts(node.(*Switch))
-		case *Host:
-			s.recoverHostLinks(node.(*Host))
-		default:
-			log.Println("Unexpected type", t)
-		}
-	}
-
-	for _, host := range s.Hosts {
-		if err := host.recoverProcs(); err != nil {
-			return err
-		}
-	}
-
-	return nil
-}
-
-// Recover switch to host connectivity
-func (s Scheme) recoverSwitchPorts(sw *Switch) error {
-	for _, port := range sw.Ports {
-		if port.Exists() {
-			continue
-		}
-
-		peer, found := s.GetNode(port.Peer.NodeName)
-		if !found {
-			return fmt.Errorf("Can't find host %s", port.Peer.NodeName)
-		}
-
-		link := peer.GetLinks().LinkByPeer(port.Peer)
-		pair := Pair{port, link}
-
-		hash := port.Name + "-" + link.Name
-		if s.pairs[hash] {
-			log.Printf("Wrong scheme. Two identic pairs found: %v\n", pair)
-			continue
-		}
-
-		// patch link
-		if sw2, found := s.GetSwitch(peer.NodeName()); found {
-			sw.AddPatchPort(pair.Left)
-			sw2.AddPatchPort(pair.Right)
-			continue
-		}
-
-		if err := pair.Create(); err != nil {
-			return err
-		}
-
-		if err := sw.AddLink(pair.Left); err != nil {
-			return err
-		}
-
-		_, err := pair.Up()
-		if err != nil {
-			return err
-		}
-
-		s.pairs[hash] = true
+// Apply creates everything from the scheme, which doesn't exist yet.
+// Loading of the scheme has no side effects, Apply is the step, which
+// changes the system.
+func (s Scheme) Apply() error {
+	plan, err := s.Plan()
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return plan.Apply()
 }
 
-// recoverHostLinks host to host connectivity
-func (s Scheme) recoverHostLinks(h *Host) error {
-	for _, left := range h.Links {
-		peer, found := s.GetHost(left.Peer.NodeName)
-		if !found {
-			continue
-		}
-
-		right := peer.Links.LinkByPeer(left.Peer)
-		if right.NodeName == "" {
-			// nothing found
-			// @todo-maybe return (Link, bool) form LinkByPeer
-			continue
-		}
-
-		pair := Pair{left, right}
-
-		hash := left.NodeName + left.Name + right.NodeName + right.Name
-		if s.pairs[hash] {
-			continue
-		}
-
-		if err := pair.Create(); err != nil {
-			return err
-		}
-
-		_, err := pair.Up()
-		if err != nil {
-			return err
-		}
-
-		h.AddLink(left)
-
-		h2, found := s.GetHost(right.NodeName)
-		if !found {
-			return fmt.Errorf("Can't find host node %s", right.NodeName)
-		}
-
-		h2.AddLink(right)
-
-		s.pairs[hash] = true
-	}
-
-	return nil
+// Recover is the same as Apply
+func (s Scheme) Recover() error {
+	return s.Apply()
 }
 
 // Release nodes
@@ -8,6 +8,8 @@ import (
 const exampleScheme = "../../cmd/example.json"
 
 func TestSchemeSimple(t *testing.T) {
+	requireSystem(t)
+
 	scheme := NewScheme()
 
 	defer scheme.Release()
@@ -59,6 +61,8 @@ func TestSchemeSimple(t *testing.T) {
 
 // @todo make it "self-hosted". hardcoded to example.json
 func TestSchemeFromJson(t *testing.T) {
+	requireSystem(t)
+
 	scheme, err := NewSchemeFromJSON(exampleScheme)
 	if err != nil {
 		t.Fatal(err)
@@ -86,6 +90,8 @@ func TestSchemeFromJson(t *testing.T) {
 
 // @todo test everything created
 func TestSchemeApply(t *testing.T) {
+	requireSystem(t)
+
 	scheme, err := NewSchemeFromJSON(exampleScheme)
 	if err != nil {
 		t.Fatal(err)
@@ -93,6 +99,10 @@ func TestSchemeApply(t *testing.T) {
 
 	defer scheme.Release()
 
+	if err := scheme.Apply(); err != nil {
+		t.Fatal(err)
+	}
+
 	s1, found := scheme.GetNode("s1")
 	if !found {
 		t.Fatal("Expected switch", s1.NodeName(), "not found")
@@ -111,8 +121,6 @@ func TestSchemeApply(t *testing.T) {
 		t.Fatal("Expected netns", h1.NetNs().Name(), "does not exist")
 	}
 
-	scheme.Recover()
-
 	// wait until ping ends
 	time.Sleep(time.Second * 5)
 }
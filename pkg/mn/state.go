@@ -0,0 +1,231 @@
+package mn
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// State is a snapshot of the running scheme, which is persisted in the StateDir.
+// Unlike scheme's JSON, loading of the state has no side effects.
+type State struct {
+	Topology string `json:",omitempty"`
+	Switches []SwitchState
+	Hosts    []HostState
+}
+
+// SwitchState is a persisted switch
+type SwitchState struct {
+	Name       string
+	Controller string
+	STP        bool `json:",omitempty"`
+	Ports      Links
+}
+
+// HostState is a persisted host
+type HostState struct {
+	Name   string
+	Cgroup *CgroupState `json:",omitempty"`
+	Links  Links
+	Procs  []ProcessState
+}
+
+// CgroupState is a persisted host's cgroup
+type CgroupState struct {
+	Name        string
+	Controllers []Controller
+}
+
+// ProcessState is a persisted process, Pid is 0 if process isn't running
+type ProcessState struct {
+	Pid     int
+	Command string
+	Args    []string
+	Output  string
+}
+
+// StateFile returns path of the topology's state file
+func StateFile(id string) string {
+	return filepath.Join(StateDir, topologyOrDefault(id)+".json")
+}
+
+// State returns snapshot of the scheme
+func (s *Scheme) State() State {
+	state := State{
+		Topology: s.Topology,
+		Switches: make([]SwitchState, 0, len(s.Switches)),
+		Hosts:    make([]HostState, 0, len(s.Hosts)),
+	}
+
+	for _, sw := range s.Switches {
+		state.Switches = append(state.Switches, SwitchState{Name: sw.Name, Controller: sw.Controller, STP: sw.STP, Ports: sw.Ports})
+	}
+
+	for _, h := range s.Hosts {
+		hs := HostState{Name: h.Name, Links: h.Links, Procs: make([]ProcessState, 0, len(h.Procs))}
+
+		if h.Cgroup != nil {
+			hs.Cgroup = &CgroupState{Name: h.Cgroup.Name, Controllers: h.Cgroup.Controllers}
+		}
+
+		for _, p := range h.Procs {
+			hs.Procs = append(hs.Procs, ProcessState{Pid: p.GetPid(), Command: p.Command, Args: p.Args, Output: p.Output})
+		}
+
+		state.Hosts = append(state.Hosts, hs)
+	}
+
+	return state
+}
+
+// SaveState writes snapshot of the scheme into the topology's state file
+func (s *Scheme) SaveState() error {
+	return writeState(s.State())
+}
+
+func writeState(state State) error {
+	data, err := json.MarshalIndent(state, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	fname := StateFile(state.Topology)
+
+	if err := os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
+		return err
+	}
+
+	// state file is replaced atomically, so it's never half-written
+	if err := ioutil.WriteFile(fname+".tmp", data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(fname+".tmp", fname)
+}
+
+// LoadState reattaches to the running topology, saved by SaveState.
+// Loaded scheme is reconciled with the system: switches, hosts, ports and
+// links which don't exist anymore are dropped, processes are attached
+// by pid if they are still running. Optional executor is set to the scheme.
+func LoadState(id string, e ...Executor) (*Scheme, error) {
+	data, err := ioutil.ReadFile(StateFile(id))
+	if err != nil {
+		return nil, err
+	}
+
+	state := State{}
+
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+
+	scheme := NewScheme()
+
+	if len(e) > 0 {
+		scheme.SetExecutor(e[0])
+	}
+
+	for _, ss := range state.Switches {
+		scheme.AddNode(&Switch{Name: ss.Name, Controller: ss.Controller, STP: ss.STP, Ports: ss.Ports})
+	}
+
+	pids := make(map[*Process]int)
+
+	for _, hs := range state.Hosts {
+		h := &Host{Name: hs.Name, netns: &NetNs{name: hs.Name}, Links: hs.Links}
+
+		if hs.Cgroup != nil {
+			h.Cgroup, _ = NewCgroup(hs.Cgroup.Name)
+			h.Cgroup.Controllers = hs.Cgroup.Controllers
+		}
+
+		for _, ps := range hs.Procs {
+			p := &Process{Command: ps.Command, Args: ps.Args, Output: ps.Output}
+			h.Procs = append(h.Procs, p)
+			pids[p] = ps.Pid
+		}
+
+		scheme.AddNode(h)
+	}
+
+	scheme.SetTopology(state.Topology)
+	scheme.reattach(pids)
+
+	return scheme, nil
+}
+
+// reattach drops everything, which doesn't exist in the system
+// and attaches still running processes
+func (s *Scheme) reattach(pids map[*Process]int) {
+	switches := make([]*Switch, 0, len(s.Switches))
+
+	for _, sw := range s.Switches {
+		if !sw.Exists() {
+			log.Println("Bridge", sw.Name, "doesn't exist anymore")
+			continue
+		}
+
+		ports := make(Links, 0, len(sw.Ports))
+
+		for _, port := range sw.Ports {
+			if !sw.HasPort(port.Name) {
+				log.Println("Port", port.Name, "of", sw.Name, "doesn't exist anymore")
+				continue
+			}
+
+			ports = append(ports, port)
+		}
+
+		sw.Ports = ports
+		switches = append(switches, sw)
+	}
+
+	hosts := make([]*Host, 0, len(s.Hosts))
+
+	for _, h := range s.Hosts {
+		if !h.NetNs().Exists() {
+			log.Println("Netns", h.NetNs().Name(), "doesn't exist anymore")
+			continue
+		}
+
+		links := make(Links, 0, len(h.Links))
+
+		for _, l := range h.Links {
+			if !l.Exists() {
+				log.Println("Link", l.Name, "of", h.Name, "doesn't exist anymore")
+				continue
+			}
+
+			links = append(links, l)
+		}
+
+		h.Links = links
+
+		for _, p := range h.Procs {
+			pid := pids[p]
+			if pid == 0 {
+				continue
+			}
+
+			if !p.attach(h.executor(), pid, h.NetNs().Name()) {
+				log.Printf("Process [%d] %s isn't running anymore", pid, p.CommandLine())
+			}
+		}
+
+		hosts = append(hosts, h)
+	}
+
+	s.Switches = switches
+	s.Hosts = hosts
+}
+
+// RemoveState removes the topology's state file
+func RemoveState(id string) error {
+	if err := os.Remove(StateFile(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
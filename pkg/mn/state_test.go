@@ -0,0 +1,78 @@
+package mn
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestState(t *testing.T) {
+	pid := strconv.Itoa(os.Getpid())
+
+	fake := NewRecordingExecutor().
+		On("ip netns list", "h1\nh2\n", nil).
+		On("ovs-vsctl list-ports s1", "h1-eth0\n", nil).
+		On("ip netns exec h1 ip link show eth1", "", errors.New("exit status 1")).
+		On("ip netns exec h2 ip link show eth0", "", errors.New("exit status 1")).
+		On("ps -o args= -p "+pid, "ping -c1 10.0.0.1\n", nil).
+		On("ps -o args= -p 99999", "", errors.New("exit status 1")).
+		On("ip netns identify "+pid, "h2\n", nil)
+
+	scheme := newFakeScheme(fake).SetTopology("lab")
+
+	h2, _ := scheme.GetHost("h2")
+	h2.Procs = append(h2.Procs,
+		&Process{Command: "ping", Args: []string{"-c1", "10.0.0.1"}, Output: "/tmp/output.1"},
+		&Process{Command: "sleep", Args: []string{"100"}, Output: "/tmp/output.2"},
+	)
+
+	if err := scheme.SaveState(); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(StateFile("lab"))
+
+	// processes weren't really started, pids are patched
+	state := scheme.State()
+	if state.Hosts[1].Procs[0].Pid != 0 {
+		t.Fatal("Expected pid 0 of not running process, obtained:", state.Hosts[1].Procs[0].Pid)
+	}
+
+	state.Hosts[1].Procs[0].Pid = os.Getpid()
+	state.Hosts[1].Procs[1].Pid = 99999
+
+	if err := writeState(state); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadState("lab", fake)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded.Topology != "lab" {
+		t.Fatal("Expected topology lab, obtained:", loaded.Topology)
+	}
+
+	if len(loaded.Switches) != 1 || len(loaded.Switches[0].Ports) != 1 {
+		t.Fatal("Expected s1 with one port, obtained:", loaded.Switches)
+	}
+
+	h1, _ := loaded.GetHost("h1")
+	if len(h1.Links) != 1 || h1.Links[0].Name != "veth0" {
+		t.Fatal("Expected only veth0 of h1, obtained:", h1.Links)
+	}
+
+	h2, _ = loaded.GetHost("h2")
+	if len(h2.Links) != 0 {
+		t.Fatal("Expected no links of h2, obtained:", h2.Links)
+	}
+
+	if p := h2.Procs[0]; p.GetPid() != os.Getpid() || p.Output != "/tmp/output.1" {
+		t.Fatal("Expected attached process", os.Getpid(), "obtained:", p.GetPid(), p.Output)
+	}
+
+	if p := h2.Procs[1]; p.GetPid() != 0 {
+		t.Fatal("Expected not running process, obtained pid:", p.GetPid())
+	}
+}
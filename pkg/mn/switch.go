@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
 )
 
 // Switch model
@@ -11,6 +12,10 @@ type Switch struct {
 	Name       string
 	Ports      Links
 	Controller string
+	STP        bool `json:",omitempty"`
+	exec       Executor
+	topology   string
+	created    bool
 }
 
 // String implements Stringer interface
@@ -47,35 +52,26 @@ func NewSwitch(name ...string) (*Switch, error) {
 	return s, nil
 }
 
-// UnmarshalJSON implements unmarshaller
-func (s *Switch) UnmarshalJSON(b []byte) error {
-	type tmp Switch
-	t := tmp{}
-
-	if err := json.Unmarshal(b, &t); err != nil {
-		return err
+// Create creates switch, bridge is tagged with topology ID
+func (s *Switch) Create() error {
+	args := []string{"add-br", s.Name, "--", "set", "bridge", s.Name, "external_ids:" + topologyKey + "=" + topologyOrDefault(s.topology)}
+	if s.STP {
+		args = append(args, "stp_enable=true")
 	}
 
-	s.Name = t.Name
-	s.Ports = t.Ports
-	if !s.Exists() {
-		if err := s.Create(); err != nil {
-			return err
-		}
+	out, err := s.executor().Run("ovs-vsctl", args...)
+	if err != nil {
+		return fmt.Errorf("Error: %v, output: %s", err, out)
 	}
 
-	if s.Controller != "" {
-		if err := s.SetController(s.Controller); err != nil {
-			return err
-		}
-	}
+	s.created = true
 
 	return nil
 }
 
-// Create creates switch
-func (s *Switch) Create() error {
-	out, err := RunCommand("ovs-vsctl", "add-br", s.Name)
+// tag marks bridge as owned by its topology
+func (s *Switch) tag() error {
+	out, err := s.executor().Run("ovs-vsctl", "set", "bridge", s.Name, "external_ids:"+topologyKey+"="+topologyOrDefault(s.topology))
 	if err != nil {
 		return fmt.Errorf("Error: %v, output: %s", err, out)
 	}
@@ -85,7 +81,7 @@ func (s *Switch) Create() error {
 
 // Exists methods check whether Switch exists or not
 func (s *Switch) Exists() bool {
-	_, err := RunCommand("ovs-vsctl", "br-exists", s.Name)
+	_, err := s.executor().Run("ovs-vsctl", "br-exists", s.Name)
 	return err == nil
 }
 
@@ -100,9 +96,16 @@ func (s *Switch) AddLink(l Link) error {
 
 // AddPort adds port to the link
 func (s *Switch) AddPort(l Link) error {
-	out, err := RunCommand("ovs-vsctl", "add-port", s.Name, l.Name)
-	if err != nil {
-		return fmt.Errorf("Error: %v, output: %s", err, out)
+	if err := s.addPort(l); err != nil {
+		return err
+	}
+
+	if l.exec == nil {
+		l.exec = s.exec
+	}
+
+	if l.topology == "" {
+		l.topology = s.topology
 	}
 
 	s.Ports = append(s.Ports, l)
@@ -110,30 +113,114 @@ func (s *Switch) AddPort(l Link) error {
 	return nil
 }
 
+func (s *Switch) addPort(l Link) error {
+	out, err := s.executor().Run("ovs-vsctl", "add-port", s.Name, l.Name)
+	if err != nil {
+		return fmt.Errorf("Error: %v, output: %s", err, out)
+	}
+
+	return nil
+}
+
 // AddPatchPort adds type to path
 func (s *Switch) AddPatchPort(l Link) error {
-	if out, err := RunCommand("ovs-vsctl", "add-port", s.NodeName(), l.Name); err != nil {
+	if err := s.addPatchPort(l); err != nil {
+		return err
+	}
+
+	l = l.SetState("UP")
+
+	if l.exec == nil {
+		l.exec = s.exec
+	}
+
+	if l.topology == "" {
+		l.topology = s.topology
+	}
+
+	s.Ports = append(s.Ports, l)
+
+	return nil
+}
+
+func (s *Switch) addPatchPort(l Link) error {
+	if out, err := s.executor().Run("ovs-vsctl", "add-port", s.NodeName(), l.Name); err != nil {
 		return fmt.Errorf("Error: %v, output: %s", err, out)
 	}
 
-	if out, err := RunCommand("ovs-vsctl", "set", "interface", l.Name, "type=patch"); err != nil {
+	if out, err := s.executor().Run("ovs-vsctl", "set", "interface", l.Name, "type=patch"); err != nil {
 		return fmt.Errorf("Error: %v, output: %s", err, out)
 	}
 
-	if out, err := RunCommand("ovs-vsctl", "set", "interface", l.Name, "options:peer="+l.Peer.Name); err != nil {
+	if out, err := s.executor().Run("ovs-vsctl", "set", "interface", l.Name, "options:peer="+l.Peer.Name); err != nil {
 		return fmt.Errorf("Error: %v, output: %s", err, out)
 	}
 
-	l = l.SetState("UP")
+	return nil
+}
 
-	s.Ports = append(s.Ports, l)
+// DelPort removes port from the switch
+func (s *Switch) DelPort(name string) error {
+	if err := s.delPort(name); err != nil {
+		return err
+	}
+
+	for i := range s.Ports {
+		if s.Ports[i].Name == name {
+			s.Ports = append(s.Ports[:i], s.Ports[i+1:]...)
+			break
+		}
+	}
 
 	return nil
 }
 
+func (s *Switch) delPort(name string) error {
+	if out, err := s.executor().Run("ovs-vsctl", "--if-exists", "del-port", s.Name, name); err != nil {
+		return fmt.Errorf("Error: %v, output: %s", err, out)
+	}
+
+	return nil
+}
+
+// DelController removes controller from the switch
+func (s *Switch) DelController() error {
+	if out, err := s.executor().Run("ovs-vsctl", "del-controller", s.Name); err != nil {
+		return fmt.Errorf("Error: %v, output: %s", err, out)
+	}
+
+	return nil
+}
+
+// HasPort checks whether port is attached to the switch
+func (s *Switch) HasPort(name string) bool {
+	out, err := s.executor().Run("ovs-vsctl", "list-ports", s.Name)
+	if err != nil {
+		return false
+	}
+
+	for _, port := range strings.Split(out, "\n") {
+		if strings.TrimSpace(port) == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetController returns controller address, which is set to the bridge
+func (s *Switch) GetController() (string, error) {
+	out, err := s.executor().Run("ovs-vsctl", "get-controller", s.Name)
+	if err != nil {
+		return "", fmt.Errorf("Error: %v, output: %s", err, out)
+	}
+
+	return strings.TrimSpace(out), nil
+}
+
 // SetController sets Controller name and address
 func (s *Switch) SetController(addr string) error {
-	if out, err := RunCommand("ovs-vsctl", "set-controller", s.NodeName(), addr); err != nil {
+	if out, err := s.executor().Run("ovs-vsctl", "set-controller", s.NodeName(), addr); err != nil {
 		return fmt.Errorf("Error: %v, output: %s", err, out)
 	}
 
@@ -148,9 +235,55 @@ func (s *Switch) SetController(addr string) error {
 	return nil
 }
 
+// SetSTP enables or disables spanning tree protocol of the bridge
+func (s *Switch) SetSTP(on bool) error {
+	if out, err := s.executor().Run("ovs-vsctl", "set", "bridge", s.Name, fmt.Sprintf("stp_enable=%t", on)); err != nil {
+		return fmt.Errorf("Error: %v, output: %s", err, out)
+	}
+
+	return nil
+}
+
+// STPEnabled checks whether spanning tree protocol of the bridge is enabled
+func (s *Switch) STPEnabled() bool {
+	out, err := s.executor().Run("ovs-vsctl", "get", "bridge", s.Name, "stp_enable")
+	return err == nil && strings.TrimSpace(out) == "true"
+}
+
+// SetExecutor sets executor for the switch and its ports
+func (s *Switch) SetExecutor(e Executor) {
+	s.exec = e
+
+	for i := range s.Ports {
+		s.Ports[i] = s.Ports[i].SetExecutor(e)
+	}
+}
+
+// SetTopology sets ID of the topology, which owns the switch and its ports
+func (s *Switch) SetTopology(id string) {
+	changed := s.topology != id
+	s.topology = id
+
+	// bridge, which has been created by NewSwitch before the switch is
+	// added to the scheme, is tagged again
+	if s.created && changed {
+		if err := s.tag(); err != nil {
+			log.Println("Unable to tag bridge", s.Name, err)
+		}
+	}
+
+	for i := range s.Ports {
+		s.Ports[i] = s.Ports[i].SetTopology(id)
+	}
+}
+
+func (s Switch) executor() Executor {
+	return executorOrDefault(s.exec)
+}
+
 // Release removes bridge
 func (s Switch) Release() error {
-	out, err := RunCommand("ovs-vsctl", "del-br", s.Name)
+	out, err := s.executor().Run("ovs-vsctl", "del-br", s.Name)
 	if err != nil {
 		log.Println("Unable to delete bridge", s.Name, err, out)
 	}
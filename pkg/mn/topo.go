@@ -0,0 +1,632 @@
+package mn
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/3d0c/mininet/pkg/pool"
+)
+
+// Topo is a compact description of the network: nodes and links between them.
+// It's expanded into the Scheme with mirrored links, peers, interface names
+// and addresses generated. Text form of the topo is a line per directive:
+//
+//	# comment
+//	topology lab
+//	pool 10.0.0.0/16
+//	pool6 fd00::/64
+//	switch s1 s2 controller=tcp:127.0.0.1:6633
+//	switch s[3-4] stp
+//	host h[1-50]
+//	router r1
+//	link s1 h[1-25]
+//	link s2 h[26-50] net=10.1.0.0/24
+//	link s1 s2
+//	link r1:eth0=10.0.0.254/16,fd00::fe/64 s1
+//	route h[1-25] default via 10.0.0.254
+//	route h[1-25] default via fd00::fe
+//
+// Link endpoint is node[:ifname][=cidr[,cidr...]|noip], the first address is
+// the primary one. Hosts get IPv6 addresses too, if pool6 or net6 is set.
+// Node names may contain
+// a range [from-to]. Link between a node and range connects the node
+// to every node in the range, two ranges of the same size are connected
+// pairwise. Router is a host, forwarding is enabled for hosts with
+// multiple links. Switches with stp run spanning tree, so loops are allowed.
+type Topo struct {
+	ID       string
+	Pool     string
+	Pool6    string
+	Switches []TopoSwitch
+	Hosts    []string
+	Links    []TopoLink
+	Routes   []TopoRoute
+}
+
+// TopoSwitch is a switch of the topo
+type TopoSwitch struct {
+	Name       string
+	Controller string
+	STP        bool
+}
+
+// TopoEndpoint is an end of the topo's link, empty IfName and Cidr are generated
+type TopoEndpoint struct {
+	Node   string
+	IfName string
+	Cidr   string
+	Addrs  []string
+}
+
+// TopoLink is a link of the topo, addresses are taken from the Net or
+// from the topo's Pool if it's empty, IPv6 ones from the Net6 or Pool6
+type TopoLink struct {
+	Left  TopoEndpoint
+	Right TopoEndpoint
+	Net   string
+	Net6  string
+}
+
+// TopoRoute is a route of the topo's host, it's set to the link,
+// which network contains the gateway
+type TopoRoute struct {
+	Node string
+	Dst  string
+	Gw   string
+}
+
+// FormatTopo is a format of the topo file
+const FormatTopo = "topo"
+
+var rangeRe = regexp.MustCompile(`^(.*)\[(\d+)-(\d+)\](.*)$`)
+
+// NewTopo creates an empty topo
+func NewTopo(id ...string) *Topo {
+	t := &Topo{}
+
+	if len(id) > 0 {
+		t.ID = id[0]
+	}
+
+	return t
+}
+
+// NewTopoFromFile parses topo file
+func NewTopoFromFile(fname string) (*Topo, error) {
+	data, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseTopo(data)
+}
+
+// ParseTopo parses text form of the topo
+func ParseTopo(data []byte) (*Topo, error) {
+	t := NewTopo()
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if err := t.parse(fields); err != nil {
+			return nil, fmt.Errorf("Line %d: %v", n, err)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func (t *Topo) parse(fields []string) error {
+	args := fields[1:]
+
+	switch fields[0] {
+	case "topology":
+		if len(args) != 1 {
+			return fmt.Errorf("expected: topology {id}")
+		}
+		t.ID = args[0]
+
+	case "pool":
+		if len(args) != 1 {
+			return fmt.Errorf("expected: pool {cidr}")
+		}
+		if _, _, err := net.ParseCIDR(args[0]); err != nil {
+			return err
+		}
+		t.Pool = args[0]
+
+	case "pool6":
+		if len(args) != 1 {
+			return fmt.Errorf("expected: pool6 {cidr}")
+		}
+		if err := checkNetwork6(args[0]); err != nil {
+			return err
+		}
+		t.Pool6 = args[0]
+
+	case "switch":
+		controller, stp := "", false
+		names := make([]string, 0, len(args))
+
+		for _, arg := range args {
+			switch {
+			case strings.HasPrefix(arg, "controller="):
+				controller = strings.TrimPrefix(arg, "controller=")
+			case arg == "stp":
+				stp = true
+			default:
+				names = append(names, arg)
+			}
+		}
+
+		names, err := expandNames(names...)
+		if err != nil {
+			return err
+		}
+
+		for _, name := range names {
+			t.Switches = append(t.Switches, TopoSwitch{Name: name, Controller: controller, STP: stp})
+		}
+
+	case "host", "router":
+		names, err := expandNames(args...)
+		if err != nil {
+			return err
+		}
+
+		t.AddHost(names...)
+
+	case "link":
+		if len(args) < 2 || len(args) > 4 {
+			return fmt.Errorf("expected: link {endpoint} {endpoint} [net={cidr}] [net6={cidr}]")
+		}
+
+		network, network6 := "", ""
+		for _, arg := range args[2:] {
+			switch {
+			case strings.HasPrefix(arg, "net="):
+				network = strings.TrimPrefix(arg, "net=")
+				if _, _, err := net.ParseCIDR(network); err != nil {
+					return err
+				}
+			case strings.HasPrefix(arg, "net6="):
+				network6 = strings.TrimPrefix(arg, "net6=")
+				if err := checkNetwork6(network6); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unexpected %s, expected net={cidr} or net6={cidr}", arg)
+			}
+		}
+
+		left, err := parseEndpoints(args[0])
+		if err != nil {
+			return err
+		}
+
+		right, err := parseEndpoints(args[1])
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case len(left) == 1:
+			for _, r := range right {
+				t.Links = append(t.Links, TopoLink{Left: left[0], Right: r, Net: network, Net6: network6})
+			}
+		case len(right) == 1:
+			for _, l := range left {
+				t.Links = append(t.Links, TopoLink{Left: l, Right: right[0], Net: network, Net6: network6})
+			}
+		case len(left) == len(right):
+			for i := range left {
+				t.Links = append(t.Links, TopoLink{Left: left[i], Right: right[i], Net: network, Net6: network6})
+			}
+		default:
+			return fmt.Errorf("ranges %s and %s have different sizes", args[0], args[1])
+		}
+
+	case "route":
+		if len(args) != 4 || args[2] != "via" {
+			return fmt.Errorf("expected: route {node} {dst|default} via {gw}")
+		}
+
+		if net.ParseIP(args[3]) == nil {
+			return fmt.Errorf("wrong gateway %s", args[3])
+		}
+
+		dst := args[1]
+		if dst == "default" {
+			dst = defaultDst(args[3])
+		}
+
+		names, err := expandNames(args[0])
+		if err != nil {
+			return err
+		}
+
+		for _, name := range names {
+			t.AddRoute(name, dst, args[3])
+		}
+
+	default:
+		return fmt.Errorf("unknown directive %s", fields[0])
+	}
+
+	return nil
+}
+
+// AddSwitch adds switches to the topo
+func (t *Topo) AddSwitch(names ...string) *Topo {
+	for _, name := range names {
+		t.Switches = append(t.Switches, TopoSwitch{Name: name})
+	}
+
+	return t
+}
+
+// AddHost adds hosts to the topo
+func (t *Topo) AddHost(names ...string) *Topo {
+	t.Hosts = append(t.Hosts, names...)
+	return t
+}
+
+// AddLink adds link between two nodes, optional network is used for addresses
+func (t *Topo) AddLink(left, right string, network ...string) *Topo {
+	l := TopoLink{Left: TopoEndpoint{Node: left}, Right: TopoEndpoint{Node: right}}
+
+	if len(network) > 0 {
+		l.Net = network[0]
+	}
+
+	t.Links = append(t.Links, l)
+
+	return t
+}
+
+// AddRoute adds route to the host
+func (t *Topo) AddRoute(node, dst, gw string) *Topo {
+	t.Routes = append(t.Routes, TopoRoute{Node: node, Dst: dst, Gw: gw})
+	return t
+}
+
+// Scheme expands the topo into the scheme, which is created by Plan or
+// Recover. Addresses are given out by the pool of the expansion, not by the
+// global one, and generated links have no MACs, kernel sets them. So the
+// same topo is always expanded into the same scheme.
+func (t *Topo) Scheme() (*Scheme, error) {
+	scheme := NewScheme()
+	nodes := make(map[string]Node)
+
+	for _, ts := range t.Switches {
+		if _, found := nodes[ts.Name]; found {
+			return nil, fmt.Errorf("Duplicate node %s", ts.Name)
+		}
+
+		sw := &Switch{Name: ts.Name, Controller: ts.Controller, STP: ts.STP, Ports: make(Links, 0)}
+		nodes[sw.Name] = sw
+		scheme.AddNode(sw)
+	}
+
+	for _, name := range t.Hosts {
+		if _, found := nodes[name]; found {
+			return nil, fmt.Errorf("Duplicate node %s", name)
+		}
+
+		h := &Host{Name: name, netns: &NetNs{name: name}, Links: make(Links, 0)}
+		nodes[h.Name] = h
+		scheme.AddNode(h)
+	}
+
+	// explicit addresses are never given out by the pool
+	a := &allocator{pool: pool.New(), reserved: make(map[string]bool)}
+
+	for _, tl := range t.Links {
+		for _, ep := range []TopoEndpoint{tl.Left, tl.Right} {
+			for _, cidr := range append([]string{ep.Cidr}, ep.Addrs...) {
+				if ip, _, err := net.ParseCIDR(cidr); err == nil {
+					a.reserved[ip.String()] = true
+				}
+			}
+		}
+	}
+
+	for _, tl := range t.Links {
+		if err := t.expandLink(nodes, tl, a); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, tr := range t.Routes {
+		h, found := scheme.GetHost(tr.Node)
+		if !found {
+			return nil, fmt.Errorf("Route of unknown host %s", tr.Node)
+		}
+
+		if err := addRoute(h, Route{Dst: tr.Dst, Gw: tr.Gw}); err != nil {
+			return nil, err
+		}
+	}
+
+	if t.ID != "" {
+		scheme.SetTopology(t.ID)
+	}
+
+	return scheme, nil
+}
+
+// allocator gives out addresses of the single expansion
+type allocator struct {
+	pool     pool.Pool
+	reserved map[string]bool
+}
+
+func (t *Topo) expandLink(nodes map[string]Node, tl TopoLink, a *allocator) error {
+	left, found := nodes[tl.Left.Node]
+	if !found {
+		return fmt.Errorf("Link to unknown node %s", tl.Left.Node)
+	}
+
+	right, found := nodes[tl.Right.Node]
+	if !found {
+		return fmt.Errorf("Link to unknown node %s", tl.Right.Node)
+	}
+
+	// switch is always on the left, its port is named after the host
+	if _, ok := right.(*Switch); ok {
+		left, right = right, left
+		tl.Left, tl.Right = tl.Right, tl.Left
+	}
+
+	_, patch := right.(*Switch)
+	refs := make([]Link, 0, 2)
+
+	for _, ep := range []struct {
+		n  Node
+		ep TopoEndpoint
+	}{{left, tl.Left}, {right, tl.Right}} {
+		l := Link{Name: ep.ep.IfName, Cidr: ep.ep.Cidr, Addrs: ep.ep.Addrs}
+
+		switch ep.n.(type) {
+		case *Switch:
+			if l.Cidr == "" && !patch {
+				l.Cidr = noip
+			}
+		case *Host:
+			if l.Name == "" {
+				l.Name = fmt.Sprintf("veth%d", ep.n.LinksCount())
+			}
+			if l.Cidr == "" {
+				cidr, err := t.nextCidr(tl.Net, a)
+				if err != nil {
+					return err
+				}
+				l.Cidr = cidr
+			}
+			if l.Cidr != noip && !l.HasIPv6() {
+				cidr, err := t.nextCidr6(tl.Net6, a)
+				if err != nil {
+					return err
+				}
+				if cidr != "" {
+					l.Addrs = append(l.Addrs, cidr)
+				}
+			}
+		}
+
+		refs = append(refs, l)
+	}
+
+	pr := NewLink(left, right, refs...)
+	pr.Left.HwAddr, pr.Right.HwAddr = "", ""
+
+	for _, l := range []Link{pr.Left, pr.Right} {
+		switch n := nodes[l.NodeName].(type) {
+		case *Switch:
+			if _, found := n.Ports.ByName(l.Name); found {
+				return fmt.Errorf("Duplicate port %s of %s", l.Name, n.Name)
+			}
+			n.Ports = append(n.Ports, l)
+		case *Host:
+			if _, found := n.Links.ByName(l.Name); found {
+				return fmt.Errorf("Duplicate link %s of %s", l.Name, n.Name)
+			}
+			n.Links = append(n.Links, l)
+		}
+	}
+
+	return nil
+}
+
+func (t *Topo) nextCidr(network string, a *allocator) (string, error) {
+	if network == "" {
+		network = t.Pool
+	}
+
+	if network == "" {
+		network = pool.ThePool().Network()
+	}
+
+	if network == "" {
+		return "", fmt.Errorf("No network for the link address, set pool or net")
+	}
+
+	return a.next(network)
+}
+
+// nextCidr6 returns IPv6 address of the network, Pool6 or the pool's
+// IPv6 network, empty one if neither is set
+func (t *Topo) nextCidr6(network string, a *allocator) (string, error) {
+	if network == "" {
+		network = t.Pool6
+	}
+
+	if network == "" {
+		network = pool.ThePool().Network6()
+	}
+
+	if network == "" {
+		return "", nil
+	}
+
+	return a.next(network)
+}
+
+// next returns the next address of the network, which isn't reserved
+func (a *allocator) next(network string) (string, error) {
+	if _, _, err := net.ParseCIDR(network); err != nil {
+		return "", err
+	}
+
+	for {
+		cidr := a.pool.NextCidr(network)
+
+		ip, ipnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return "", err
+		}
+
+		// pool starts from the network address again, when it's exhausted
+		if ip.Equal(ipnet.IP) || (ip.To4() != nil && ip.Equal(broadcast(ipnet))) {
+			return "", fmt.Errorf("Network %s is exhausted", ipnet)
+		}
+
+		if !a.reserved[ip.String()] {
+			a.reserved[ip.String()] = true
+			return cidr, nil
+		}
+	}
+}
+
+// checkNetwork6 checks that the network is IPv6 CIDR, not an address
+func checkNetwork6(network string) error {
+	ip, _, err := net.ParseCIDR(network)
+	if err != nil {
+		return err
+	}
+
+	if ip.To4() != nil {
+		return fmt.Errorf("wrong IPv6 network %s", network)
+	}
+
+	return nil
+}
+
+// defaultDst is the default route destination of the gateway's IP version
+func defaultDst(gw string) string {
+	if isIPv6(gw) {
+		return "::/0"
+	}
+
+	return "0.0.0.0/0"
+}
+
+func broadcast(ipnet *net.IPNet) net.IP {
+	ip := make(net.IP, len(ipnet.IP))
+
+	for i := range ipnet.IP {
+		ip[i] = ipnet.IP[i] | ^ipnet.Mask[i]
+	}
+
+	return ip
+}
+
+// addRoute adds route to the host's link, which network contains the gateway
+func addRoute(h *Host, r Route) error {
+	gw := net.ParseIP(r.Gw)
+
+	for i, l := range h.Links {
+		for _, cidr := range l.Cidrs() {
+			if _, ipnet, err := net.ParseCIDR(cidr); err == nil && ipnet.Contains(gw) {
+				h.Links[i].Routes = append(h.Links[i].Routes, r)
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("Gateway %s isn't reachable from %s", r.Gw, h.Name)
+}
+
+// expandNames expands ranges in names, e.g. h[1-3] is h1 h2 h3
+func expandNames(names ...string) ([]string, error) {
+	result := make([]string, 0, len(names))
+
+	for _, name := range names {
+		m := rangeRe.FindStringSubmatch(name)
+		if m == nil {
+			result = append(result, name)
+			continue
+		}
+
+		from, _ := strconv.Atoi(m[2])
+		to, _ := strconv.Atoi(m[3])
+
+		if from > to {
+			return nil, fmt.Errorf("wrong range %s", name)
+		}
+
+		for i := from; i <= to; i++ {
+			result = append(result, fmt.Sprintf("%s%d%s", m[1], i, m[4]))
+		}
+	}
+
+	return result, nil
+}
+
+// parseEndpoints parses node[:ifname][=cidr[,cidr...]], node may be a range
+func parseEndpoints(s string) ([]TopoEndpoint, error) {
+	ep := TopoEndpoint{}
+
+	if i := strings.Index(s, "="); i >= 0 {
+		var addrs []string
+		s, addrs = s[:i], strings.Split(s[i+1:], ",")
+
+		for _, addr := range addrs {
+			if _, _, err := net.ParseCIDR(addr); err != nil && (addr != noip || len(addrs) > 1) {
+				return nil, fmt.Errorf("wrong address %s", addr)
+			}
+		}
+
+		ep.Cidr, ep.Addrs = addrs[0], addrs[1:]
+	}
+
+	if i := strings.Index(s, ":"); i >= 0 {
+		s, ep.IfName = s[:i], s[i+1:]
+	}
+
+	names, err := expandNames(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(names) > 1 && ep.Cidr != "" && ep.Cidr != noip {
+		return nil, fmt.Errorf("address %s is set to the range %s", ep.Cidr, s)
+	}
+
+	result := make([]TopoEndpoint, 0, len(names))
+
+	for _, name := range names {
+		result = append(result, TopoEndpoint{Node: name, IfName: ep.IfName, Cidr: ep.Cidr, Addrs: ep.Addrs})
+	}
+
+	return result, nil
+}
@@ -0,0 +1,225 @@
+package mn
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+var labTopo = `
+# two networks behind the router
+topology lab
+
+switch s1 s2 controller=tcp:127.0.0.1:6633
+host h[1-4]
+router r1
+
+link s1 h[1-2] net=10.77.1.0/24
+link s2 h[3-4] net=10.77.2.0/24
+link r1=10.77.1.1/24 s1
+link r1:wan0=10.77.2.254/24 s2
+link s1 s2
+
+route h[1-2] default via 10.77.1.1
+route h[3-4] default via 10.77.2.254
+`
+
+func TestTopo(t *testing.T) {
+	topo, err := ParseTopo([]byte(labTopo))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scheme, err := topo.Scheme()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if scheme.Topology != "lab" || len(scheme.Switches) != 2 || len(scheme.Hosts) != 5 {
+		t.Fatalf("Unexpected scheme: %s", scheme)
+	}
+
+	expected := map[string]string{
+		"h1": "veth0 10.77.1.2/24 0.0.0.0/0 via 10.77.1.1",
+		"h2": "veth0 10.77.1.3/24 0.0.0.0/0 via 10.77.1.1",
+		"h3": "veth0 10.77.2.1/24 0.0.0.0/0 via 10.77.2.254",
+		"h4": "veth0 10.77.2.2/24 0.0.0.0/0 via 10.77.2.254",
+	}
+
+	for name, exp := range expected {
+		h, _ := scheme.GetHost(name)
+		l := h.Links[0]
+
+		if obtained := l.Name + " " + l.Cidr + " " + l.Routes[0].Dst + " via " + l.Routes[0].Gw; obtained != exp {
+			t.Fatalf("%s\nExpected: %s\nObtained: %s", name, exp, obtained)
+		}
+	}
+
+	r1, _ := scheme.GetHost("r1")
+	if len(r1.Links) != 2 || r1.Links[0].Name != "veth0" || r1.Links[1].Name != "wan0" {
+		t.Fatalf("Unexpected r1 links: %v", r1.Links)
+	}
+
+	// every link is mirrored by its peer
+	for _, sw := range scheme.Switches {
+		if sw.Controller != "tcp:127.0.0.1:6633" {
+			t.Fatalf("Unexpected controller of %s: %s", sw.Name, sw.Controller)
+		}
+
+		for _, port := range sw.Ports {
+			peer, found := scheme.GetNode(port.Peer.NodeName)
+			if !found {
+				t.Fatalf("Unknown peer of %s: %v", port.Name, port.Peer)
+			}
+
+			l := peer.GetLinks().LinkByPeer(Peer{NodeName: port.Peer.NodeName, IfName: port.Peer.IfName})
+			if l.Peer.NodeName != sw.Name || l.Peer.IfName != port.Name {
+				t.Fatalf("Port %s of %s isn't mirrored by %v", port.Name, sw.Name, l)
+			}
+		}
+	}
+
+	s1, _ := scheme.GetSwitch("s1")
+	if names := s1.Ports[0].Name + " " + s1.Ports[3].Name; names != "h1-eth0 s1-pp3" {
+		t.Fatalf("\nExpected: h1-eth0 s1-pp3\nObtained: %s", names)
+	}
+
+	// expanding has no side effects, everything is created by plan
+	fake := NewRecordingExecutor().
+		On("ovs-vsctl br-exists", "", errors.New("exit status 2")).
+		On("ip link show", "", errors.New("exit status 1"))
+
+	plan, err := scheme.SetExecutor(fake).Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ops := plan.String()
+
+	for _, exp := range []string{
+		"create  bridge      s1",
+		"create  netns       r1",
+		"enable  forwarding  r1",
+		"add     route       h4  0.0.0.0/0 via 10.77.2.254 dev veth0@h4",
+	} {
+		if !strings.Contains(ops, exp) {
+			t.Fatalf("Expected %q in plan:\n%s", exp, ops)
+		}
+	}
+
+	// the same topo is always expanded into the same scheme, MACs are set by kernel
+	again, err := topo.Scheme()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err = scheme.SetExecutor(NewRecordingExecutor()).Diff(again)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, op := range plan {
+		if op.Action == "delete" || op.Kind == "addr" || op.Kind == "mac" || s1.Ports[0].HwAddr != "" {
+			t.Fatalf("Expected no changes, obtained:\n%s", plan)
+		}
+	}
+
+	// switches of loops run STP
+	topo, err = ParseTopo([]byte("switch s[1-2] stp controller=tcp:127.0.0.1:6633"))
+	if err != nil || len(topo.Switches) != 2 || !topo.Switches[1].STP || topo.Switches[1].Controller == "" {
+		t.Fatalf("Unexpected switches: %v, error: %v", topo.Switches, err)
+	}
+}
+
+func TestTopoErrors(t *testing.T) {
+	for data, exp := range map[string]string{
+		"host h1\nfoo h1": "Line 2: unknown directive foo",
+		"host h[1-2]\nhost g[1-3]\nlink h[1-2] g[1-3]": "Line 3: ranges h[1-2] and g[1-3] have different sizes",
+		"link s1 h1=10.0.0.300/24":                     "Line 1: wrong address 10.0.0.300/24",
+		"route h1 default 10.0.0.1":                    "Line 1: expected: route {node} {dst|default} via {gw}",
+		"pool 10.0.0.0/24\npool6 fd00::1\nlink h1 h2":  "Line 2: invalid CIDR address: fd00::1",
+		"pool6 10.0.0.0/24":                            "Line 1: wrong IPv6 network 10.0.0.0/24",
+		"link h1 h2 net6=fd00::1":                      "Line 1: invalid CIDR address: fd00::1",
+	} {
+		if _, err := ParseTopo([]byte(data)); err == nil || err.Error() != exp {
+			t.Fatalf("\nExpected: %s\nObtained: %v", exp, err)
+		}
+	}
+
+	for data, exp := range map[string]string{
+		"switch s1\nlink s1 h1": "Link to unknown node h1",
+		"host h1\nswitch h1":    "Duplicate node h1",
+		"switch s1\nhost h1\nlink s1 h1 net=10.78.0.0/24\nroute h1 default via 10.79.0.1": "Gateway 10.79.0.1 isn't reachable from h1",
+		"switch s1\nhost h[1-3]\nlink s1 h[1-3] net=10.80.0.0/30":                         "Network 10.80.0.0/30 is exhausted",
+	} {
+		topo, err := ParseTopo([]byte(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := topo.Scheme(); err == nil || !strings.HasPrefix(err.Error(), exp) {
+			t.Fatalf("\nExpected: %s\nObtained: %v", exp, err)
+		}
+	}
+
+	// topo, which isn't parsed, has the same checks on expanding
+	topo := NewTopo().AddHost("h1", "h2").AddLink("h1", "h2")
+	topo.Pool, topo.Pool6 = "10.81.0.0/24", "fd00::1"
+
+	if _, err := topo.Scheme(); err == nil {
+		t.Fatal("Expected error of IPv6 address instead of network")
+	}
+}
+
+func TestTopoIPv6(t *testing.T) {
+	topo, err := ParseTopo([]byte(`
+pool6 fd00:77::/64
+switch s1
+host h[1-2]
+router r1
+link s1 h[1-2] net=10.77.3.0/24
+link r1=10.77.3.254/24,fd00:77::fe/64 s1
+link r1:wan0=fd00:78::1/64 h2:wan0=fd00:78::2/64
+link s1 r1:lan1 net=10.77.4.0/24 net6=fd00:79::/64
+route h1 default via 10.77.3.254
+route h1 default via fd00:77::fe
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scheme, err := topo.Scheme()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h1, _ := scheme.GetHost("h1")
+	h2, _ := scheme.GetHost("h2")
+	r1, _ := scheme.GetHost("r1")
+
+	if l := h1.Links[0]; l.Cidr != "10.77.3.1/24" || len(l.Addrs) != 1 || l.Addrs[0] != "fd00:77::1/64" {
+		t.Fatalf("Unexpected link of h1: %v", l)
+	}
+
+	expected := []Route{{Dst: "0.0.0.0/0", Gw: "10.77.3.254"}, {Dst: "::/0", Gw: "fd00:77::fe"}}
+	if routes := h1.Links[0].Routes; len(routes) != 2 || routes[0] != expected[0] || routes[1] != expected[1] {
+		t.Fatalf("\nExpected: %v\nObtained: %v", expected, routes)
+	}
+
+	if l := r1.Links[0]; len(l.Addrs) != 1 || l.Addrs[0] != "fd00:77::fe/64" {
+		t.Fatalf("Unexpected link of r1: %v", l)
+	}
+
+	if l := r1.Links[2]; l.Cidr != "10.77.4.1/24" || len(l.Addrs) != 1 || l.Addrs[0] != "fd00:79::1/64" {
+		t.Fatalf("Unexpected link of r1: %v", l)
+	}
+
+	// IPv6 only link
+	if l := h2.Links[1]; l.Cidr != "fd00:78::2/64" || len(l.Addrs) != 0 {
+		t.Fatalf("Unexpected link of h2: %v", l)
+	}
+
+	if err := scheme.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -5,8 +5,17 @@ import (
 	"log"
 	"net"
 	"strings"
+	"testing"
 )
 
+// requireSystem skips the test in short mode,
+// such tests need root, openvswitch and iproute2
+func requireSystem(t *testing.T) {
+	if testing.Short() {
+		t.Skip("system test, skipped in short mode")
+	}
+}
+
 func ifaceNotExists(ifname string, netns string) bool {
 	var out string
 	var err error
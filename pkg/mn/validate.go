@@ -0,0 +1,324 @@
+package mn
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ifNameSize is IFNAMSIZ, interface name length including terminating zero
+const ifNameSize = 16
+
+// cgroupControllers are known cgroup v1 controllers
+var cgroupControllers = map[string]bool{
+	"blkio":      true,
+	"cpu":        true,
+	"cpuacct":    true,
+	"cpuset":     true,
+	"devices":    true,
+	"freezer":    true,
+	"hugetlb":    true,
+	"memory":     true,
+	"net_cls":    true,
+	"net_prio":   true,
+	"perf_event": true,
+	"pids":       true,
+	"rdma":       true,
+}
+
+// Problem is a single problem of the scheme, Path is a JSON path
+// of the wrong field, e.g. $.Hosts[0].Links[1].Peer.NodeName
+type Problem struct {
+	Path    string
+	Message string
+}
+
+// String satisfies stringer interface
+func (p Problem) String() string {
+	return p.Path + ": " + p.Message
+}
+
+// ValidationError contains all problems of the scheme
+type ValidationError []Problem
+
+// Error satisfies error interface, a problem per line
+func (e ValidationError) Error() string {
+	lines := make([]string, 0, len(e))
+
+	for _, p := range e {
+		lines = append(lines, p.String())
+	}
+
+	return "Invalid scheme:\n" + strings.Join(lines, "\n")
+}
+
+// validator collects problems of the scheme
+type validator struct {
+	scheme   Scheme
+	problems ValidationError
+	// first path of the node, interface in netns and address
+	nodes map[string]string
+	names map[string]string
+	addrs map[string]string
+	// networks of netns
+	nets map[string][]netPath
+}
+
+type netPath struct {
+	ipnet *net.IPNet
+	path  string
+}
+
+// Validate checks the scheme before anything is created: peers symmetry,
+// interface names, addresses, routes' gateways, MACs, link states and cgroup
+// controllers.
+// All problems are returned as ValidationError.
+func (s Scheme) Validate() error {
+	v := &validator{
+		scheme: s,
+		nodes:  make(map[string]string),
+		names:  make(map[string]string),
+		addrs:  make(map[string]string),
+		nets:   make(map[string][]netPath),
+	}
+
+	for i, sw := range s.Switches {
+		path := fmt.Sprintf("$.Switches[%d]", i)
+
+		v.node(path, sw.Name)
+
+		for j, port := range sw.Ports {
+			v.link(fmt.Sprintf("%s.Ports[%d]", path, j), sw.Name, port)
+		}
+	}
+
+	for i, h := range s.Hosts {
+		path := fmt.Sprintf("$.Hosts[%d]", i)
+
+		v.node(path, h.Name)
+
+		for j, l := range h.Links {
+			v.link(fmt.Sprintf("%s.Links[%d]", path, j), h.Name, l)
+		}
+
+		v.cgroup(path+".Cgroup", h.Cgroup)
+	}
+
+	// all networks are known, so gateways are checked in the second pass
+	for i, h := range s.Hosts {
+		for j, l := range h.Links {
+			v.routes(fmt.Sprintf("$.Hosts[%d].Links[%d]", i, j), l)
+		}
+	}
+
+	if len(v.problems) == 0 {
+		return nil
+	}
+
+	return v.problems
+}
+
+func (v *validator) add(path, format string, args ...interface{}) {
+	v.problems = append(v.problems, Problem{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) node(path, name string) {
+	if name == "" {
+		v.add(path+".Name", "node name is empty")
+		return
+	}
+
+	if first, found := v.nodes[name]; found {
+		v.add(path+".Name", "duplicate node name %s, first defined at %s", name, first)
+		return
+	}
+
+	v.nodes[name] = path + ".Name"
+}
+
+func (v *validator) link(path, node string, l Link) {
+	netns := linkNetNs(l)
+
+	switch {
+	case l.Name == "":
+		v.add(path+".Name", "interface name is empty")
+	case len(l.Name) >= ifNameSize:
+		v.add(path+".Name", "interface name %s is longer than %d characters", l.Name, ifNameSize-1)
+	}
+
+	if l.Name != "" {
+		key := netns + "/" + l.Name
+
+		if first, found := v.names[key]; found {
+			v.add(path+".Name", "duplicate interface name %s in netns %s, first defined at %s", l.Name, netnsOrRoot(netns), first)
+		} else {
+			v.names[key] = path + ".Name"
+		}
+	}
+
+	switch l.NodeName {
+	case "":
+		v.add(path+".NodeName", "node name of the link is empty")
+	case node:
+	default:
+		v.add(path+".NodeName", "link of %s belongs to %s", node, l.NodeName)
+	}
+
+	if l.HwAddr != "" {
+		if hw, err := net.ParseMAC(l.HwAddr); err != nil || len(hw) != 6 {
+			v.add(path+".HwAddr", "invalid MAC address %s", l.HwAddr)
+		} else if hw[0]&1 != 0 {
+			v.add(path+".HwAddr", "multicast MAC address %s", l.HwAddr)
+		}
+	}
+
+	switch l.State {
+	case "", "UP":
+	case "DOWN":
+		_, left := v.scheme.GetSwitch(node)
+		_, right := v.scheme.GetSwitch(l.Peer.NodeName)
+
+		if left && right {
+			v.add(path+".State", "patch port %s can't be DOWN", l.Name)
+		}
+	default:
+		v.add(path+".State", "unknown link state %s, expected UP or DOWN", l.State)
+	}
+
+	v.cidr(path, node, netns, l)
+	v.peer(path, node, l)
+
+	if l.Impairment != nil {
+		for _, problem := range l.Impairment.problems() {
+			v.add(path+".Impairment", "%s", problem)
+		}
+	}
+}
+
+func (v *validator) cidr(path, node, netns string, l Link) {
+	// every switch port gets an address of its link from the pool, they
+	// share the root netns, so their networks overlap
+	_, port := v.scheme.GetSwitch(node)
+	overlap := !(port && netns == "")
+
+	if l.Cidr != "" && l.Cidr != noip {
+		v.address(path+".Cidr", netns, l.Cidr, overlap)
+	}
+
+	for i, addr := range l.Addrs {
+		v.address(fmt.Sprintf("%s.Addrs[%d]", path, i), netns, addr, overlap)
+	}
+}
+
+// address checks that the address is unique, and that its network doesn't
+// overlap with other networks of the netns, if overlap is set
+func (v *validator) address(path, netns, cidr string, overlap bool) {
+	ip, ipnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		v.add(path, "invalid CIDR %s", cidr)
+		return
+	}
+
+	if first, found := v.addrs[ip.String()]; found {
+		v.add(path, "duplicate address %s, first used at %s", ip, first)
+	} else {
+		v.addrs[ip.String()] = path
+	}
+
+	if !overlap {
+		return
+	}
+
+	for _, n := range v.nets[netns] {
+		if n.ipnet.Contains(ipnet.IP) || ipnet.Contains(n.ipnet.IP) {
+			v.add(path, "network %s overlaps with %s at %s in netns %s", ipnet, n.ipnet, n.path, netnsOrRoot(netns))
+			break
+		}
+	}
+
+	v.nets[netns] = append(v.nets[netns], netPath{ipnet: ipnet, path: path})
+}
+
+// peer checks that the peer exists and points back to the link
+func (v *validator) peer(path, node string, l Link) {
+	if l.Peer.NodeName == "" {
+		v.add(path+".Peer.NodeName", "peer node is empty")
+		return
+	}
+
+	peer, found := v.scheme.GetNode(l.Peer.NodeName)
+	if !found {
+		v.add(path+".Peer.NodeName", "unknown node %s", l.Peer.NodeName)
+		return
+	}
+
+	pl := peer.GetLinks().LinkByPeer(l.Peer)
+	if pl.NodeName == "" {
+		v.add(path+".Peer.IfName", "node %s has no link %s", l.Peer.NodeName, l.Peer.IfName)
+		return
+	}
+
+	if pl.Peer.NodeName != node || pl.Peer.IfName != l.Name {
+		v.add(path+".Peer", "peer %s of %s points to %s of %s instead", l.Peer.IfName, l.Peer.NodeName, pl.Peer.IfName, pl.Peer.NodeName)
+	}
+}
+
+func (v *validator) routes(path string, l Link) {
+	for i, r := range l.Routes {
+		rpath := fmt.Sprintf("%s.Routes[%d]", path, i)
+
+		if _, _, err := net.ParseCIDR(r.Dst); err != nil {
+			v.add(rpath+".Dst", "invalid destination %s", r.Dst)
+		} else if isIPv6(r.Dst) != isIPv6(r.Gw) {
+			v.add(rpath+".Gw", "gateway %s and destination %s are of different IP versions", r.Gw, r.Dst)
+		}
+
+		gw := net.ParseIP(r.Gw)
+		if gw == nil {
+			v.add(rpath+".Gw", "invalid gateway %s", r.Gw)
+			continue
+		}
+
+		// IPv6 link-local gateway is on the link
+		reachable := gw.To4() == nil && gw.IsLinkLocalUnicast()
+
+		for _, n := range v.nets[linkNetNs(l)] {
+			if n.ipnet.Contains(gw) {
+				reachable = true
+				break
+			}
+		}
+
+		if !reachable {
+			v.add(rpath+".Gw", "gateway %s isn't reachable from netns %s", r.Gw, netnsOrRoot(linkNetNs(l)))
+		}
+	}
+}
+
+func (v *validator) cgroup(path string, c *Cgroup) {
+	if c == nil {
+		return
+	}
+
+	for i, ctrl := range c.Controllers {
+		if !cgroupControllers[ctrl.Name] {
+			v.add(fmt.Sprintf("%s.Controllers[%d].Name", path, i), "unknown cgroup controller %s", ctrl.Name)
+		}
+	}
+}
+
+func linkNetNs(l Link) string {
+	if l.NetNs == "root" {
+		return ""
+	}
+
+	return l.NetNs
+}
+
+func netnsOrRoot(netns string) string {
+	if netns == "" {
+		return "root"
+	}
+
+	return netns
+}
@@ -0,0 +1,116 @@
+package mn
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	fake := NewRecordingExecutor()
+
+	if err := newFakeScheme(fake).Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	scheme := newFakeScheme(fake)
+
+	s1, _ := scheme.GetSwitch("s1")
+	s1.Ports[0].Peer.NodeName = "h3"
+	s1.Ports = append(s1.Ports, Link{Name: "h1-eth0-very-long", NodeName: "s1", HwAddr: "01:00:5e:00:00:01", Peer: Peer{NodeName: "h2", IfName: "eth0"}})
+
+	h1, _ := scheme.GetHost("h1")
+	h1.Links[1].HwAddr = "zz:00:00:00:00:01"
+	h1.Links[1].Addrs = []string{"fd00:1::1/64"}
+	h1.Links[1].State = "OFF"
+	h1.Links = append(h1.Links, Link{Name: "veth0", NodeName: "h1", NetNs: "h1", Cidr: "10.1.0.2/16", Peer: Peer{NodeName: "s1", IfName: "h1-eth0"}})
+
+	h2, _ := scheme.GetHost("h2")
+	h2.Links[0].Routes = append(h2.Links[0].Routes, Route{Dst: "0.0.0.0/0", Gw: "10.2.0.1"}, Route{Dst: "::/0", Gw: "fe80::1"}, Route{Dst: "::/0", Gw: "10.1.0.1"})
+	h2.Links[0].Addrs = []string{"fd00:1::1/64", "fd00:2::1"}
+	h2.Cgroup = &Cgroup{Name: "h2", Controllers: []Controller{{Name: "cpu"}, {Name: "cpus"}}}
+
+	err := scheme.Validate()
+	if err == nil {
+		t.Fatal("Expected validation error")
+	}
+
+	expected := []string{
+		"$.Switches[0].Ports[0].Peer.NodeName: unknown node h3",
+		"$.Switches[0].Ports[1].Name: interface name h1-eth0-very-long is longer than 15 characters",
+		"$.Switches[0].Ports[1].HwAddr: multicast MAC address 01:00:5e:00:00:01",
+		"$.Switches[0].Ports[1].Peer: peer eth0 of h2 points to eth1 of h1 instead",
+		"$.Hosts[0].Links[0].Peer: peer h1-eth0 of s1 points to veth0 of h3 instead",
+		"$.Hosts[0].Links[1].HwAddr: invalid MAC address zz:00:00:00:00:01",
+		"$.Hosts[0].Links[1].State: unknown link state OFF, expected UP or DOWN",
+		"$.Hosts[0].Links[2].Name: duplicate interface name veth0 in netns h1, first defined at $.Hosts[0].Links[0].Name",
+		"$.Hosts[0].Links[2].Cidr: network 10.1.0.0/16 overlaps with 10.1.0.0/24 at $.Hosts[0].Links[1].Cidr in netns h1",
+		"$.Hosts[0].Links[2].Peer: peer h1-eth0 of s1 points to veth0 of h3 instead",
+		"$.Hosts[1].Links[0].Cidr: duplicate address 10.1.0.2, first used at $.Hosts[0].Links[2].Cidr",
+		"$.Hosts[1].Links[0].Addrs[0]: duplicate address fd00:1::1, first used at $.Hosts[0].Links[1].Addrs[0]",
+		"$.Hosts[1].Links[0].Addrs[1]: invalid CIDR fd00:2::1",
+		"$.Hosts[1].Cgroup.Controllers[1].Name: unknown cgroup controller cpus",
+		"$.Hosts[1].Links[0].Routes[1].Gw: gateway 10.2.0.1 isn't reachable from netns h2",
+		"$.Hosts[1].Links[0].Routes[3].Gw: gateway 10.1.0.1 and destination ::/0 are of different IP versions",
+	}
+
+	problems := err.(ValidationError)
+
+	if len(problems) != len(expected) {
+		t.Fatalf("\nExpected:\n%v\nObtained:\n%v", expected, err)
+	}
+
+	for i := range expected {
+		if problems[i].String() != expected[i] {
+			t.Fatalf("\nExpected: %s\nObtained: %s", expected[i], problems[i])
+		}
+	}
+
+	// invalid scheme isn't planned
+	if _, err := scheme.Plan(); err == nil {
+		t.Fatal("Expected plan to fail")
+	}
+}
+
+func TestValidateSwitchPorts(t *testing.T) {
+	fake := NewRecordingExecutor().
+		On("ovs-vsctl br-exists", "", errors.New("exit status 2")).
+		On("ip link show", "", errors.New("exit status 1"))
+
+	saved := DefaultExecutor
+	DefaultExecutor = fake
+	defer func() { DefaultExecutor = saved }()
+
+	s1, err := NewSwitch("s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scheme := NewScheme().AddNode(s1)
+
+	// switch ports get addresses of the shared pool, like hosts do,
+	// their networks overlap in root netns
+	for i, name := range []string{"h1", "h2"} {
+		h, err := NewHost(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pair := NewLink(s1, h,
+			Link{Cidr: fmt.Sprintf("192.168.55.%d/24", 2*i+1)},
+			Link{Cidr: fmt.Sprintf("192.168.55.%d/24", 2*i+2)},
+		)
+		s1.AddLink(pair.Left)
+		h.AddLink(pair.Right)
+
+		scheme.AddNode(h)
+	}
+
+	if err := scheme.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := scheme.Plan(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,180 @@
+package mn
+
+// Views are stable representations of the scheme's objects for machine-readable
+// output. Unlike the scheme's JSON they don't depend on internal structures,
+// fields are only added, never renamed or removed.
+
+// SchemeView is a view of the whole scheme
+type SchemeView struct {
+	Topology string       `json:"topology" yaml:"topology"`
+	Switches []SwitchView `json:"switches" yaml:"switches"`
+	Hosts    []HostView   `json:"hosts" yaml:"hosts"`
+}
+
+// SwitchView is a view of the switch
+type SwitchView struct {
+	Name       string     `json:"name" yaml:"name"`
+	Controller string     `json:"controller" yaml:"controller"`
+	STP        bool       `json:"stp,omitempty" yaml:"stp,omitempty"`
+	Ports      []LinkView `json:"ports" yaml:"ports"`
+}
+
+// HostView is a view of the host
+type HostView struct {
+	Name   string        `json:"name" yaml:"name"`
+	NetNs  string        `json:"netns" yaml:"netns"`
+	Cgroup string        `json:"cgroup" yaml:"cgroup"`
+	Links  []LinkView    `json:"links" yaml:"links"`
+	Procs  []ProcessView `json:"procs" yaml:"procs"`
+}
+
+// LinkView is a view of the host's link or the switch's port
+type LinkView struct {
+	Name     string      `json:"name" yaml:"name"`
+	Node     string      `json:"node" yaml:"node"`
+	NetNs    string      `json:"netns" yaml:"netns"`
+	Cidr     string      `json:"cidr" yaml:"cidr"`
+	Addrs    []string    `json:"addrs,omitempty" yaml:"addrs,omitempty"`
+	HwAddr   string      `json:"mac" yaml:"mac"`
+	State    string      `json:"state" yaml:"state"`
+	Patch    bool        `json:"patch" yaml:"patch"`
+	PeerNode string      `json:"peer_node" yaml:"peer_node"`
+	PeerLink string      `json:"peer_link" yaml:"peer_link"`
+	Routes   []RouteView `json:"routes" yaml:"routes"`
+	Impaired string      `json:"impairment,omitempty" yaml:"impairment,omitempty"`
+}
+
+// RouteView is a view of the route
+type RouteView struct {
+	Dst string `json:"dst" yaml:"dst"`
+	Gw  string `json:"gw" yaml:"gw"`
+}
+
+// ProcessView is a view of the host's process, Pid is 0 if it isn't running
+type ProcessView struct {
+	Pid     int      `json:"pid" yaml:"pid"`
+	Running bool     `json:"running" yaml:"running"`
+	Command string   `json:"command" yaml:"command"`
+	Args    []string `json:"args" yaml:"args"`
+	Output  string   `json:"output" yaml:"output"`
+}
+
+// View returns view of the scheme
+func (s *Scheme) View() SchemeView {
+	return SchemeView{
+		Topology: topologyOrDefault(s.Topology),
+		Switches: s.SwitchViews(),
+		Hosts:    s.HostViews(),
+	}
+}
+
+// SwitchViews returns views of all switches
+func (s *Scheme) SwitchViews() []SwitchView {
+	result := make([]SwitchView, 0, len(s.Switches))
+
+	for _, sw := range s.Switches {
+		v := sw.View()
+
+		for i := range v.Ports {
+			if _, found := s.GetSwitch(v.Ports[i].PeerNode); found {
+				v.Ports[i].Patch = true
+			}
+		}
+
+		result = append(result, v)
+	}
+
+	return result
+}
+
+// HostViews returns views of all hosts
+func (s *Scheme) HostViews() []HostView {
+	result := make([]HostView, 0, len(s.Hosts))
+
+	for _, h := range s.Hosts {
+		result = append(result, h.View())
+	}
+
+	return result
+}
+
+// View returns view of the switch
+func (s Switch) View() SwitchView {
+	v := SwitchView{Name: s.Name, Controller: s.Controller, STP: s.STP, Ports: make([]LinkView, 0, len(s.Ports))}
+
+	for _, port := range s.Ports {
+		v.Ports = append(v.Ports, port.View())
+	}
+
+	return v
+}
+
+// View returns view of the host
+func (h Host) View() HostView {
+	v := HostView{
+		Name:  h.Name,
+		Links: make([]LinkView, 0, len(h.Links)),
+		Procs: make([]ProcessView, 0, len(h.Procs)),
+	}
+
+	if h.NetNs() != nil {
+		v.NetNs = h.NetNs().Name()
+	}
+
+	if h.Cgroup != nil {
+		v.Cgroup = h.Cgroup.Name
+	}
+
+	for _, l := range h.Links {
+		v.Links = append(v.Links, l.View())
+	}
+
+	for _, p := range h.Procs {
+		v.Procs = append(v.Procs, p.View())
+	}
+
+	return v
+}
+
+// View returns view of the link
+func (l Link) View() LinkView {
+	v := LinkView{
+		Name:     l.Name,
+		Node:     l.NodeName,
+		NetNs:    l.NetNs,
+		Cidr:     l.Cidr,
+		Addrs:    l.Addrs,
+		HwAddr:   l.HwAddr,
+		State:    l.State,
+		Patch:    l.patch,
+		PeerNode: l.Peer.NodeName,
+		PeerLink: l.Peer.IfName,
+		Routes:   make([]RouteView, 0, len(l.Routes)),
+	}
+
+	if l.impaired() {
+		v.Impaired = l.Impairment.String()
+	}
+
+	for _, r := range l.Routes {
+		v.Routes = append(v.Routes, RouteView{Dst: r.Dst, Gw: r.Gw})
+	}
+
+	return v
+}
+
+// View returns view of the process
+func (p Process) View() ProcessView {
+	args := p.Args
+	if args == nil {
+		args = make([]string, 0)
+	}
+
+	return ProcessView{
+		Pid:     p.GetPid(),
+		Running: p.GetPid() != 0,
+		Command: p.Command,
+		Args:    args,
+		Output:  p.Output,
+	}
+}
@@ -0,0 +1,51 @@
+package mn
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestView(t *testing.T) {
+	scheme := newFakeScheme(NewRecordingExecutor())
+
+	h2, _ := scheme.GetHost("h2")
+	h2.Procs = append(h2.Procs, &Process{Command: "ping", Args: []string{"-c1", "10.0.0.1"}, Output: "/tmp/output.1"})
+
+	view := scheme.View()
+
+	if view.Topology != DefaultTopology {
+		t.Fatal("Expected default topology, obtained:", view.Topology)
+	}
+
+	if len(view.Switches) != 1 || len(view.Switches[0].Ports) != 1 || len(view.Hosts) != 2 {
+		t.Fatal("Unexpected view:", view)
+	}
+
+	// views are published schemas, field names must not be changed
+	out, err := json.Marshal(view.Hosts[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"name":"h2","netns":"h2","cgroup":"",` +
+		`"links":[{"name":"eth0","node":"h2","netns":"h2","cidr":"10.1.0.2/24","mac":"02:00:00:00:02:02","state":"","patch":false,` +
+		`"peer_node":"h1","peer_link":"eth1","routes":[{"dst":"10.0.0.0/24","gw":"10.1.0.1"}]}],` +
+		`"procs":[{"pid":0,"running":false,"command":"ping","args":["-c1","10.0.0.1"],"output":"/tmp/output.1"}]}`
+
+	if string(out) != expected {
+		t.Fatalf("\nExpected:\n%s\nObtained:\n%s", expected, out)
+	}
+
+	out, err = json.Marshal(view.Switches[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = `{"name":"s1","controller":"",` +
+		`"ports":[{"name":"h1-eth0","node":"s1","netns":"","cidr":"noip","mac":"02:00:00:00:01:01","state":"","patch":false,` +
+		`"peer_node":"h1","peer_link":"veth0","routes":[]}]}`
+
+	if string(out) != expected {
+		t.Fatalf("\nExpected:\n%s\nObtained:\n%s", expected, out)
+	}
+}
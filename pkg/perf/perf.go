@@ -0,0 +1,572 @@
+// Package perf is a small iperf-like traffic generator and sink. Server
+// listens TCP and UDP on the same port, client sends traffic for the
+// duration and gets back what the server has received.
+package perf
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultPort is a port of the server
+const DefaultPort = 5201
+
+// Defaults of the client options
+const (
+	DefaultDuration = 10 * time.Second
+	DefaultRate     = 1000000
+	DefaultTCPLen   = 128 * 1024
+	DefaultUDPLen   = 1400
+)
+
+// IdleTimeout is how long the server waits for data of the client and the
+// client waits for the result, e.g. if the link has gone down during the test
+var IdleTimeout = 10 * time.Second
+
+// Options of the client, zero values are defaults. Rate is bits per
+// second, it limits UDP only, TCP is sent as fast as possible.
+type Options struct {
+	Protocol string
+	Duration time.Duration
+	Rate     int64
+	Length   int
+}
+
+// Result is measured by the receiving side. Bandwidth is bits per second,
+// Jitter is milliseconds, packets, loss and jitter are UDP only.
+type Result struct {
+	Protocol  string  `json:"protocol" yaml:"protocol"`
+	Seconds   float64 `json:"seconds" yaml:"seconds"`
+	Bytes     int64   `json:"bytes" yaml:"bytes"`
+	Bandwidth float64 `json:"bandwidth_bps" yaml:"bandwidth_bps"`
+	Packets   int64   `json:"packets,omitempty" yaml:"packets,omitempty"`
+	Lost      int64   `json:"lost,omitempty" yaml:"lost,omitempty"`
+	Loss      float64 `json:"loss,omitempty" yaml:"loss,omitempty"`
+	Jitter    float64 `json:"jitter_ms,omitempty" yaml:"jitter_ms,omitempty"`
+}
+
+// String satisfies stringer interface
+func (r Result) String() string {
+	s := fmt.Sprintf("%s %.2fs %s %s", r.Protocol, r.Seconds, Bytes(r.Bytes), Bits(r.Bandwidth))
+
+	if r.Protocol == "udp" {
+		s += fmt.Sprintf(" jitter %.3fms lost %d/%d (%v%%)", r.Jitter, r.Lost, r.Packets, r.Loss)
+	}
+
+	return s
+}
+
+// Bits formats bandwidth, e.g. 9.52 Mbit/s
+func Bits(bps float64) string {
+	return scaled(bps, "bit/s")
+}
+
+// Bytes formats size, e.g. 11.90 MB
+func Bytes(n int64) string {
+	return scaled(float64(n), "B")
+}
+
+func scaled(v float64, unit string) string {
+	prefixes := []string{"", "K", "M", "G", "T"}
+
+	i := 0
+	for ; v >= 1000 && i < len(prefixes)-1; i++ {
+		v /= 1000
+	}
+
+	return fmt.Sprintf("%.2f %s%s", v, prefixes[i], unit)
+}
+
+// ParseRate parses bits per second with K, M or G suffix, e.g. 10M
+func ParseRate(s string) (int64, error) {
+	if s == "" {
+		return 0, fmt.Errorf("empty bandwidth")
+	}
+
+	multiplier := int64(1)
+
+	switch strings.ToUpper(s[len(s)-1:]) {
+	case "K":
+		multiplier = 1000
+	case "M":
+		multiplier = 1000000
+	case "G":
+		multiplier = 1000000000
+	}
+
+	if multiplier > 1 {
+		s = s[:len(s)-1]
+	}
+
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	return int64(v * float64(multiplier)), nil
+}
+
+// UDP datagram header: type, test id, sequence number, timestamp
+const (
+	udpHello byte = iota
+	udpData
+	udpFin
+	udpAck
+	udpResult
+
+	udpHeaderLen = 1 + 4 + 8 + 8
+)
+
+type header struct {
+	kind byte
+	id   uint32
+	seq  uint64
+	ts   int64
+}
+
+func (h header) marshal(b []byte) []byte {
+	b[0] = h.kind
+	binary.BigEndian.PutUint32(b[1:], h.id)
+	binary.BigEndian.PutUint64(b[5:], h.seq)
+	binary.BigEndian.PutUint64(b[13:], uint64(h.ts))
+
+	return b
+}
+
+func unmarshal(b []byte) (header, bool) {
+	if len(b) < udpHeaderLen {
+		return header{}, false
+	}
+
+	return header{
+		kind: b[0],
+		id:   binary.BigEndian.Uint32(b[1:]),
+		seq:  binary.BigEndian.Uint64(b[5:]),
+		ts:   int64(binary.BigEndian.Uint64(b[13:])),
+	}, true
+}
+
+// Server is a sink of the traffic
+type Server struct {
+	tcp net.Listener
+	udp *net.UDPConn
+
+	mu    sync.Mutex
+	tests map[string]*udpTest
+}
+
+type udpTest struct {
+	id          uint32
+	packets     int64
+	bytes       int64
+	jitter      float64
+	lastTransit int64
+	first, last time.Time
+	result      []byte
+}
+
+// NewServer listens TCP and UDP on the address, zero port is any free one
+func NewServer(addr string) (*Server, error) {
+	tcp, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	udp, err := net.ListenUDP("udp", &net.UDPAddr{IP: tcp.Addr().(*net.TCPAddr).IP, Port: tcp.Addr().(*net.TCPAddr).Port})
+	if err != nil {
+		tcp.Close()
+		return nil, err
+	}
+
+	return &Server{tcp: tcp, udp: udp, tests: make(map[string]*udpTest)}, nil
+}
+
+// Addr returns listening address
+func (s *Server) Addr() string {
+	return s.tcp.Addr().String()
+}
+
+// Serve serves clients until Close
+func (s *Server) Serve() error {
+	done := make(chan error, 2)
+
+	go func() {
+		done <- s.serveTCP()
+	}()
+
+	go func() {
+		done <- s.serveUDP()
+	}()
+
+	err := <-done
+	s.Close()
+	<-done
+
+	return err
+}
+
+// Close stops the server
+func (s *Server) Close() {
+	s.tcp.Close()
+	s.udp.Close()
+}
+
+func (s *Server) serveTCP() error {
+	for {
+		conn, err := s.tcp.Accept()
+		if err != nil {
+			return err
+		}
+
+		go func() {
+			defer conn.Close()
+
+			r := receiveTCP(conn)
+
+			conn.SetWriteDeadline(time.Now().Add(IdleTimeout))
+			if err := json.NewEncoder(conn).Encode(r); err != nil {
+				return
+			}
+		}()
+	}
+}
+
+// receiveTCP counts bytes from the first one to EOF, or to the last one,
+// if the client is idle for IdleTimeout
+func receiveTCP(conn net.Conn) Result {
+	buf := make([]byte, DefaultTCPLen)
+	r := Result{Protocol: "tcp"}
+
+	var start, last time.Time
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(IdleTimeout))
+
+		n, err := conn.Read(buf)
+		if n > 0 {
+			if r.Bytes == 0 {
+				start = time.Now()
+			}
+
+			last = time.Now()
+		}
+
+		r.Bytes += int64(n)
+
+		if err != nil {
+			if !isTimeout(err) {
+				last = time.Now()
+			}
+
+			break
+		}
+	}
+
+	if r.Bytes > 0 {
+		r.Seconds = last.Sub(start).Seconds()
+		r.Bandwidth = bandwidth(r.Bytes, r.Seconds)
+	}
+
+	return r
+}
+
+func (s *Server) serveUDP() error {
+	buf := make([]byte, 64*1024)
+
+	for {
+		n, addr, err := s.udp.ReadFromUDP(buf)
+		if err != nil {
+			return err
+		}
+
+		if reply := s.handleUDP(buf[:n], addr.String(), time.Now()); reply != nil {
+			s.udp.WriteToUDP(reply, addr)
+		}
+	}
+}
+
+// handleUDP updates the test of the client, it returns reply if any
+func (s *Server) handleUDP(b []byte, client string, now time.Time) []byte {
+	h, ok := unmarshal(b)
+	if !ok {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.tests[client]
+
+	switch h.kind {
+	case udpHello:
+		s.tests[client] = &udpTest{id: h.id}
+		return header{kind: udpAck, id: h.id}.marshal(make([]byte, udpHeaderLen))
+
+	case udpData:
+		if t == nil || t.id != h.id || t.result != nil {
+			return nil
+		}
+
+		// RFC 3550 interarrival jitter, clock of all namespaces is the same
+		transit := now.UnixNano() - h.ts
+		if t.packets > 0 {
+			d := math.Abs(float64(transit - t.lastTransit))
+			t.jitter += (d - t.jitter) / 16
+		}
+
+		if t.packets == 0 {
+			t.first = now
+		}
+
+		t.last = now
+		t.lastTransit = transit
+		t.packets++
+		t.bytes += int64(len(b))
+
+	case udpFin:
+		if t == nil || t.id != h.id {
+			return nil
+		}
+
+		if t.result == nil {
+			t.result = t.finish(int64(h.seq), time.Duration(h.ts))
+		}
+
+		return t.result
+	}
+
+	return nil
+}
+
+// finish makes result of the test, fin has number of sent packets and
+// sending duration. Bandwidth is measured from the first received packet
+// to the last one, like TCP's, so packets queued by the link are counted
+// at the rate they are received.
+func (t *udpTest) finish(sent int64, elapsed time.Duration) []byte {
+	seconds := t.last.Sub(t.first).Seconds()
+	if t.packets < 2 {
+		seconds = elapsed.Seconds()
+	}
+
+	r := Result{
+		Protocol: "udp",
+		Seconds:  seconds,
+		Bytes:    t.bytes,
+		Packets:  sent,
+		Lost:     sent - t.packets,
+		Jitter:   math.Round(t.jitter/1000) / 1000,
+	}
+
+	if r.Lost < 0 {
+		r.Lost = 0
+	}
+
+	if sent > 0 {
+		r.Loss = math.Round(float64(r.Lost)*10000/float64(sent)) / 100
+	}
+
+	r.Bandwidth = bandwidth(r.Bytes, r.Seconds)
+
+	b, _ := json.Marshal(r)
+
+	return append(header{kind: udpResult, id: t.id}.marshal(make([]byte, udpHeaderLen)), b...)
+}
+
+func bandwidth(bytes int64, seconds float64) float64 {
+	if seconds <= 0 {
+		return 0
+	}
+
+	return math.Round(float64(bytes) * 8 / seconds)
+}
+
+// Run sends traffic to the server and returns what it has received.
+// Server is waited for a second, so it could be started just before.
+func Run(addr string, o Options) (Result, error) {
+	if o.Duration <= 0 {
+		o.Duration = DefaultDuration
+	}
+
+	switch o.Protocol {
+	case "", "tcp":
+		if o.Length <= 0 {
+			o.Length = DefaultTCPLen
+		}
+
+		return runTCP(addr, o)
+
+	case "udp":
+		if o.Rate <= 0 {
+			o.Rate = DefaultRate
+		}
+
+		if o.Length <= 0 {
+			o.Length = DefaultUDPLen
+		}
+
+		if o.Length < udpHeaderLen {
+			return Result{}, fmt.Errorf("Length %d is less than UDP header %d", o.Length, udpHeaderLen)
+		}
+
+		return runUDP(addr, o)
+	}
+
+	return Result{}, fmt.Errorf("Unknown protocol %s, expected tcp or udp", o.Protocol)
+}
+
+func runTCP(addr string, o Options) (Result, error) {
+	var conn net.Conn
+	var err error
+
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(50 * time.Millisecond) {
+		if conn, err = net.Dial("tcp", addr); err == nil || time.Now().After(deadline) {
+			break
+		}
+	}
+
+	if err != nil {
+		return Result{}, err
+	}
+
+	defer conn.Close()
+
+	buf := make([]byte, o.Length)
+	end := time.Now().Add(o.Duration)
+
+	// write, which is blocked by the peer, e.g. if the link is down,
+	// ends the sending at the end of the duration
+	conn.SetWriteDeadline(end)
+
+	for time.Now().Before(end) {
+		if _, err := conn.Write(buf); err != nil {
+			if isTimeout(err) {
+				break
+			}
+
+			return Result{}, err
+		}
+	}
+
+	if err := conn.(*net.TCPConn).CloseWrite(); err != nil {
+		return Result{}, err
+	}
+
+	// server gives up on the idle client in IdleTimeout after the sending
+	conn.SetReadDeadline(time.Now().Add(IdleTimeout + time.Second))
+
+	out, err := ioutil.ReadAll(conn)
+	if err != nil {
+		return Result{}, fmt.Errorf("No result from server %s: %v", addr, err)
+	}
+
+	r := Result{}
+	if err := json.Unmarshal(out, &r); err != nil {
+		return Result{}, fmt.Errorf("Unable to parse server's result, error: %v, output: %s", err, out)
+	}
+
+	return r, nil
+}
+
+func runUDP(addr string, o Options) (Result, error) {
+	raddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return Result{}, err
+	}
+
+	conn, err := net.DialUDP("udp", nil, raddr)
+	if err != nil {
+		return Result{}, err
+	}
+
+	defer conn.Close()
+
+	id := rand.New(rand.NewSource(time.Now().UnixNano())).Uint32()
+	buf := make([]byte, o.Length)
+
+	if _, err := exchange(conn, header{kind: udpHello, id: id}, udpAck, 2*time.Second); err != nil {
+		return Result{}, fmt.Errorf("Server %s doesn't respond: %v", addr, err)
+	}
+
+	interval := time.Duration(float64(o.Length*8) / float64(o.Rate) * float64(time.Second))
+	start := time.Now()
+
+	var sent uint64
+
+	for time.Since(start) < o.Duration {
+		// packets, which are late, are sent at once
+		if next := start.Add(time.Duration(sent) * interval); time.Now().Before(next) {
+			time.Sleep(time.Until(next))
+		}
+
+		h := header{kind: udpData, id: id, seq: sent, ts: time.Now().UnixNano()}
+		if _, err := conn.Write(h.marshal(buf)); err != nil {
+			return Result{}, err
+		}
+
+		sent++
+	}
+
+	// fin is queued behind the data on the rate limited link
+	reply, err := exchange(conn, header{kind: udpFin, id: id, seq: sent, ts: int64(time.Since(start))}, udpResult, IdleTimeout)
+	if err != nil {
+		return Result{}, fmt.Errorf("No result from server %s: %v", addr, err)
+	}
+
+	r := Result{}
+	if err := json.Unmarshal(reply[udpHeaderLen:], &r); err != nil {
+		return Result{}, fmt.Errorf("Unable to parse server's result, error: %v, output: %s", err, reply)
+	}
+
+	return r, nil
+}
+
+// exchange sends the control datagram every 100ms until the reply of the
+// kind is received or the timeout is over
+func exchange(conn *net.UDPConn, h header, kind byte, timeout time.Duration) ([]byte, error) {
+	out := h.marshal(make([]byte, udpHeaderLen))
+	in := make([]byte, 64*1024)
+
+	for end := time.Now().Add(timeout); time.Now().Before(end); {
+		if _, err := conn.Write(out); err != nil {
+			// ICMP port unreachable of the previous try, server isn't started yet
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+
+		conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+
+		for {
+			n, err := conn.Read(in)
+			if err != nil {
+				break
+			}
+
+			if r, ok := unmarshal(in[:n]); ok && r.kind == kind && r.id == h.id {
+				conn.SetReadDeadline(time.Time{})
+				return in[:n], nil
+			}
+		}
+	}
+
+	return nil, errors.New("timeout")
+}
+
+func isTimeout(err error) bool {
+	var nerr net.Error
+	return errors.As(err, &nerr) && nerr.Timeout()
+}
+
+// HostPort joins host and port, IPv6 host is bracketed
+func HostPort(host string, port int) string {
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
@@ -0,0 +1,202 @@
+package perf
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+)
+
+func newTestServer(t *testing.T) *Server {
+	s, err := NewServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go s.Serve()
+
+	return s
+}
+
+func TestTCP(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	r, err := Run(s.Addr(), Options{Duration: 200 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Protocol != "tcp" || r.Bytes == 0 || r.Bandwidth <= 0 || r.Seconds <= 0 {
+		t.Fatal("Unexpected result:", r)
+	}
+}
+
+func TestTCPIdle(t *testing.T) {
+	timeout := IdleTimeout
+	IdleTimeout = 200 * time.Millisecond
+
+	defer func() {
+		IdleTimeout = timeout
+	}()
+
+	s := newTestServer(t)
+	defer s.Close()
+
+	// client, which is gone without closing, gets the result of received bytes
+	conn, err := net.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	if _, err := conn.Write(make([]byte, 1000)); err != nil {
+		t.Fatal(err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+
+	out, err := ioutil.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := Result{}
+	if err := json.Unmarshal(out, &r); err != nil || r.Bytes != 1000 {
+		t.Fatal("Unexpected result:", err, string(out))
+	}
+
+	// server, which doesn't read, blocks the client for the duration
+	// and IdleTimeout only
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer l.Close()
+
+	go func() {
+		if c, err := l.Accept(); err == nil {
+			defer c.Close()
+			time.Sleep(5 * time.Second)
+		}
+	}()
+
+	start := time.Now()
+
+	if _, err := Run(l.Addr().String(), Options{Duration: 200 * time.Millisecond}); err == nil {
+		t.Fatal("Expected no result error")
+	}
+
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Fatal("Client is blocked for", elapsed)
+	}
+}
+
+func TestUDP(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	r, err := Run(s.Addr(), Options{Protocol: "udp", Duration: 300 * time.Millisecond, Rate: 10000000, Length: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 10 Mbit/s of 1000 bytes datagrams is 1250 packets per second
+	if r.Protocol != "udp" || r.Packets < 300 || r.Packets > 400 || r.Bytes != (r.Packets-r.Lost)*1000 {
+		t.Fatal("Unexpected result:", r)
+	}
+
+	if r.Bandwidth < 5000000 || r.Bandwidth > 15000000 {
+		t.Fatal("Unexpected bandwidth:", r)
+	}
+}
+
+func TestUDPLoss(t *testing.T) {
+	s := &Server{tests: make(map[string]*udpTest)}
+	now := time.Unix(0, 1000000000)
+
+	s.handleUDP(header{kind: udpHello, id: 1}.marshal(make([]byte, udpHeaderLen)), "c1", now)
+
+	// every other packet is lost, transit time grows by 2ms, so jitter converges to 2ms
+	for seq := uint64(0); seq < 8; seq += 2 {
+		sent := now.Add(time.Duration(seq) * time.Millisecond)
+		s.handleUDP(header{kind: udpData, id: 1, seq: seq, ts: sent.UnixNano()}.marshal(make([]byte, 100)), "c1", sent.Add(time.Duration(seq)*time.Millisecond))
+	}
+
+	// data of the other test is ignored
+	s.handleUDP(header{kind: udpData, id: 2}.marshal(make([]byte, 100)), "c1", now)
+
+	reply := s.handleUDP(header{kind: udpFin, id: 1, seq: 8, ts: int64(time.Second)}.marshal(make([]byte, udpHeaderLen)), "c1", now)
+
+	h, ok := unmarshal(reply)
+	if !ok || h.kind != udpResult {
+		t.Fatal("Unexpected reply:", reply)
+	}
+
+	// sender has been sending for a second, but bandwidth is measured by
+	// the receiver: 400 bytes from the first packet to the last one in 12ms
+	expected := `{"protocol":"udp","seconds":0.012,"bytes":400,"bandwidth_bps":266667,"packets":8,"lost":4,"loss":50,"jitter_ms":0.352}`
+	if string(reply[udpHeaderLen:]) != expected {
+		t.Fatalf("\nExpected: %s\nObtained: %s", expected, reply[udpHeaderLen:])
+	}
+}
+
+func TestUDPQueuedFin(t *testing.T) {
+	udp, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer udp.Close()
+
+	// fin is queued behind the data for 1.5s, e.g. by the rate limited link
+	go func() {
+		s := &Server{tests: make(map[string]*udpTest)}
+		buf := make([]byte, 64*1024)
+
+		var fin time.Time
+
+		for {
+			n, addr, err := udp.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+
+			if h, _ := unmarshal(buf[:n]); h.kind == udpFin {
+				if fin.IsZero() {
+					fin = time.Now()
+				}
+
+				if time.Since(fin) < 1500*time.Millisecond {
+					continue
+				}
+			}
+
+			if reply := s.handleUDP(buf[:n], addr.String(), time.Now()); reply != nil {
+				udp.WriteToUDP(reply, addr)
+			}
+		}
+	}()
+
+	r, err := Run(udp.LocalAddr().String(), Options{Protocol: "udp", Duration: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Packets == 0 || r.Lost != 0 {
+		t.Fatal("Unexpected result:", r)
+	}
+}
+
+func TestBits(t *testing.T) {
+	if s := Bits(9520000); s != "9.52 Mbit/s" {
+		t.Fatal("Unexpected bits:", s)
+	}
+
+	if s := Bytes(512); s != "512.00 B" {
+		t.Fatal("Unexpected bytes:", s)
+	}
+}
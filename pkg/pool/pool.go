@@ -12,9 +12,16 @@ type Pool struct {
 	pool    map[string]bool
 	created bool
 	preset  bool
+	network string
 }
 
-var instance Pool
+// DefaultNetwork6 is used by NextCidr6, if IPv6 network isn't set
+var DefaultNetwork6 = "fd00::/64"
+
+var (
+	instance Pool
+	network6 string
+)
 
 // ThePool is a singleton, wwhich creates a pool of IPs based on provided CIDR
 func ThePool(args ...interface{}) Pool {
@@ -28,6 +35,7 @@ func ThePool(args ...interface{}) Pool {
 		instance = newPool()
 		instance.cache[key.String()] = ipnet
 		instance.preset = true
+		instance.network = key.String()
 	}
 
 	if instance.created == false {
@@ -37,6 +45,12 @@ func ThePool(args ...interface{}) Pool {
 	return instance
 }
 
+// New creates a pool, which isn't shared, e.g. to give out addresses
+// of a single topology, which are the same every time
+func New() Pool {
+	return newPool()
+}
+
 func newPool() Pool {
 	return Pool{
 		cache:   make(map[string]*net.IPNet),
@@ -51,6 +65,9 @@ func (p Pool) NextCidr(args ...interface{}) string {
 
 	if len(args) == 1 {
 		cidr = args[0].(string)
+	} else if c, found := p.cache[p.network]; found {
+		// cache has networks of all allocated addresses, preset one is the default
+		cidr = c.String()
 	} else {
 		for k := range p.cache {
 			ipnet := p.cache[k]
@@ -89,6 +106,44 @@ func (p Pool) NextCidr(args ...interface{}) string {
 	return ipnet.String()
 }
 
+// SetNetwork6 sets IPv6 network of the pool, links get addresses from
+// both networks then
+func SetNetwork6(cidr string) error {
+	ip, ipnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return err
+	}
+
+	if ip.To4() != nil {
+		return fmt.Errorf("%s isn't IPv6 network", cidr)
+	}
+
+	network6 = ipnet.String()
+
+	return nil
+}
+
+// Network6 returns IPv6 network set by SetNetwork6
+func (p Pool) Network6() string {
+	return network6
+}
+
+// NextCidr6 returns the next IPv6 cidr of the provided network,
+// of the pool's one or of the DefaultNetwork6
+func (p Pool) NextCidr6(args ...interface{}) string {
+	cidr := network6
+
+	if len(args) == 1 {
+		cidr = args[0].(string)
+	}
+
+	if cidr == "" {
+		cidr = DefaultNetwork6
+	}
+
+	return p.NextCidr(cidr)
+}
+
 // NextAddr returns next address
 func (p Pool) NextAddr(args ...interface{}) string {
 	var addr, netmask string
@@ -104,6 +159,11 @@ func (p Pool) NextAddr(args ...interface{}) string {
 		}
 
 	case 0:
+		if c, found := p.cache[p.network]; found {
+			ipnet = c
+			break
+		}
+
 		for k := range p.cache {
 			ipnet = p.cache[k]
 			break
@@ -151,6 +211,16 @@ func (p Pool) get(cidr string) (net.IP, *net.IPNet, string, error) {
 	return ip, ipnet, ip.String(), err
 }
 
+// Network returns the network the pool has been created with, e.g. 10.0.0.0/16
+func (p Pool) Network() string {
+	c, found := p.cache[p.network]
+	if !p.preset || !found {
+		return ""
+	}
+
+	return (&net.IPNet{IP: c.IP.Mask(c.Mask), Mask: c.Mask}).String()
+}
+
 // Preset method is a wrapper for unexported field
 func (p Pool) Preset() bool {
 	return p.preset
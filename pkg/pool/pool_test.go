@@ -1,6 +1,7 @@
 package pool
 
 import (
+	"fmt"
 	"testing"
 )
 
@@ -55,3 +56,54 @@ func TestCustomRange(t *testing.T) {
 		t.Fatal("Expected ip3 = 192.168.0.3/24, obtained =", ip3)
 	}
 }
+
+func TestIPv6(t *testing.T) {
+	instance.created = false
+	ThePool("192.168.1.0/24")
+
+	if err := SetNetwork6("fd00:1::/64"); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { network6 = "" }()
+
+	if err := SetNetwork6("10.0.0.0/8"); err == nil {
+		t.Fatal("Expected error for IPv4 network")
+	}
+
+	ip1 := ThePool().NextCidr6()
+	ip2 := ThePool().NextCidr6()
+
+	if ip1 != "fd00:1::1/64" || ip2 != "fd00:1::2/64" {
+		t.Fatal("Expected fd00:1::1/64 and fd00:1::2/64, obtained =", ip1, ip2)
+	}
+
+	// preset network is the default one, whatever else is allocated
+	ThePool().NextCidr("10.1.0.0/24")
+
+	for i := 1; i < 10; i++ {
+		if ip := ThePool().NextCidr(); ip != fmt.Sprintf("192.168.1.%d/24", i) {
+			t.Fatalf("Expected 192.168.1.%d/24, obtained = %s", i, ip)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	instance.created = false
+	ThePool("192.168.2.0/24")
+	ThePool().NextCidr()
+
+	if n := ThePool().Network(); n != "192.168.2.0/24" {
+		t.Fatal("Expected 192.168.2.0/24, obtained =", n)
+	}
+
+	// pools don't share addresses
+	for i := 0; i < 2; i++ {
+		if ip := New().NextCidr("192.168.2.0/24"); ip != "192.168.2.1/24" {
+			t.Fatal("Expected 192.168.2.1/24, obtained =", ip)
+		}
+	}
+
+	if n := New().Network(); n != "" {
+		t.Fatal("Expected no network, obtained =", n)
+	}
+}